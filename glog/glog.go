@@ -13,6 +13,7 @@ import (
 	"path"
 	"strconv"
 	"strings"
+	"sync/atomic"
 	"time"
 )
 
@@ -44,6 +45,14 @@ type RotatingHandler struct {
 	httpMsgChannel chan *logInfo //http消息通道
 	HTTPMsgmethod  string        //http日志发送模式
 	HTTPMsgURL     string        //http日志接收地址
+	//HTTPTransport http日志使用的传输，为空使用 http.DefaultTransport | 需在 StartLogHandler 前设置
+	//可设置为带熔断器的 ghttp.Client，日志服务器不可用时快速失败；该客户端不能带 LogMiddleware，否则发送日志又会产生日志
+	HTTPTransport http.RoundTripper
+
+	flushChannel chan chan struct{} //Flush 请求通道
+	started      int32              //写入线程是否已启动
+	httpPending  int64              //已放入http通道、尚未发送完成的日志数（原子操作）
+	httpSending  int32              //http发送线程是否已启动（未配置发送地址时不启动，Flush 不等待http日志）
 
 }
 
@@ -63,6 +72,7 @@ var LogHandler = &RotatingHandler{
 	HTTPMsgmethod:  "POST",                     //默认http日志方法方法
 	errMsgChannel:  make(chan *string, 10000),  //设置 1w 个写缓存的通道
 	httpMsgChannel: make(chan *logInfo, 10000), //post比 写文件速度慢，所以缓存通道多一些
+	flushChannel:   make(chan chan struct{}),
 }
 
 var msgTotalLen int64 //现有log文件大小
@@ -101,6 +111,7 @@ func StartLogHandler(appID int, appVersion string, runEnvironment int, screenSta
 	//开启线程 判断目录下，是否有过期的文件有就删除
 	go checkFileTime(LogHandler.SaveDay) //(参数：过期时间)只删除日志文件（.log）
 	go writeMsgHandle()                  //开启线程 做写入消息处理
+	atomic.StoreInt32(&LogHandler.started, 1)
 
 	//如果开启 http 发送模式
 	if httpStatus == true {
@@ -131,16 +142,78 @@ func writeMsgHandle() {
 				logBuffer.Reset()         //清空buffer
 			}
 		case errMsg = <-LogHandler.errMsgChannel:
-			msgTotalLen = msgTotalLen + int64(len(*errMsg)) //获得新总写入字节数
-			logBuffer.WriteString(*errMsg)                  //将要写的数据放入缓存Buffer
-			if msgTotalLen > LogHandler.MaxSize {           //如果合计总文件，大于设置的文件大小，就执行
-				LogHandler.rename()                  //改名
-				logWriteBytes(&logBuffer)            //写入文件(字符串)，如果文件不存在就创建文件
-				msgTotalLen = int64(logBuffer.Len()) //重置msgTotalLen为最后写入的字符串大小）
-				logBuffer.Reset()                    //清空buffer
+			bufferMsg(&logBuffer, errMsg)
+		case done := <-LogHandler.flushChannel: //Flush：写入通道中剩余的消息及缓存
+			for n := len(LogHandler.errMsgChannel); n > 0; n-- {
+				bufferMsg(&logBuffer, <-LogHandler.errMsgChannel)
+			}
+			if logBuffer.Len() > 0 {
+				logWriteBytes(&logBuffer)
+				logBuffer.Reset()
 			}
+			close(done)
+		}
+	}
+}
+
+//bufferMsg 将消息放入缓存，超过文件大小时改名并写入
+func bufferMsg(logBuffer *bytes.Buffer, errMsg *string) {
+	msgTotalLen = msgTotalLen + int64(len(*errMsg)) //获得新总写入字节数
+	logBuffer.WriteString(*errMsg)                  //将要写的数据放入缓存Buffer
+	if msgTotalLen > LogHandler.MaxSize {           //如果合计总文件，大于设置的文件大小，就执行
+		LogHandler.rename()                  //改名
+		logWriteBytes(logBuffer)             //写入文件(字符串)，如果文件不存在就创建文件
+		msgTotalLen = int64(logBuffer.Len()) //重置msgTotalLen为最后写入的字符串大小）
+		logBuffer.Reset()                    //清空buffer
+	}
+}
+
+/*
+Flush 立即将缓存的日志写入文件，并等待http日志发送完毕（包括已从通道取出、正在发送的日志；未启动http发送线程时不等待）
+参数：timeout 最长等待时间
+返回：超时未完成返回错误 | 未调用 StartLogHandler 时直接返回
+用于程序退出前避免丢失日志（写入线程每1秒才写一次文件）
+*/
+func Flush(timeout time.Duration) error {
+	if atomic.LoadInt32(&LogHandler.started) == 0 {
+		return nil
+	}
+	deadline := time.NewTimer(timeout)
+	defer deadline.Stop()
+	if err := flushFile(deadline.C, timeout); err != nil {
+		return err
+	}
+	//未启动http发送线程时通道中的日志不会被消费，无需等待
+	if atomic.LoadInt32(&LogHandler.httpSending) == 0 {
+		return nil
+	}
+	ticker := time.NewTicker(20 * time.Millisecond)
+	defer ticker.Stop()
+	for atomic.LoadInt64(&LogHandler.httpPending) > 0 {
+		select {
+		case <-ticker.C:
+		case <-deadline.C:
+			return fmt.Errorf("glog flush timeout after %s: %d http logs pending", timeout, atomic.LoadInt64(&LogHandler.httpPending))
 		}
 	}
+	//http发送失败的日志会放入文件通道，再写入一次
+	return flushFile(deadline.C, timeout)
+}
+
+//flushFile 通知写入线程将通道中剩余的消息及缓存写入文件，并等待写入完成
+func flushFile(deadline <-chan time.Time, timeout time.Duration) error {
+	done := make(chan struct{})
+	select {
+	case LogHandler.flushChannel <- done:
+	case <-deadline:
+		return fmt.Errorf("glog flush timeout after %s", timeout)
+	}
+	select {
+	case <-done:
+	case <-deadline:
+		return fmt.Errorf("glog flush timeout after %s", timeout)
+	}
+	return nil
 }
 
 //logWriteBytes 将字符串写入日志 --测试用WriteBytes
@@ -236,6 +309,7 @@ func logDeal(item *logInfo) {
 	item.errCode = LogHandler.ID + item.errCode
 	//http发送通道
 	if LogHandler.httpStatus && !(!LogHandler.CloudLogStatus && item.msgType == "Log") {
+		atomic.AddInt64(&LogHandler.httpPending, 1)
 		LogHandler.httpMsgChannel <- item
 	}
 }
@@ -328,6 +402,7 @@ func StarupLogHTTPParameter() {
 		for i := 0; i < 10; i++ {
 			go donormalHTTPRequest()
 		}
+		atomic.StoreInt32(&LogHandler.httpSending, 1)
 	} else {
 		Printfer("1001", "Err ： http日志发送地址 或者 http日志发送模式 为空！")
 	}
@@ -405,7 +480,8 @@ func getLocalIP() string {
 //普通的post 请求
 func donormalHTTPRequest() {
 	httplog := &http.Client{
-		Timeout: 2 * time.Second, //为了http 请求线程安全设置2秒超时|终止本次请求
+		Timeout:   2 * time.Second, //为了http 请求线程安全设置2秒超时|终止本次请求
+		Transport: LogHandler.HTTPTransport,
 	}
 	var posterrString string
 	var body []byte
@@ -447,13 +523,15 @@ func donormalHTTPRequest() {
 		} else {
 			resp.Body.Close() //报错情况关闭会导致内存指针错误，简言之，接收端关了，发送端就挂了
 		}
+		atomic.AddInt64(&LogHandler.httpPending, -1) //发送完成（成功或失败），Flush 据此等待
 	}
 }
 
 //发送http应用启动日志
 func starupLogHandle() {
 	httplog := &http.Client{
-		Timeout: 10 * time.Second,
+		Timeout:   10 * time.Second,
+		Transport: LogHandler.HTTPTransport,
 	}
 	var resp *http.Response
 	var err error
@@ -505,7 +583,8 @@ error 错误返回
 */
 func httpRequestData(url, parameter, method string, timeout int) (int, string, error) {
 	httpweixin := &http.Client{
-		Timeout: time.Duration(timeout) * time.Second,
+		Timeout:   time.Duration(timeout) * time.Second,
+		Transport: LogHandler.HTTPTransport,
 	}
 	var resp *http.Response
 	var err error
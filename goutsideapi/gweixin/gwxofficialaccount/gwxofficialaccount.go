@@ -400,28 +400,9 @@ type JSTicket struct {
 }
 
 //CreateSignature 构造微信签名
+//对所有待签名参数按照字段名的ASCII 码从小到大排序（字典序）后拼接 url 参数，计算 sha1
 func CreateSignature(args map[string]string) string {
-	//对所有待签名参数按照字段名的ASCII 码从小到大排序（字典序）
-	keys := make([]string, 0)
-	for k := range args {
-		keys = append(keys, k)
-	}
-	sort.Strings(keys)
-
-	//按照排序好的key来构造url参数
-	urlParams := ""
-	for _, key := range keys {
-		urlParams += fmt.Sprintf("&%s=%s", key, args[key])
-	}
-	urlParams = urlParams[1:] //去掉第一个&
-
-	//创建一个hash变量
-	hashItem := sha1.New()
-	//写入数据
-	hashItem.Write([]byte(urlParams))
-	//将hash加密后的数据（[]byte）以字符串格式输出
-	signature := hex.EncodeToString(hashItem.Sum([]byte(nil)))
-
+	signature, _ := (&ghttp.Signer{Algorithm: ghttp.SignSHA1}).Sign(args)
 	return signature
 }
 
@@ -2,36 +2,59 @@ package gmap
 
 import (
 	"encoding/json"
+	"fmt"
 	"sync"
 )
 
 var SHARD_COUNT = 32
 
-// A "thread" safe map of type string:Anything.
+// A "thread" safe map of type K:V.
 // To avoid lock bottlenecks this map is dived to several (SHARD_COUNT) map shards.
-type ConcurrentMap []*ConcurrentMapShared
+type ConcurrentMapOf[K comparable, V any] []*ConcurrentMapSharedOf[K, V]
 
-// A "thread" safe string to anything map.
-type ConcurrentMapShared struct {
-	items        map[string]interface{}
-	sync.RWMutex // Read Write mutex, guards access to internal map.
+// A "thread" safe K to V map.
+type ConcurrentMapSharedOf[K comparable, V any] struct {
+	items        map[K]V
+	sharding     func(key K) uint32 // picks the shard of a key, the same for every shard of a map
+	sync.RWMutex                    // Read Write mutex, guards access to internal map.
 }
 
+// A "thread" safe map of type string:Anything, kept for compatibility.
+type ConcurrentMap = ConcurrentMapOf[string, interface{}]
+
+// A "thread" safe string to anything map, kept for compatibility.
+type ConcurrentMapShared = ConcurrentMapSharedOf[string, interface{}]
+
 // Creates a new concurrent map.
 func NewConcurrentMap() ConcurrentMap {
-	m := make(ConcurrentMap, SHARD_COUNT)
+	return NewConcurrentMapOf[interface{}]()
+}
+
+// NewConcurrentMapOf creates a new concurrent map with string keys, sharded by fnv32.
+func NewConcurrentMapOf[V any]() ConcurrentMapOf[string, V] {
+	return NewConcurrentMapWithHash[string, V](fnv32)
+}
+
+// NewConcurrentMapWithHash creates a new concurrent map whose keys are
+// sharded by the given hash function; a nil hash shards by the key's fmt.Sprint text.
+func NewConcurrentMapWithHash[K comparable, V any](sharding func(key K) uint32) ConcurrentMapOf[K, V] {
+	m := make(ConcurrentMapOf[K, V], SHARD_COUNT)
 	for i := 0; i < SHARD_COUNT; i++ {
-		m[i] = &ConcurrentMapShared{items: make(map[string]interface{})}
+		m[i] = &ConcurrentMapSharedOf[K, V]{items: make(map[K]V), sharding: sharding}
 	}
 	return m
 }
 
 // GetShard returns shard under given key
-func (m ConcurrentMap) GetShard(key string) *ConcurrentMapShared {
-	return m[uint(fnv32(key))%uint(SHARD_COUNT)]
+func (m ConcurrentMapOf[K, V]) GetShard(key K) *ConcurrentMapSharedOf[K, V] {
+	sharding := m[0].sharding
+	if sharding == nil {
+		sharding = defaultSharding[K]
+	}
+	return m[uint(sharding(key))%uint(len(m))]
 }
 
-func (m ConcurrentMap) MSet(data map[string]interface{}) {
+func (m ConcurrentMapOf[K, V]) MSet(data map[K]V) {
 	for key, value := range data {
 		shard := m.GetShard(key)
 		shard.Lock()
@@ -41,7 +64,7 @@ func (m ConcurrentMap) MSet(data map[string]interface{}) {
 }
 
 // Sets the given value under the specified key.
-func (m ConcurrentMap) Set(key string, value interface{}) {
+func (m ConcurrentMapOf[K, V]) Set(key K, value V) {
 	// Get map shard.
 	shard := m.GetShard(key)
 	shard.Lock()
@@ -53,10 +76,13 @@ func (m ConcurrentMap) Set(key string, value interface{}) {
 // It is called while lock is held, therefore it MUST NOT
 // try to access other keys in same map, as it can lead to deadlock since
 // Go sync.RWLock is not reentrant
-type UpsertCb func(exist bool, valueInMap interface{}, newValue interface{}) interface{}
+type UpsertCbOf[V any] func(exist bool, valueInMap V, newValue V) V
+
+// UpsertCb is the UpsertCbOf of ConcurrentMap.
+type UpsertCb = UpsertCbOf[interface{}]
 
 // Insert or Update - updates existing element or inserts a new one using UpsertCb
-func (m ConcurrentMap) Upsert(key string, value interface{}, cb UpsertCb) (res interface{}) {
+func (m ConcurrentMapOf[K, V]) Upsert(key K, value V, cb UpsertCbOf[V]) (res V) {
 	shard := m.GetShard(key)
 	shard.Lock()
 	v, ok := shard.items[key]
@@ -67,7 +93,7 @@ func (m ConcurrentMap) Upsert(key string, value interface{}, cb UpsertCb) (res i
 }
 
 // Sets the given value under the specified key if no value was associated with it.
-func (m ConcurrentMap) SetIfAbsent(key string, value interface{}) bool {
+func (m ConcurrentMapOf[K, V]) SetIfAbsent(key K, value V) bool {
 	// Get map shard.
 	shard := m.GetShard(key)
 	shard.Lock()
@@ -80,7 +106,7 @@ func (m ConcurrentMap) SetIfAbsent(key string, value interface{}) bool {
 }
 
 // Get retrieves an element from map under given key.
-func (m ConcurrentMap) Get(key string) (interface{}, bool) {
+func (m ConcurrentMapOf[K, V]) Get(key K) (V, bool) {
 	// Get shard
 	shard := m.GetShard(key)
 	shard.RLock()
@@ -91,10 +117,9 @@ func (m ConcurrentMap) Get(key string) (interface{}, bool) {
 }
 
 // Count returns the number of elements within the map.
-func (m ConcurrentMap) Count() int {
+func (m ConcurrentMapOf[K, V]) Count() int {
 	count := 0
-	for i := 0; i < SHARD_COUNT; i++ {
-		shard := m[i]
+	for _, shard := range m {
 		shard.RLock()
 		count += len(shard.items)
 		shard.RUnlock()
@@ -103,7 +128,7 @@ func (m ConcurrentMap) Count() int {
 }
 
 // Looks up an item under specified key
-func (m ConcurrentMap) Has(key string) bool {
+func (m ConcurrentMapOf[K, V]) Has(key K) bool {
 	// Get shard
 	shard := m.GetShard(key)
 	shard.RLock()
@@ -114,7 +139,7 @@ func (m ConcurrentMap) Has(key string) bool {
 }
 
 // Remove removes an element from the map.
-func (m ConcurrentMap) Remove(key string) {
+func (m ConcurrentMapOf[K, V]) Remove(key K) {
 	// Try to get shard.
 	shard := m.GetShard(key)
 	shard.Lock()
@@ -123,7 +148,7 @@ func (m ConcurrentMap) Remove(key string) {
 }
 
 // Pop removes an element from the map and returns it
-func (m ConcurrentMap) Pop(key string) (v interface{}, exists bool) {
+func (m ConcurrentMapOf[K, V]) Pop(key K) (v V, exists bool) {
 	// Try to get shard.
 	shard := m.GetShard(key)
 	shard.Lock()
@@ -134,34 +159,37 @@ func (m ConcurrentMap) Pop(key string) (v interface{}, exists bool) {
 }
 
 // IsEmpty checks if map is empty.
-func (m ConcurrentMap) IsEmpty() bool {
+func (m ConcurrentMapOf[K, V]) IsEmpty() bool {
 	return m.Count() == 0
 }
 
 // Used by the Iter & IterBuffered functions to wrap two variables together over a channel,
-type Tuple struct {
-	Key string
-	Val interface{}
+type TupleOf[K comparable, V any] struct {
+	Key K
+	Val V
 }
 
+// Tuple is the TupleOf of ConcurrentMap.
+type Tuple = TupleOf[string, interface{}]
+
 // Iter returns an iterator which could be used in a for range loop.
 //
 // Deprecated: using IterBuffered() will get a better performence
-func (m ConcurrentMap) Iter() <-chan Tuple {
+func (m ConcurrentMapOf[K, V]) Iter() <-chan TupleOf[K, V] {
 	chans := snapshot(m)
-	ch := make(chan Tuple)
+	ch := make(chan TupleOf[K, V])
 	go fanIn(chans, ch)
 	return ch
 }
 
 // IterBuffered returns a buffered iterator which could be used in a for range loop.
-func (m ConcurrentMap) IterBuffered() <-chan Tuple {
+func (m ConcurrentMapOf[K, V]) IterBuffered() <-chan TupleOf[K, V] {
 	chans := snapshot(m)
 	total := 0
 	for _, c := range chans {
 		total += cap(c)
 	}
-	ch := make(chan Tuple, total)
+	ch := make(chan TupleOf[K, V], total)
 	go fanIn(chans, ch)
 	return ch
 }
@@ -170,19 +198,19 @@ func (m ConcurrentMap) IterBuffered() <-chan Tuple {
 // which likely takes a snapshot of `m`.
 // It returns once the size of each buffered channel is determined,
 // before all the channels are populated using goroutines.
-func snapshot(m ConcurrentMap) (chans []chan Tuple) {
-	chans = make([]chan Tuple, SHARD_COUNT)
+func snapshot[K comparable, V any](m ConcurrentMapOf[K, V]) (chans []chan TupleOf[K, V]) {
+	chans = make([]chan TupleOf[K, V], len(m))
 	wg := sync.WaitGroup{}
-	wg.Add(SHARD_COUNT)
+	wg.Add(len(m))
 	// Foreach shard.
 	for index, shard := range m {
-		go func(index int, shard *ConcurrentMapShared) {
+		go func(index int, shard *ConcurrentMapSharedOf[K, V]) {
 			// Foreach key, value pair.
 			shard.RLock()
-			chans[index] = make(chan Tuple, len(shard.items))
+			chans[index] = make(chan TupleOf[K, V], len(shard.items))
 			wg.Done()
 			for key, val := range shard.items {
-				chans[index] <- Tuple{key, val}
+				chans[index] <- TupleOf[K, V]{key, val}
 			}
 			shard.RUnlock()
 			close(chans[index])
@@ -193,11 +221,11 @@ func snapshot(m ConcurrentMap) (chans []chan Tuple) {
 }
 
 // fanIn reads elements from channels `chans` into channel `out`
-func fanIn(chans []chan Tuple, out chan Tuple) {
+func fanIn[K comparable, V any](chans []chan TupleOf[K, V], out chan TupleOf[K, V]) {
 	wg := sync.WaitGroup{}
 	wg.Add(len(chans))
 	for _, ch := range chans {
-		go func(ch chan Tuple) {
+		go func(ch chan TupleOf[K, V]) {
 			for t := range ch {
 				out <- t
 			}
@@ -208,9 +236,9 @@ func fanIn(chans []chan Tuple, out chan Tuple) {
 	close(out)
 }
 
-// Items returns all items as map[string]interface{}
-func (m ConcurrentMap) Items() map[string]interface{} {
-	tmp := make(map[string]interface{})
+// Items returns all items as map[K]V
+func (m ConcurrentMapOf[K, V]) Items() map[K]V {
+	tmp := make(map[K]V)
 
 	// Insert items to temporary map.
 	for item := range m.IterBuffered() {
@@ -224,13 +252,15 @@ func (m ConcurrentMap) Items() map[string]interface{} {
 // maps. RLock is held for all calls for a given shard
 // therefore callback sess consistent view of a shard,
 // but not across the shards
-type IterCb func(key string, v interface{})
+type IterCbOf[K comparable, V any] func(key K, v V)
+
+// IterCb is the IterCbOf of ConcurrentMap.
+type IterCb = IterCbOf[string, interface{}]
 
 //IterCb Callback based iterator, cheapest way to read
 // all elements in a map.
-func (m ConcurrentMap) IterCb(fn IterCb) {
-	for idx := range m {
-		shard := (m)[idx]
+func (m ConcurrentMapOf[K, V]) IterCb(fn IterCbOf[K, V]) {
+	for _, shard := range m {
 		shard.RLock()
 		for key, value := range shard.items {
 			fn(key, value)
@@ -239,16 +269,16 @@ func (m ConcurrentMap) IterCb(fn IterCb) {
 	}
 }
 
-// Keys returns all keys as []string
-func (m ConcurrentMap) Keys() []string {
+// Keys returns all keys as []K
+func (m ConcurrentMapOf[K, V]) Keys() []K {
 	count := m.Count()
-	ch := make(chan string, count)
+	ch := make(chan K, count)
 	go func() {
 		// Foreach shard.
 		wg := sync.WaitGroup{}
-		wg.Add(SHARD_COUNT)
+		wg.Add(len(m))
 		for _, shard := range m {
-			go func(shard *ConcurrentMapShared) {
+			go func(shard *ConcurrentMapSharedOf[K, V]) {
 				// Foreach key, value pair.
 				shard.RLock()
 				for key := range shard.items {
@@ -263,7 +293,7 @@ func (m ConcurrentMap) Keys() []string {
 	}()
 
 	// Generate keys
-	keys := make([]string, 0, count)
+	keys := make([]K, 0, count)
 	for k := range ch {
 		keys = append(keys, k)
 	}
@@ -271,9 +301,9 @@ func (m ConcurrentMap) Keys() []string {
 }
 
 //Reviles ConcurrentMap "private" variables to json marshal.
-func (m ConcurrentMap) MarshalJSON() ([]byte, error) {
+func (m ConcurrentMapOf[K, V]) MarshalJSON() ([]byte, error) {
 	// Create a temporary map, which will hold all item spread across shards.
-	tmp := make(map[string]interface{})
+	tmp := make(map[K]V)
 
 	// Insert items to temporary map.
 	for item := range m.IterBuffered() {
@@ -282,6 +312,14 @@ func (m ConcurrentMap) MarshalJSON() ([]byte, error) {
 	return json.Marshal(tmp)
 }
 
+// defaultSharding hashes keys of maps created without a hash function.
+func defaultSharding[K comparable](key K) uint32 {
+	if s, ok := any(key).(string); ok {
+		return fnv32(s)
+	}
+	return fnv32(fmt.Sprint(key))
+}
+
 func fnv32(key string) uint32 {
 	hash := uint32(2166136261)
 	const prime32 = uint32(16777619)
@@ -0,0 +1,116 @@
+package gmap
+
+import (
+	"encoding/json"
+	"sort"
+	"strconv"
+	"sync"
+	"testing"
+)
+
+func TestConcurrentMap(t *testing.T) {
+	m := NewConcurrentMap()
+	m.Set("a", 1)
+	m.MSet(map[string]interface{}{"b": "two", "c": 3.0})
+	if v, ok := m.Get("a"); !ok || v != 1 {
+		t.Errorf("Get(a) = %v, %v", v, ok)
+	}
+	if m.SetIfAbsent("a", 9) || !m.SetIfAbsent("d", 4) {
+		t.Error("SetIfAbsent")
+	}
+	if m.Count() != 4 || m.IsEmpty() || !m.Has("d") {
+		t.Errorf("Count() = %d", m.Count())
+	}
+	if v, ok := m.Pop("d"); !ok || v != 4 || m.Has("d") {
+		t.Errorf("Pop(d) = %v, %v", v, ok)
+	}
+	m.Remove("c")
+	keys := m.Keys()
+	sort.Strings(keys)
+	if len(keys) != 2 || keys[0] != "a" || keys[1] != "b" {
+		t.Errorf("Keys() = %v", keys)
+	}
+	if b, err := json.Marshal(m); err != nil || string(b) != `{"a":1,"b":"two"}` {
+		t.Errorf("MarshalJSON = %s, %v", b, err)
+	}
+}
+
+func TestConcurrentMapOf(t *testing.T) {
+	m := NewConcurrentMapOf[int]()
+	add := func(exist bool, old, n int) int { return old + n }
+	m.Upsert("n", 2, add)
+	if got := m.Upsert("n", 3, add); got != 5 {
+		t.Errorf("Upsert = %d, want 5", got)
+	}
+	if v, ok := m.Get("missing"); ok || v != 0 {
+		t.Errorf("Get(missing) = %d, %v", v, ok)
+	}
+	for i := 0; i < 100; i++ {
+		m.Set(strconv.Itoa(i), i)
+	}
+	sum := 0
+	m.IterCb(func(key string, v int) { sum += v })
+	if sum != 4950+5 {
+		t.Errorf("IterCb sum = %d", sum)
+	}
+	n := 0
+	for range m.IterBuffered() {
+		n++
+	}
+	if n != 101 || len(m.Items()) != 101 {
+		t.Errorf("IterBuffered yielded %d, Items %d", n, len(m.Items()))
+	}
+}
+
+func TestConcurrentMapWithHash(t *testing.T) {
+	type point struct{ X, Y int }
+	for name, sharding := range map[string]func(point) uint32{
+		"custom": func(p point) uint32 { return uint32(p.X*31 + p.Y) },
+		"nil":    nil,
+	} {
+		m := NewConcurrentMapWithHash[point, string](sharding)
+		for i := 0; i < 50; i++ {
+			m.Set(point{i, -i}, strconv.Itoa(i))
+		}
+		if v, ok := m.Get(point{7, -7}); !ok || v != "7" {
+			t.Errorf("%s hash: Get = %q, %v", name, v, ok)
+		}
+		if m.Count() != 50 {
+			t.Errorf("%s hash: Count() = %d", name, m.Count())
+		}
+		used := map[*ConcurrentMapSharedOf[point, string]]bool{}
+		for i := 0; i < 50; i++ {
+			used[m.GetShard(point{i, -i})] = true
+		}
+		if len(used) < 2 {
+			t.Errorf("%s hash: every key went to one shard", name)
+		}
+	}
+}
+
+func TestConcurrentMapParallel(t *testing.T) {
+	m := NewConcurrentMapOf[int]()
+	var wg sync.WaitGroup
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < 200; i++ {
+				key := strconv.Itoa(i % 20)
+				m.Upsert(key, 1, func(exist bool, old, n int) int { return old + n })
+				m.Get(key)
+				if i%50 == 0 {
+					m.Keys()
+				}
+			}
+		}(g)
+	}
+	wg.Wait()
+	total := 0
+	for _, v := range m.Items() {
+		total += v
+	}
+	if total != 8*200 {
+		t.Errorf("total = %d, want %d", total, 8*200)
+	}
+}
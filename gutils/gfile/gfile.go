@@ -1,12 +1,10 @@
 package gfile
 
 import (
-	"encoding/json"
 	"fmt"
 	"io/ioutil"
 	"os"
 	"regexp"
-	"strings"
 	"ackevin.com/jsonq"
 )
 
@@ -36,11 +34,40 @@ func Readconfigfile(filename string) (*jsonq.JSONQuery, error) {
 	fmt.Println(configdatastring)
 	// //格式化 配置文件 config.ini 内的内容格式化 json
 	//将json字符串转为json结构体实例
-	mapData := map[string]interface{}{}                                         //初始化一个map[string]interface{}
-	err = json.NewDecoder(strings.NewReader(configdatastring)).Decode(&mapData) //将json字符串解析到map
-	Configdatajsonq = jsonq.NewQuery(mapData)                                   //创建一个json查询
+	query, err := jsonq.FromString(configdatastring) //将json字符串解析并创建一个json查询
+	if err != nil {
+		return nil, fmt.Errorf("parse config file error:%s", err)
+	}
+	Configdatajsonq = query
 	//返回
-	return Configdatajsonq, err
+	return Configdatajsonq, nil
+}
+
+/*
+ReadconfigfileWithSchema 读取配置文件并按 JSON Schema 文件校验，校验失败返回所有错误（jsonq.SchemaErrors），用于启动时尽早发现配置错误
+例：schema 中 "additionalProperties": false 可发现拼写错误的配置项
+	cfg, err := gfile.ReadconfigfileWithSchema("config.json", "config.schema.json")
+	if err != nil {
+		log.Fatal(err)
+	}
+*/
+func ReadconfigfileWithSchema(filename, schemafilename string) (*jsonq.JSONQuery, error) {
+	schemadata, err := ioutil.ReadFile(schemafilename)
+	if err != nil {
+		return nil, fmt.Errorf("read schema file error:%s", err)
+	}
+	schema, err := jsonq.ParseSchema(schemadata)
+	if err != nil {
+		return nil, fmt.Errorf("parse schema file error:%s", err)
+	}
+	config, err := Readconfigfile(filename)
+	if err != nil {
+		return nil, err
+	}
+	if err := config.Validate(schema); err != nil {
+		return nil, fmt.Errorf("config file %s: %w", filename, err)
+	}
+	return config, nil
 }
 
 /*
@@ -0,0 +1,630 @@
+package ghttp
+
+import (
+	"encoding"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+//MaxBindBodySize Bind 读取 JSON 请求体的最大字节数
+var MaxBindBodySize int64 = 10 << 20
+
+//MaxMultipartMemory 解析 multipart 表单时保存在内存中的最大字节数，超出部分写入临时文件
+var MaxMultipartMemory int64 = 32 << 20
+
+//ErrBodyTooLarge 请求体超出限制
+var ErrBodyTooLarge = errors.New("request body too large")
+
+//BindError 单个字段的绑定或校验错误
+type BindError struct {
+	Field  string //字段名（form 标签，没有则 json 标签，都没有则为结构体字段名），嵌套字段以 . 连接
+	Rule   string //未通过的校验规则 例：required、min=1，类型转换失败时为空
+	Reason string //错误说明
+}
+
+func (e *BindError) Error() string {
+	if e.Field == "" {
+		return e.Reason
+	}
+	return e.Field + ": " + e.Reason
+}
+
+//BindErrors 绑定或校验错误列表 | WriteError 按 400 返回
+type BindErrors []*BindError
+
+func (e BindErrors) Error() string {
+	msgs := make([]string, len(e))
+	for i, err := range e {
+		msgs[i] = err.Error()
+	}
+	return strings.Join(msgs, "; ")
+}
+
+//has 字段是否已有错误（类型转换失败的字段不再校验）
+func (e BindErrors) has(field string) bool {
+	for _, err := range e {
+		if err.Field == field {
+			return true
+		}
+	}
+	return false
+}
+
+/*Bind 按请求类型将参数绑定到结构体并校验
+先绑定url参数，再按 Content-Type 绑定请求体：
+	application/json                   JSON 解码（json 标签）
+	application/x-www-form-urlencoded  表单参数（form 标签）
+	multipart/form-data                表单参数及文件（*multipart.FileHeader、[]*multipart.FileHeader 字段）
+url及表单参数的字段名取 form 标签，没有则取 json 标签，都没有则为结构体字段名；同名参数有多个值时绑定到切片字段
+支持 string、bool、整数、浮点数、time.Duration、实现 encoding.TextUnmarshaler 的类型及其指针和切片，匿名嵌入结构体展开绑定
+
+校验使用 validate 标签，多个规则以逗号分隔 例：`form:"age" validate:"required,min=1,max=150"`
+	required          不能为零值（字符串不能为空、切片不能为空、指针不能为 nil）
+	omitempty         为零值时跳过其他规则
+	min=N、max=N      数值的大小，字符串（按字符数）、切片、map 的长度
+	len=N             字符串（按字符数）、切片、map 的长度
+	oneof=a b c       取值只能是其中之一
+	email             邮箱格式
+	pattern=REGEXP    匹配正则表达式（必须是最后一个规则，正则中可以包含逗号）
+嵌套结构体（及结构体切片）字段递归校验
+返回的错误为 BindErrors，包含所有未通过的字段；请求体超出 MaxBindBodySize 返回 ErrBodyTooLarge
+*/
+func Bind(r *http.Request, v interface{}) error {
+	rv, err := structValue(v)
+	if err != nil {
+		return err
+	}
+	var errs BindErrors
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	switch mediaType {
+	case "application/json":
+		bindValues(r.URL.Query(), rv, "", nil, &errs)
+		if err := decodeJSON(r, v); err != nil {
+			return err
+		}
+	case "application/x-www-form-urlencoded", "multipart/form-data":
+		if err := parseForm(r); err != nil {
+			return err
+		}
+		bindValues(r.Form, rv, "", nil, &errs)
+		if r.MultipartForm != nil {
+			bindFiles(r.MultipartForm.File, rv, "", nil, &errs)
+		}
+	default:
+		bindValues(r.URL.Query(), rv, "", nil, &errs)
+	}
+	validateStruct(rv, "", &errs)
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+//BindQuery 绑定url参数并校验（规则见 Bind）
+func BindQuery(r *http.Request, v interface{}) error {
+	return BindValues(r.URL.Query(), v)
+}
+
+//BindForm 绑定url参数及表单参数并校验（规则见 Bind）
+func BindForm(r *http.Request, v interface{}) error {
+	rv, err := structValue(v)
+	if err != nil {
+		return err
+	}
+	if err := parseForm(r); err != nil {
+		return err
+	}
+	var errs BindErrors
+	bindValues(r.Form, rv, "", nil, &errs)
+	if r.MultipartForm != nil {
+		bindFiles(r.MultipartForm.File, rv, "", nil, &errs)
+	}
+	validateStruct(rv, "", &errs)
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+//BindJSON 解码 JSON 请求体并校验（规则见 Bind）
+func BindJSON(r *http.Request, v interface{}) error {
+	rv, err := structValue(v)
+	if err != nil {
+		return err
+	}
+	if err := decodeJSON(r, v); err != nil {
+		return err
+	}
+	return Validate(rv.Addr().Interface())
+}
+
+//BindValues 将参数绑定到结构体并校验（规则见 Bind）| 可直接传入 r.Form，代替会丢弃重复值的 GetformatURLData
+func BindValues(values url.Values, v interface{}) error {
+	rv, err := structValue(v)
+	if err != nil {
+		return err
+	}
+	var errs BindErrors
+	bindValues(values, rv, "", nil, &errs)
+	validateStruct(rv, "", &errs)
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+//Validate 按 validate 标签校验结构体（规则见 Bind），v 为结构体指针
+func Validate(v interface{}) error {
+	rv, err := structValue(v)
+	if err != nil {
+		return err
+	}
+	var errs BindErrors
+	validateStruct(rv, "", &errs)
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+//structValue v 必须为非 nil 的结构体指针
+func structValue(v interface{}) (reflect.Value, error) {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() || rv.Elem().Kind() != reflect.Struct {
+		return reflect.Value{}, fmt.Errorf("ghttp bind: expected non-nil pointer to struct, got %T", v)
+	}
+	return rv.Elem(), nil
+}
+
+//parseForm 解析表单，multipart 表单同时解析文件
+func parseForm(r *http.Request) error {
+	var err error
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/form-data") {
+		err = r.ParseMultipartForm(MaxMultipartMemory)
+	} else {
+		err = r.ParseForm()
+	}
+	if errors.Is(err, ErrBodyTooLarge) {
+		return ErrBodyTooLarge
+	}
+	if err != nil {
+		return BindErrors{{Reason: "invalid form: " + err.Error()}}
+	}
+	return nil
+}
+
+//decodeJSON 解码 JSON 请求体，空请求体不报错
+func decodeJSON(r *http.Request, v interface{}) error {
+	if r.Body == nil || r.Body == http.NoBody {
+		return nil
+	}
+	body, err := ioutil.ReadAll(io.LimitReader(r.Body, MaxBindBodySize+1))
+	if errors.Is(err, ErrBodyTooLarge) {
+		return ErrBodyTooLarge
+	}
+	if err != nil {
+		return BindErrors{{Reason: "read body: " + err.Error()}}
+	}
+	if int64(len(body)) > MaxBindBodySize {
+		return ErrBodyTooLarge
+	}
+	if len(strings.TrimSpace(string(body))) == 0 {
+		return nil
+	}
+	if err := json.Unmarshal(body, v); err != nil {
+		var typeErr *json.UnmarshalTypeError
+		if errors.As(err, &typeErr) && typeErr.Field != "" {
+			return BindErrors{{Field: typeErr.Field, Reason: fmt.Sprintf("cannot use %s as %s", typeErr.Value, typeErr.Type)}}
+		}
+		return BindErrors{{Reason: "invalid json: " + err.Error()}}
+	}
+	return nil
+}
+
+var (
+	textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+	durationType        = reflect.TypeOf(time.Duration(0))
+	fileHeaderType      = reflect.TypeOf((*multipart.FileHeader)(nil))
+)
+
+//fieldName 字段参数名，返回 false 表示忽略该字段
+func fieldName(f reflect.StructField) (string, bool) {
+	for _, key := range []string{"form", "json"} {
+		tag, ok := f.Tag.Lookup(key)
+		if !ok {
+			continue
+		}
+		name := strings.Split(tag, ",")[0]
+		if name == "-" {
+			return "", false
+		}
+		if name != "" {
+			return name, true
+		}
+	}
+	return f.Name, true
+}
+
+//embedded 是否为需要展开的匿名嵌入结构体
+func embedded(f reflect.StructField) bool {
+	if !f.Anonymous || f.Tag.Get("form") != "" || f.Tag.Get("json") != "" {
+		return false
+	}
+	t := f.Type
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	return t.Kind() == reflect.Struct && !reflect.PtrTo(t).Implements(textUnmarshalerType)
+}
+
+//embeddedValue 取匿名嵌入结构体的值，nil 指针时创建
+//seen 为正在展开的结构体类型，返回 false 表示跳过（未导出的指针，或自引用的类型 例：type Node struct{ *Node }）
+func embeddedValue(fv reflect.Value, f reflect.StructField, seen map[reflect.Type]bool) (reflect.Value, bool) {
+	t := f.Type
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if seen[t] {
+		return reflect.Value{}, false
+	}
+	if f.Type.Kind() == reflect.Ptr {
+		if !f.IsExported() {
+			return reflect.Value{}, false
+		}
+		if fv.IsNil() {
+			fv.Set(reflect.New(t))
+		}
+		fv = fv.Elem()
+	}
+	return fv, true
+}
+
+//enter 记录正在展开的结构体类型，seen 为 nil 时创建
+func enter(seen map[reflect.Type]bool, t reflect.Type) map[reflect.Type]bool {
+	if seen == nil {
+		seen = map[reflect.Type]bool{}
+	}
+	seen[t] = true
+	return seen
+}
+
+//bindValues 绑定参数到结构体字段
+func bindValues(values url.Values, rv reflect.Value, prefix string, seen map[reflect.Type]bool, errs *BindErrors) {
+	rt := rv.Type()
+	seen = enter(seen, rt)
+	defer delete(seen, rt)
+	for i := 0; i < rt.NumField(); i++ {
+		f := rt.Field(i)
+		if embedded(f) {
+			if fv, ok := embeddedValue(rv.Field(i), f, seen); ok {
+				bindValues(values, fv, prefix, seen, errs)
+			}
+			continue
+		}
+		if !f.IsExported() || f.Type == fileHeaderType || f.Type == reflect.SliceOf(fileHeaderType) {
+			continue
+		}
+		name, ok := fieldName(f)
+		if !ok {
+			continue
+		}
+		vals, ok := values[name]
+		if !ok || len(vals) == 0 {
+			continue
+		}
+		fv := rv.Field(i)
+		if fv.Kind() == reflect.Slice && !fv.Addr().Type().Implements(textUnmarshalerType) {
+			slice := reflect.MakeSlice(fv.Type(), len(vals), len(vals))
+			for j, s := range vals {
+				if err := setValue(slice.Index(j), s); err != nil {
+					*errs = append(*errs, &BindError{Field: prefix + name, Reason: err.Error()})
+					break
+				}
+			}
+			fv.Set(slice)
+			continue
+		}
+		if err := setValue(fv, vals[0]); err != nil {
+			*errs = append(*errs, &BindError{Field: prefix + name, Reason: err.Error()})
+		}
+	}
+}
+
+//bindFiles 绑定 multipart 文件到 *multipart.FileHeader、[]*multipart.FileHeader 字段
+func bindFiles(files map[string][]*multipart.FileHeader, rv reflect.Value, prefix string, seen map[reflect.Type]bool, errs *BindErrors) {
+	rt := rv.Type()
+	seen = enter(seen, rt)
+	defer delete(seen, rt)
+	for i := 0; i < rt.NumField(); i++ {
+		f := rt.Field(i)
+		if embedded(f) {
+			if fv, ok := embeddedValue(rv.Field(i), f, seen); ok {
+				bindFiles(files, fv, prefix, seen, errs)
+			}
+			continue
+		}
+		if !f.IsExported() {
+			continue
+		}
+		name, ok := fieldName(f)
+		if !ok || len(files[name]) == 0 {
+			continue
+		}
+		switch f.Type {
+		case fileHeaderType:
+			rv.Field(i).Set(reflect.ValueOf(files[name][0]))
+		case reflect.SliceOf(fileHeaderType):
+			rv.Field(i).Set(reflect.ValueOf(files[name]))
+		}
+	}
+}
+
+//setValue 将字符串转换为字段类型并赋值，空字符串不修改数值类型字段
+func setValue(v reflect.Value, s string) error {
+	if v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			v.Set(reflect.New(v.Type().Elem()))
+		}
+		return setValue(v.Elem(), s)
+	}
+	if v.CanAddr() && v.Addr().Type().Implements(textUnmarshalerType) {
+		return v.Addr().Interface().(encoding.TextUnmarshaler).UnmarshalText([]byte(s))
+	}
+	if v.Kind() == reflect.String {
+		v.SetString(s)
+		return nil
+	}
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return nil
+	}
+	switch v.Kind() {
+	case reflect.Bool:
+		if s == "on" { //html checkbox
+			v.SetBool(true)
+			return nil
+		}
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return fmt.Errorf("invalid bool '%s'", s)
+		}
+		v.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if v.Type() == durationType {
+			d, err := time.ParseDuration(s)
+			if err != nil {
+				return fmt.Errorf("invalid duration '%s'", s)
+			}
+			v.SetInt(int64(d))
+			return nil
+		}
+		n, err := strconv.ParseInt(s, 10, v.Type().Bits())
+		if err != nil {
+			return fmt.Errorf("invalid integer '%s'", s)
+		}
+		v.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(s, 10, v.Type().Bits())
+		if err != nil {
+			return fmt.Errorf("invalid unsigned integer '%s'", s)
+		}
+		v.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		n, err := strconv.ParseFloat(s, v.Type().Bits())
+		if err != nil {
+			return fmt.Errorf("invalid number '%s'", s)
+		}
+		v.SetFloat(n)
+	default:
+		return fmt.Errorf("unsupported field type %s", v.Type())
+	}
+	return nil
+}
+
+var (
+	emailRegexp = regexp.MustCompile(`^[^@\s]+@[^@\s]+\.[^@\s]+$`)
+	patterns    sync.Map //pattern 规则编译后的正则
+)
+
+//validateStruct 按 validate 标签校验结构体字段
+func validateStruct(rv reflect.Value, prefix string, errs *BindErrors) {
+	rt := rv.Type()
+	for i := 0; i < rt.NumField(); i++ {
+		f := rt.Field(i)
+		fv := rv.Field(i)
+		if embedded(f) {
+			if fv.Kind() == reflect.Ptr {
+				if fv.IsNil() {
+					continue
+				}
+				fv = fv.Elem()
+			}
+			validateStruct(fv, prefix, errs)
+			continue
+		}
+		if !f.IsExported() {
+			continue
+		}
+		name, ok := fieldName(f)
+		if !ok {
+			continue
+		}
+		if tag := f.Tag.Get("validate"); tag != "" && tag != "-" && !errs.has(prefix+name) {
+			if err := validateField(fv, tag); err != nil {
+				err.Field = prefix + name
+				*errs = append(*errs, err)
+				continue
+			}
+		}
+		validateNested(fv, prefix+name, errs)
+	}
+}
+
+//validateNested 递归校验结构体及结构体切片字段
+func validateNested(fv reflect.Value, path string, errs *BindErrors) {
+	for fv.Kind() == reflect.Ptr {
+		if fv.IsNil() {
+			return
+		}
+		fv = fv.Elem()
+	}
+	switch fv.Kind() {
+	case reflect.Struct:
+		if fv.Type() != reflect.TypeOf(time.Time{}) {
+			validateStruct(fv, path+".", errs)
+		}
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < fv.Len(); i++ {
+			validateNested(fv.Index(i), fmt.Sprintf("%s[%d]", path, i), errs)
+		}
+	}
+}
+
+//validateField 校验单个字段，返回第一个未通过的规则
+func validateField(fv reflect.Value, tag string) *BindError {
+	for tag != "" {
+		var rule string
+		if strings.HasPrefix(tag, "pattern=") {
+			rule, tag = tag, ""
+		} else if i := strings.Index(tag, ","); i >= 0 {
+			rule, tag = tag[:i], tag[i+1:]
+		} else {
+			rule, tag = tag, ""
+		}
+		rule = strings.TrimSpace(rule)
+		name, param := rule, ""
+		if i := strings.Index(rule, "="); i >= 0 {
+			name, param = rule[:i], rule[i+1:]
+		}
+		zero := isZero(fv)
+		switch name {
+		case "":
+		case "omitempty":
+			if zero {
+				return nil
+			}
+		case "required":
+			if zero {
+				return &BindError{Rule: rule, Reason: "is required"}
+			}
+		case "min", "max", "len":
+			if reason := checkSize(fv, name, param); reason != "" {
+				return &BindError{Rule: rule, Reason: reason}
+			}
+		case "oneof":
+			s := fmt.Sprint(indirect(fv).Interface())
+			found := false
+			for _, option := range strings.Fields(param) {
+				if s == option {
+					found = true
+					break
+				}
+			}
+			if !found {
+				return &BindError{Rule: rule, Reason: fmt.Sprintf("must be one of [%s]", param)}
+			}
+		case "email":
+			if !emailRegexp.MatchString(fmt.Sprint(indirect(fv).Interface())) {
+				return &BindError{Rule: rule, Reason: "must be a valid email address"}
+			}
+		case "pattern":
+			re, err := compilePattern(param)
+			if err != nil {
+				return &BindError{Rule: rule, Reason: "invalid pattern: " + err.Error()}
+			}
+			if !re.MatchString(fmt.Sprint(indirect(fv).Interface())) {
+				return &BindError{Rule: rule, Reason: fmt.Sprintf("must match pattern '%s'", param)}
+			}
+		default:
+			return &BindError{Rule: rule, Reason: fmt.Sprintf("unknown validate rule '%s'", name)}
+		}
+	}
+	return nil
+}
+
+//compilePattern 编译并缓存 pattern 规则的正则
+func compilePattern(expr string) (*regexp.Regexp, error) {
+	if re, ok := patterns.Load(expr); ok {
+		return re.(*regexp.Regexp), nil
+	}
+	re, err := regexp.Compile(expr)
+	if err != nil {
+		return nil, err
+	}
+	patterns.Store(expr, re)
+	return re, nil
+}
+
+//indirect 取指针指向的值，nil 指针返回对应类型的零值
+func indirect(fv reflect.Value) reflect.Value {
+	for fv.Kind() == reflect.Ptr {
+		if fv.IsNil() {
+			return reflect.Zero(fv.Type().Elem())
+		}
+		fv = fv.Elem()
+	}
+	return fv
+}
+
+//isZero 是否为零值，空切片、空 map 视为零值
+func isZero(fv reflect.Value) bool {
+	switch fv.Kind() {
+	case reflect.Slice, reflect.Map:
+		return fv.Len() == 0
+	}
+	return fv.IsZero()
+}
+
+//checkSize 校验 min、max、len 规则，通过返回空字符串
+func checkSize(fv reflect.Value, rule, param string) string {
+	limit, err := strconv.ParseFloat(param, 64)
+	if err != nil {
+		return fmt.Sprintf("invalid %s parameter '%s'", rule, param)
+	}
+	fv = indirect(fv)
+	var n float64
+	unit := ""
+	switch fv.Kind() {
+	case reflect.String:
+		n, unit = float64(utf8.RuneCountInString(fv.String())), " characters"
+	case reflect.Slice, reflect.Map, reflect.Array:
+		n, unit = float64(fv.Len()), " items"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n = float64(fv.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n = float64(fv.Uint())
+	case reflect.Float32, reflect.Float64:
+		n = fv.Float()
+	default:
+		return fmt.Sprintf("rule %s not supported for %s", rule, fv.Type())
+	}
+	switch {
+	case rule == "min" && n < limit:
+		if unit != "" {
+			return fmt.Sprintf("must have at least %s%s", param, unit)
+		}
+		return fmt.Sprintf("must be >= %s", param)
+	case rule == "max" && n > limit:
+		if unit != "" {
+			return fmt.Sprintf("must have at most %s%s", param, unit)
+		}
+		return fmt.Sprintf("must be <= %s", param)
+	case rule == "len" && n != limit:
+		return fmt.Sprintf("must have exactly %s%s", param, unit)
+	}
+	return ""
+}
@@ -0,0 +1,133 @@
+package ghttp
+
+import (
+	"bytes"
+	"errors"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+type BindPage struct {
+	Page int `form:"page" validate:"min=1"`
+}
+
+type BindUpload struct {
+	File *multipart.FileHeader `form:"file" validate:"required"`
+}
+
+type BindNode struct {
+	*BindNode
+	Name string `form:"name"`
+}
+
+type bindUser struct {
+	*BindPage
+	*BindUpload
+	Name    string        `json:"name" validate:"required,max=5"`
+	Tags    []string      `form:"tag"`
+	Age     *int          `form:"age" validate:"omitempty,min=1"`
+	Timeout time.Duration `form:"timeout"`
+	Email   string        `form:"email" validate:"omitempty,email"`
+	Role    string        `form:"role" validate:"oneof=admin user"`
+	Ignored string        `form:"-"`
+}
+
+func TestBindValues(t *testing.T) {
+	var u bindUser
+	values := url.Values{"name": {"bob"}, "tag": {"a", "b"}, "age": {"20"}, "timeout": {"2s"}, "page": {"3"}, "role": {"user"}, "Ignored": {"x"}}
+	err := BindValues(values, &u)
+	var errs BindErrors
+	//File 来自嵌入的 *BindUpload，required 未通过
+	if !errors.As(err, &errs) || len(errs) != 1 || errs[0].Field != "file" || errs[0].Rule != "required" {
+		t.Fatalf("err = %v", err)
+	}
+	if u.Name != "bob" || len(u.Tags) != 2 || *u.Age != 20 || u.Timeout != 2*time.Second || u.Page != 3 || u.Ignored != "" {
+		t.Fatalf("bound %+v", u)
+	}
+}
+
+func TestBindValuesErrors(t *testing.T) {
+	var u bindUser
+	err := BindValues(url.Values{"name": {"toolong"}, "age": {"x"}, "email": {"bad"}, "role": {"root"}, "page": {"0"}}, &u)
+	var errs BindErrors
+	if !errors.As(err, &errs) {
+		t.Fatalf("err = %v", err)
+	}
+	got := map[string]string{}
+	for _, e := range errs {
+		got[e.Field] = e.Rule
+	}
+	want := map[string]string{"name": "max=5", "age": "", "email": "email", "role": "oneof=admin user", "page": "min=1", "file": "required"}
+	for field, rule := range want {
+		if r, ok := got[field]; !ok || r != rule {
+			t.Errorf("field %s rule %q (present %v), want %q", field, r, ok, rule)
+		}
+	}
+}
+
+func TestBindSelfReference(t *testing.T) {
+	var n BindNode
+	if err := BindValues(url.Values{"name": {"root"}}, &n); err != nil {
+		t.Fatal(err)
+	}
+	if n.Name != "root" || n.BindNode != nil {
+		t.Fatalf("bound %+v", n)
+	}
+}
+
+func TestBindMultipart(t *testing.T) {
+	var buf bytes.Buffer
+	w := multipart.NewWriter(&buf)
+	w.WriteField("name", "amy")
+	w.WriteField("role", "admin")
+	fw, _ := w.CreateFormFile("file", "a.txt")
+	fw.Write([]byte("hello"))
+	w.Close()
+	r := httptest.NewRequest("POST", "/?page=2", &buf)
+	r.Header.Set("Content-Type", w.FormDataContentType())
+
+	var u bindUser
+	if err := Bind(r, &u); err != nil {
+		t.Fatal(err)
+	}
+	if u.File == nil || u.File.Filename != "a.txt" || u.Name != "amy" || u.Page != 2 {
+		t.Fatalf("bound %+v", u)
+	}
+}
+
+func TestBindJSON(t *testing.T) {
+	r := httptest.NewRequest("POST", "/?page=2", strings.NewReader(`{"name":"amy"}`))
+	r.Header.Set("Content-Type", "application/json")
+	var v struct {
+		Name string `json:"name" validate:"required"`
+		Page int    `form:"page"`
+	}
+	if err := Bind(r, &v); err != nil || v.Name != "amy" || v.Page != 2 {
+		t.Fatalf("bound %+v err %v", v, err)
+	}
+
+	r = httptest.NewRequest("POST", "/", strings.NewReader(`{"name":1}`))
+	r.Header.Set("Content-Type", "application/json")
+	var errs BindErrors
+	if err := BindJSON(r, &v); !errors.As(err, &errs) || errs[0].Field != "name" {
+		t.Fatalf("err = %v", err)
+	}
+
+	defer func(n int64) { MaxBindBodySize = n }(MaxBindBodySize)
+	MaxBindBodySize = 4
+	r = httptest.NewRequest("POST", "/", strings.NewReader(`{"name":"amy"}`))
+	if err := BindJSON(r, &v); !errors.Is(err, ErrBodyTooLarge) {
+		t.Fatalf("err = %v, want ErrBodyTooLarge", err)
+	}
+	if err := BindJSON(httptest.NewRequest("POST", "/", http.NoBody), &v); err != nil {
+		t.Fatalf("empty body err = %v", err)
+	}
+	if err := Validate(v); err == nil {
+		t.Fatal("non-pointer accepted")
+	}
+}
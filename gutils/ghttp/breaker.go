@@ -0,0 +1,259 @@
+package ghttp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"ackevin.com/glog"
+	gmap "ackevin.com/gutils/gcontainer"
+)
+
+//ErrCircuitOpen 熔断器已打开，请求未发出直接返回
+var ErrCircuitOpen = errors.New("circuit breaker is open")
+
+//BreakerState 熔断器状态
+type BreakerState int
+
+//熔断器状态
+const (
+	StateClosed   BreakerState = iota //关闭：请求正常发出
+	StateOpen                         //打开：请求直接返回 ErrCircuitOpen
+	StateHalfOpen                     //半开：冷却结束，放行少量探测请求
+)
+
+func (s BreakerState) String() string {
+	switch s {
+	case StateClosed:
+		return "closed"
+	case StateOpen:
+		return "open"
+	case StateHalfOpen:
+		return "half-open"
+	}
+	return "unknown"
+}
+
+//BreakerOptions 熔断器配置
+type BreakerOptions struct {
+	Window              time.Duration                             //失败率统计窗口，默认 60s
+	MinRequests         int                                       //窗口内请求数达到该值才计算失败率，默认 10
+	FailureRate         float64                                   //失败率阈值（0~1），达到即熔断，默认 0.5
+	ConsecutiveFailures int                                       //连续失败次数阈值，>0 时达到即熔断
+	Cooldown            time.Duration                             //熔断持续时间，之后进入半开状态，默认 30s
+	HalfOpenRequests    int                                       //半开状态放行的探测请求数，全部成功则关闭熔断，默认 1
+	IsFailure           func(resp *http.Response, err error) bool //判断请求是否失败，默认网络错误（非调用方取消）或 5xx 为失败
+	OnStateChange       func(host string, from, to BreakerState)  //状态变化回调（已写入 glog，可用于上报监控），在释放锁后调用，可在回调中调用 State
+}
+
+//CircuitBreaker 按主机熔断器
+type CircuitBreaker struct {
+	opts  BreakerOptions
+	hosts gmap.ConcurrentMapOf[string, *hostBreaker]
+}
+
+//hostBreaker 单个主机的熔断状态
+type hostBreaker struct {
+	mu          sync.Mutex
+	state       BreakerState
+	openedAt    time.Time //打开时间
+	windowStart time.Time //统计窗口开始时间
+	requests    int       //窗口内请求数
+	failures    int       //窗口内失败数
+	consecutive int       //连续失败数
+	probing     int       //半开状态已放行的探测请求数
+	succeeded   int       //半开状态探测成功数
+}
+
+//NewCircuitBreaker 创建熔断器 opts 为空使用默认配置
+func NewCircuitBreaker(opts *BreakerOptions) *CircuitBreaker {
+	var o BreakerOptions
+	if opts != nil {
+		o = *opts
+	}
+	if o.Window <= 0 {
+		o.Window = 60 * time.Second
+	}
+	if o.MinRequests <= 0 {
+		o.MinRequests = 10
+	}
+	if o.FailureRate <= 0 {
+		o.FailureRate = 0.5
+	}
+	if o.Cooldown <= 0 {
+		o.Cooldown = 30 * time.Second
+	}
+	if o.HalfOpenRequests <= 0 {
+		o.HalfOpenRequests = 1
+	}
+	if o.IsFailure == nil {
+		o.IsFailure = defaultIsFailure
+	}
+	return &CircuitBreaker{opts: o, hosts: gmap.NewConcurrentMapOf[*hostBreaker]()}
+}
+
+//defaultIsFailure 网络错误或 5xx 为失败
+func defaultIsFailure(resp *http.Response, err error) bool {
+	if err != nil {
+		return true
+	}
+	return resp.StatusCode >= 500
+}
+
+//breaker 获取主机熔断状态，不存在则创建 | 用 Upsert 一次完成读取或插入，避免与 Reset 并发时拿到 nil
+func (cb *CircuitBreaker) breaker(host string) *hostBreaker {
+	if b, ok := cb.hosts.Get(host); ok {
+		return b
+	}
+	return cb.hosts.Upsert(host, nil, func(exist bool, old *hostBreaker, _ *hostBreaker) *hostBreaker {
+		if exist {
+			return old
+		}
+		return &hostBreaker{windowStart: time.Now()}
+	})
+}
+
+//State 主机当前熔断状态
+func (cb *CircuitBreaker) State(host string) BreakerState {
+	hb, ok := cb.hosts.Get(host)
+	if !ok {
+		return StateClosed
+	}
+	hb.mu.Lock()
+	defer hb.mu.Unlock()
+	if hb.state == StateOpen && time.Since(hb.openedAt) >= cb.opts.Cooldown {
+		return StateHalfOpen
+	}
+	return hb.state
+}
+
+//Reset 重置主机熔断状态为关闭
+func (cb *CircuitBreaker) Reset(host string) {
+	cb.hosts.Remove(host)
+}
+
+//allow 判断请求是否放行
+func (cb *CircuitBreaker) allow(host string, b *hostBreaker) error {
+	var t *transition
+	defer func() { cb.notify(host, t) }()
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.state == StateOpen {
+		if time.Since(b.openedAt) < cb.opts.Cooldown {
+			return fmt.Errorf("%w: %s", ErrCircuitOpen, host)
+		}
+		t = cb.setState(host, b, StateHalfOpen)
+	}
+	if b.state == StateHalfOpen {
+		if b.probing >= cb.opts.HalfOpenRequests {
+			return fmt.Errorf("%w: %s", ErrCircuitOpen, host)
+		}
+		b.probing++
+	}
+	return nil
+}
+
+//record 记录请求结果
+func (cb *CircuitBreaker) record(host string, b *hostBreaker, failed bool) {
+	var t *transition
+	defer func() { cb.notify(host, t) }()
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	switch b.state {
+	case StateHalfOpen:
+		if failed {
+			t = cb.setState(host, b, StateOpen)
+			return
+		}
+		if b.succeeded++; b.succeeded >= cb.opts.HalfOpenRequests {
+			t = cb.setState(host, b, StateClosed)
+		}
+	case StateClosed:
+		now := time.Now()
+		if now.Sub(b.windowStart) >= cb.opts.Window {
+			b.windowStart, b.requests, b.failures = now, 0, 0
+		}
+		b.requests++
+		if !failed {
+			b.consecutive = 0
+			return
+		}
+		b.failures++
+		b.consecutive++
+		if (cb.opts.ConsecutiveFailures > 0 && b.consecutive >= cb.opts.ConsecutiveFailures) ||
+			(b.requests >= cb.opts.MinRequests && float64(b.failures)/float64(b.requests) >= cb.opts.FailureRate) {
+			t = cb.setState(host, b, StateOpen)
+		}
+	}
+}
+
+//transition 状态变化及切换时的窗口统计（用于日志）
+type transition struct {
+	from, to                        BreakerState
+	requests, failures, consecutive int
+}
+
+//setState 切换状态，调用方需持有锁，释放锁后将返回值传给 notify
+func (cb *CircuitBreaker) setState(host string, b *hostBreaker, state BreakerState) *transition {
+	t := &transition{from: b.state, to: state, requests: b.requests, failures: b.failures, consecutive: b.consecutive}
+	b.state = state
+	b.probing, b.succeeded = 0, 0
+	switch state {
+	case StateOpen:
+		b.openedAt = time.Now()
+	case StateClosed:
+		b.windowStart, b.requests, b.failures, b.consecutive = time.Now(), 0, 0, 0
+	}
+	return t
+}
+
+/*notify 记录状态变化日志并调用回调，调用方不能持有锁
+glog 的http日志可能经过本熔断器，回调中也可能调用 State，均需在释放锁后进行
+*/
+func (cb *CircuitBreaker) notify(host string, t *transition) {
+	if t == nil {
+		return
+	}
+	if t.to == StateOpen {
+		glog.Debuger("302", "ghttp 熔断器 %s %s -> %s 窗口请求:%d 失败:%d 连续失败:%d", host, t.from, t.to, t.requests, t.failures, t.consecutive)
+	} else {
+		glog.Printfer("302", "ghttp 熔断器 %s %s -> %s", host, t.from, t.to)
+	}
+	if cb.opts.OnStateChange != nil {
+		cb.opts.OnStateChange(host, t.from, t.to)
+	}
+}
+
+/*CircuitBreakerMiddleware 按主机熔断的中间件 | 熔断打开时直接返回 ErrCircuitOpen（可用 errors.Is 判断），不等待超时
+调用方主动取消（context.Canceled）的请求不计入失败，超时计入失败
+glog 的http日志（HTTPMsgURL）可通过 glog.LogHandler.HTTPTransport 经过熔断器，需在 StartLogHandler 前设置：
+	logClient := ghttp.NewClient(2)
+	logClient.Use(ghttp.CircuitBreakerMiddleware(ghttp.NewCircuitBreaker(nil)))
+	glog.LogHandler.HTTPTransport = logClient
+*/
+func CircuitBreakerMiddleware(cb *CircuitBreaker) Middleware {
+	return func(next http.RoundTripper) http.RoundTripper {
+		return RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			host := req.URL.Host
+			b := cb.breaker(host)
+			if err := cb.allow(host, b); err != nil {
+				return nil, err
+			}
+			resp, err := next.RoundTrip(req)
+			if err != nil && errors.Is(req.Context().Err(), context.Canceled) {
+				//调用方取消（超时不算取消），释放半开探测名额
+				b.mu.Lock()
+				if b.state == StateHalfOpen && b.probing > 0 {
+					b.probing--
+				}
+				b.mu.Unlock()
+				return resp, err
+			}
+			cb.record(host, b, cb.opts.IsFailure(resp, err))
+			return resp, err
+		})
+	}
+}
@@ -0,0 +1,113 @@
+package ghttp
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestCircuitBreaker(t *testing.T) {
+	fail := true
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if fail {
+			w.WriteHeader(http.StatusBadGateway)
+		}
+	}))
+	defer srv.Close()
+	host := strings.TrimPrefix(srv.URL, "http://")
+
+	var changes []string
+	var cb *CircuitBreaker
+	cb = NewCircuitBreaker(&BreakerOptions{
+		ConsecutiveFailures: 2,
+		Cooldown:            50 * time.Millisecond,
+		OnStateChange: func(h string, from, to BreakerState) {
+			//回调中读取状态不能死锁
+			changes = append(changes, from.String()+">"+to.String()+"="+cb.State(h).String())
+		},
+	})
+	c := NewClient(5)
+	c.Use(CircuitBreakerMiddleware(cb))
+	get := func() error {
+		_, err := c.BaseRequest(context.Background(), srv.URL, "", "", "GET", 5)
+		return err
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		get()
+		get()
+		if err := get(); !errors.Is(err, ErrCircuitOpen) {
+			t.Errorf("third request err = %v, want ErrCircuitOpen", err)
+		}
+		if cb.State(host) != StateOpen {
+			t.Errorf("state = %s, want open", cb.State(host))
+		}
+		time.Sleep(60 * time.Millisecond)
+		fail = false
+		if err := get(); err != nil {
+			t.Errorf("probe err = %v", err)
+		}
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("deadlock: OnStateChange called with the breaker locked")
+	}
+	want := "closed>open=open open>half-open=half-open half-open>closed=closed"
+	if got := strings.Join(changes, " "); got != want {
+		t.Fatalf("changes = %q, want %q", got, want)
+	}
+	if cb.State(host) != StateClosed {
+		t.Fatalf("state = %s, want closed", cb.State(host))
+	}
+}
+
+func TestCircuitBreakerFailureRate(t *testing.T) {
+	cb := NewCircuitBreaker(&BreakerOptions{MinRequests: 4, FailureRate: 0.5})
+	b := cb.breaker("api.example.com")
+	for _, failed := range []bool{false, true, false} {
+		cb.record("api.example.com", b, failed)
+	}
+	if cb.State("api.example.com") != StateClosed {
+		t.Fatal("opened before MinRequests")
+	}
+	cb.record("api.example.com", b, true)
+	if cb.State("api.example.com") != StateOpen {
+		t.Fatal("not opened at 50% failures")
+	}
+	cb.Reset("api.example.com")
+	if cb.State("api.example.com") != StateClosed {
+		t.Fatal("Reset did not close")
+	}
+}
+
+func TestCircuitBreakerConcurrentReset(t *testing.T) {
+	cb := NewCircuitBreaker(&BreakerOptions{ConsecutiveFailures: 3})
+	var wg sync.WaitGroup
+	for g := 0; g < 4; g++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 500; i++ {
+				b := cb.breaker("h")
+				if cb.allow("h", b) == nil {
+					cb.record("h", b, i%2 == 0)
+				}
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 200; i++ {
+				cb.Reset("h")
+			}
+		}()
+	}
+	wg.Wait()
+}
@@ -0,0 +1,362 @@
+package ghttp
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	gmap "ackevin.com/gutils/gcontainer"
+)
+
+//CacheHeader 响应中标记缓存命中情况的报文头 HIT 命中 | REVALIDATED 304 校验后使用缓存 | MISS 未命中
+const CacheHeader = "X-Ghttp-Cache"
+
+//CachedResponse 缓存的响应
+type CachedResponse struct {
+	StatusCode int
+	Header     http.Header
+	Body       []byte
+	Vary       map[string]string //Vary 报文头对应的请求头值
+	StoredAt   time.Time         //缓存时间
+	Expires    time.Time         //过期时间，过期后有 ETag/Last-Modified 时发送条件请求校验
+	lastAccess int64             //最后访问时间（UnixNano），用于淘汰
+}
+
+//size 缓存占用字节数（估算）
+func (c *CachedResponse) size() int64 {
+	n := int64(len(c.Body))
+	for k, vs := range c.Header {
+		n += int64(len(k))
+		for _, v := range vs {
+			n += int64(len(v))
+		}
+	}
+	return n
+}
+
+//CacheStore 缓存存储
+type CacheStore interface {
+	Get(key string) (*CachedResponse, bool)
+	Set(key string, entry *CachedResponse)
+	Delete(key string)
+}
+
+//MemoryCache 内存缓存（基于 gmap.ConcurrentMapOf）| 超出条数或字节数限制时淘汰最久未访问的缓存
+type MemoryCache struct {
+	items      gmap.ConcurrentMapOf[string, *CachedResponse]
+	maxEntries int   //最大条数，<=0 不限制
+	maxBytes   int64 //最大字节数，<=0 不限制
+	bytes      int64 //当前字节数
+	mu         sync.Mutex
+}
+
+//NewMemoryCache 创建内存缓存 maxEntries 最大条数，maxBytes 最大字节数，<=0 不限制
+func NewMemoryCache(maxEntries int, maxBytes int64) *MemoryCache {
+	return &MemoryCache{items: gmap.NewConcurrentMapOf[*CachedResponse](), maxEntries: maxEntries, maxBytes: maxBytes}
+}
+
+//Get 读取缓存
+func (m *MemoryCache) Get(key string) (*CachedResponse, bool) {
+	entry, ok := m.items.Get(key)
+	if !ok {
+		return nil, false
+	}
+	atomic.StoreInt64(&entry.lastAccess, time.Now().UnixNano())
+	return entry, true
+}
+
+//Set 写入缓存，单条超过 maxBytes 的缓存不写入
+func (m *MemoryCache) Set(key string, entry *CachedResponse) {
+	size := entry.size()
+	if m.maxBytes > 0 && size > m.maxBytes {
+		return
+	}
+	atomic.StoreInt64(&entry.lastAccess, time.Now().UnixNano())
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if old, ok := m.items.Pop(key); ok {
+		m.bytes -= old.size()
+	}
+	for (m.maxEntries > 0 && m.items.Count() >= m.maxEntries) || (m.maxBytes > 0 && m.bytes+size > m.maxBytes) {
+		if !m.evict() {
+			break
+		}
+	}
+	m.items.Set(key, entry)
+	m.bytes += size
+}
+
+//Delete 删除缓存
+func (m *MemoryCache) Delete(key string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if old, ok := m.items.Pop(key); ok {
+		m.bytes -= old.size()
+	}
+}
+
+//Len 缓存条数
+func (m *MemoryCache) Len() int {
+	return m.items.Count()
+}
+
+//evict 淘汰最久未访问的一条缓存，调用方需持有锁
+func (m *MemoryCache) evict() bool {
+	var oldestKey string
+	oldest := int64(-1)
+	m.items.IterCb(func(key string, entry *CachedResponse) {
+		if t := atomic.LoadInt64(&entry.lastAccess); oldest < 0 || t < oldest {
+			oldest, oldestKey = t, key
+		}
+	})
+	if oldest < 0 {
+		return false
+	}
+	if old, ok := m.items.Pop(oldestKey); ok {
+		m.bytes -= old.size()
+	}
+	return true
+}
+
+//CacheOptions 缓存中间件配置
+type CacheOptions struct {
+	MaxBodySize int64         //可缓存的最大响应字节数，默认 1MB
+	DefaultTTL  time.Duration //响应未指定 Cache-Control/Expires 时的缓存时间，默认 0（只在有 ETag/Last-Modified 时缓存并每次校验）
+	//Private 缓存只供单个用户使用（客户端只用一份身份信息）时设置为 true
+	//默认按共享缓存处理：不缓存 Cache-Control: private 的响应，带 Authorization、Cookie 的请求只在响应为 public 或有 s-maxage 时缓存，s-maxage 优先于 max-age
+	Private bool
+}
+
+type cacheBypassKey struct{}
+
+//WithCacheBypass 标记请求跳过缓存（直接请求源站并更新缓存）
+//未使用上下文的调用（如 SendGET）可在报文头传入 map[string]string{"Cache-Control": "no-cache"} 达到同样效果
+func WithCacheBypass(ctx context.Context) context.Context {
+	return context.WithValue(ctx, cacheBypassKey{}, true)
+}
+
+//CacheMiddleware GET 请求缓存中间件 | 遵循 Cache-Control（max-age、s-maxage、no-cache、no-store、private、public）、Expires，
+//缓存过期后使用 ETag（If-None-Match）、Last-Modified（If-Modified-Since）条件请求校验
+//同一客户端使用多个 token（如 HTTPRequestWithToken）时，带身份信息的请求的响应默认不缓存，见 CacheOptions.Private
+func CacheMiddleware(store CacheStore, opts *CacheOptions) Middleware {
+	var o CacheOptions
+	if opts != nil {
+		o = *opts
+	}
+	if o.MaxBodySize <= 0 {
+		o.MaxBodySize = 1 << 20
+	}
+	return func(next http.RoundTripper) http.RoundTripper {
+		return RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			if req.Method != "GET" || req.Header.Get("Range") != "" {
+				return next.RoundTrip(req)
+			}
+			reqCC := parseCacheControl(req.Header.Values("Cache-Control"))
+			_, noStore := reqCC["no-store"]
+			if noStore {
+				return next.RoundTrip(req)
+			}
+			key := req.URL.String()
+			bypass, _ := req.Context().Value(cacheBypassKey{}).(bool)
+			if _, ok := reqCC["no-cache"]; ok || req.Header.Get("Pragma") == "no-cache" {
+				bypass = true
+			}
+
+			var cached *CachedResponse
+			if entry, ok := store.Get(key); ok && entry.varyMatches(req) && !bypass {
+				cached = entry
+				if time.Now().Before(entry.Expires) {
+					return entry.response(req, "HIT"), nil
+				}
+			}
+
+			outReq := req
+			if cached != nil {
+				etag, lastModified := cached.Header.Get("ETag"), cached.Header.Get("Last-Modified")
+				if etag != "" || lastModified != "" {
+					outReq = cloneRequest(req)
+					if etag != "" {
+						outReq.Header.Set("If-None-Match", etag)
+					}
+					if lastModified != "" {
+						outReq.Header.Set("If-Modified-Since", lastModified)
+					}
+				}
+			}
+			resp, err := next.RoundTrip(outReq)
+			if err != nil {
+				return resp, err
+			}
+			if cached != nil && resp.StatusCode == http.StatusNotModified {
+				io.Copy(ioutil.Discard, resp.Body)
+				resp.Body.Close()
+				//合并 304 返回的新报文头并刷新过期时间 | 逐字段复制，lastAccess 需原子读取
+				entry := &CachedResponse{
+					StatusCode: cached.StatusCode,
+					Header:     cached.Header.Clone(),
+					Body:       cached.Body,
+					Vary:       cached.Vary,
+					StoredAt:   time.Now(),
+					Expires:    cached.Expires,
+					lastAccess: atomic.LoadInt64(&cached.lastAccess),
+				}
+				for k, vs := range resp.Header {
+					entry.Header[k] = vs
+				}
+				if expires, ok := freshUntil(entry.Header, entry.StoredAt, o.DefaultTTL, !o.Private); ok {
+					entry.Expires = expires
+				}
+				store.Set(key, entry)
+				return entry.response(req, "REVALIDATED"), nil
+			}
+			return storeResponse(store, key, req, resp, &o), nil
+		})
+	}
+}
+
+//storeResponse 可缓存时读取响应内容写入缓存，返回可重新读取的响应
+func storeResponse(store CacheStore, key string, req *http.Request, resp *http.Response, o *CacheOptions) *http.Response {
+	resp.Header.Set(CacheHeader, "MISS")
+	if resp.StatusCode != http.StatusOK {
+		return resp
+	}
+	respCC := parseCacheControl(resp.Header.Values("Cache-Control"))
+	if _, ok := respCC["no-store"]; ok {
+		return resp
+	}
+	if !o.Private {
+		//共享缓存：不缓存私有响应，带身份信息的请求只缓存明确允许共享的响应
+		if _, ok := respCC["private"]; ok {
+			return resp
+		}
+		_, public := respCC["public"]
+		_, sMaxAge := respCC["s-maxage"]
+		if credentialed(req) && !public && !sMaxAge {
+			return resp
+		}
+	}
+	if resp.Header.Get("Vary") == "*" || (resp.ContentLength > o.MaxBodySize) {
+		return resp
+	}
+	now := time.Now()
+	expires, fresh := freshUntil(resp.Header, now, o.DefaultTTL, !o.Private)
+	if !fresh && resp.Header.Get("ETag") == "" && resp.Header.Get("Last-Modified") == "" {
+		return resp
+	}
+	//读取不超过 MaxBodySize 的内容，超出则不缓存
+	body, err := ioutil.ReadAll(io.LimitReader(resp.Body, o.MaxBodySize+1))
+	if err != nil || int64(len(body)) > o.MaxBodySize {
+		resp.Body = readCloser{io.MultiReader(bytes.NewReader(body), resp.Body), resp.Body}
+		return resp
+	}
+	resp.Body.Close()
+	resp.Body = ioutil.NopCloser(bytes.NewReader(body))
+	entry := &CachedResponse{
+		StatusCode: resp.StatusCode,
+		Header:     resp.Header.Clone(),
+		Body:       body,
+		StoredAt:   now,
+		Expires:    expires,
+	}
+	entry.Header.Del(CacheHeader)
+	if vary := resp.Header.Get("Vary"); vary != "" {
+		entry.Vary = map[string]string{}
+		for _, h := range strings.Split(vary, ",") {
+			h = http.CanonicalHeaderKey(strings.TrimSpace(h))
+			entry.Vary[h] = req.Header.Get(h)
+		}
+	}
+	store.Set(key, entry)
+	return resp
+}
+
+//credentialed 请求是否带有身份信息（Authorization、Cookie）
+func credentialed(req *http.Request) bool {
+	return req.Header.Get("Authorization") != "" || req.Header.Get("Cookie") != ""
+}
+
+//varyMatches 请求的 Vary 报文头是否与缓存一致
+func (c *CachedResponse) varyMatches(req *http.Request) bool {
+	for h, v := range c.Vary {
+		if req.Header.Get(h) != v {
+			return false
+		}
+	}
+	return true
+}
+
+//response 由缓存构造响应
+func (c *CachedResponse) response(req *http.Request, status string) *http.Response {
+	header := c.Header.Clone()
+	header.Set(CacheHeader, status)
+	return &http.Response{
+		Status:        strconv.Itoa(c.StatusCode) + " " + http.StatusText(c.StatusCode),
+		StatusCode:    c.StatusCode,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          ioutil.NopCloser(bytes.NewReader(c.Body)),
+		ContentLength: int64(len(c.Body)),
+		Request:       req,
+	}
+}
+
+//freshUntil 按 Cache-Control、Expires 计算过期时间，返回是否有有效的缓存时间 | shared 共享缓存时 s-maxage 优先
+func freshUntil(header http.Header, now time.Time, defaultTTL time.Duration, shared bool) (time.Time, bool) {
+	cc := parseCacheControl(header.Values("Cache-Control"))
+	if _, ok := cc["no-cache"]; ok {
+		return now, false
+	}
+	v, ok := cc["max-age"]
+	if sv, sok := cc["s-maxage"]; sok && shared {
+		v, ok = sv, true
+	}
+	if ok {
+		if sec, err := strconv.Atoi(v); err == nil && sec > 0 {
+			return now.Add(time.Duration(sec) * time.Second), true
+		}
+		return now, false
+	}
+	if v := header.Get("Expires"); v != "" {
+		expires, err := http.ParseTime(v)
+		if err != nil {
+			return now, false
+		}
+		//按服务器时间计算剩余有效期
+		date, err := http.ParseTime(header.Get("Date"))
+		if err != nil {
+			date = now
+		}
+		if ttl := expires.Sub(date); ttl > 0 {
+			return now.Add(ttl), true
+		}
+		return now, false
+	}
+	if defaultTTL > 0 {
+		return now.Add(defaultTTL), true
+	}
+	return now, false
+}
+
+//parseCacheControl 解析 Cache-Control 报文头
+func parseCacheControl(values []string) map[string]string {
+	cc := map[string]string{}
+	for _, item := range splitList(values) {
+		kv := strings.SplitN(item, "=", 2)
+		key := strings.ToLower(strings.TrimSpace(kv[0]))
+		if len(kv) == 2 {
+			cc[key] = strings.Trim(strings.TrimSpace(kv[1]), `"`)
+		} else {
+			cc[key] = ""
+		}
+	}
+	return cc
+}
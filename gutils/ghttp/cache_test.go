@@ -0,0 +1,176 @@
+package ghttp
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+//cacheServer 返回按 Authorization 区分内容的测试服务器及请求计数
+func cacheServer(cacheControl string) (*httptest.Server, *int64) {
+	var hits int64
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt64(&hits, 1)
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("Cache-Control", cacheControl)
+		w.Header().Set("ETag", `"v1"`)
+		fmt.Fprintf(w, "user:%s", r.Header.Get("Authorization"))
+	}))
+	return srv, &hits
+}
+
+func cachedGet(t *testing.T, c *Client, url string, headers map[string]string) (string, string) {
+	req, _ := http.NewRequest("GET", url, nil)
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	resp, err := c.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	var body [64]byte
+	n, _ := resp.Body.Read(body[:])
+	return string(body[:n]), resp.Header.Get(CacheHeader)
+}
+
+func TestCacheMiddleware(t *testing.T) {
+	srv, hits := cacheServer("max-age=60")
+	defer srv.Close()
+	c := NewClient(5)
+	c.Use(CacheMiddleware(NewMemoryCache(10, 0), nil))
+
+	if _, status := cachedGet(t, c, srv.URL, nil); status != "MISS" {
+		t.Fatalf("first request %s, want MISS", status)
+	}
+	if _, status := cachedGet(t, c, srv.URL, nil); status != "HIT" || *hits != 1 {
+		t.Fatalf("second request %s after %d hits, want HIT", status, *hits)
+	}
+	if _, status := cachedGet(t, c, srv.URL, map[string]string{"Cache-Control": "no-cache"}); status != "MISS" || *hits != 2 {
+		t.Fatalf("no-cache request %s after %d hits, want MISS", status, *hits)
+	}
+	_, err := c.BaseRequest(WithCacheBypass(context.Background()), srv.URL, "", "", "GET", 5)
+	if err != nil || *hits != 3 {
+		t.Fatalf("bypass request err %v after %d hits", err, *hits)
+	}
+}
+
+func TestCacheRevalidate(t *testing.T) {
+	srv, hits := cacheServer("no-cache")
+	defer srv.Close()
+	c := NewClient(5)
+	c.Use(CacheMiddleware(NewMemoryCache(10, 0), nil))
+
+	cachedGet(t, c, srv.URL, nil)
+	body, status := cachedGet(t, c, srv.URL, nil)
+	if status != "REVALIDATED" || body != "user:" || *hits != 2 {
+		t.Fatalf("got %s %q after %d hits, want REVALIDATED", status, body, *hits)
+	}
+}
+
+func TestCacheRevalidateConcurrent(t *testing.T) {
+	srv, _ := cacheServer("no-cache")
+	defer srv.Close()
+	c := NewClient(5)
+	c.Use(CacheMiddleware(NewMemoryCache(10, 0), nil))
+
+	cachedGet(t, c, srv.URL, nil)
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 10; j++ {
+				req, _ := http.NewRequest("GET", srv.URL, nil)
+				resp, err := c.Do(req)
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				resp.Body.Close()
+				if status := resp.Header.Get(CacheHeader); status != "REVALIDATED" {
+					t.Errorf("got %s, want REVALIDATED", status)
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func TestCacheCredentials(t *testing.T) {
+	tests := []struct {
+		cacheControl string
+		private      bool
+		wantShared   bool //B 是否拿到 A 的缓存
+	}{
+		{"max-age=60", false, false},
+		{"private, max-age=60", false, false},
+		{"public, max-age=60", false, true},
+		{"s-maxage=60", false, true},
+		{"max-age=60", true, true},
+	}
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("%s private=%v", tt.cacheControl, tt.private), func(t *testing.T) {
+			srv, _ := cacheServer(tt.cacheControl)
+			defer srv.Close()
+			c := NewClient(5)
+			c.Use(CacheMiddleware(NewMemoryCache(10, 0), &CacheOptions{Private: tt.private}))
+
+			cachedGet(t, c, srv.URL, map[string]string{"Authorization": "Bearer A"})
+			body, status := cachedGet(t, c, srv.URL, map[string]string{"Authorization": "Bearer B"})
+			if shared := status == "HIT"; shared != tt.wantShared {
+				t.Fatalf("second user got %s (%s)", status, body)
+			}
+			if !tt.wantShared && body != "user:Bearer B" {
+				t.Fatalf("second user got %q", body)
+			}
+		})
+	}
+}
+
+func TestCachePrivateResponse(t *testing.T) {
+	srv, hits := cacheServer("private, max-age=60")
+	defer srv.Close()
+	c := NewClient(5)
+	c.Use(CacheMiddleware(NewMemoryCache(10, 0), nil))
+	cachedGet(t, c, srv.URL, nil)
+	if _, status := cachedGet(t, c, srv.URL, nil); status != "MISS" || *hits != 2 {
+		t.Fatalf("private response served from shared cache: %s", status)
+	}
+}
+
+func TestFreshUntilSharedMaxAge(t *testing.T) {
+	h := http.Header{"Cache-Control": {"max-age=10, s-maxage=100"}}
+	now := time.Now()
+	shared, _ := freshUntil(h, now, 0, true)
+	private, _ := freshUntil(h, now, 0, false)
+	if shared.Sub(now).Seconds() != 100 || private.Sub(now).Seconds() != 10 {
+		t.Fatalf("shared %s, private %s", shared.Sub(now), private.Sub(now))
+	}
+}
+
+func TestMemoryCacheEviction(t *testing.T) {
+	m := NewMemoryCache(2, 0)
+	for i := 0; i < 3; i++ {
+		m.Set(fmt.Sprint(i), &CachedResponse{Body: []byte("x")})
+		if i == 1 {
+			m.Get("0") //访问后 "1" 成为最久未访问
+		}
+	}
+	if _, ok := m.Get("1"); ok || m.Len() != 2 {
+		t.Fatalf("len %d, least recently used entry kept: %v", m.Len(), ok)
+	}
+	m.Delete("0")
+	if m.Len() != 1 || m.bytes != 1 {
+		t.Fatalf("len %d bytes %d after delete", m.Len(), m.bytes)
+	}
+}
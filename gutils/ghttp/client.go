@@ -0,0 +1,153 @@
+package ghttp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+//Middleware 请求中间件 | 包装下一个 http.RoundTripper，可在请求发出前后做处理（报文头、日志、统计等）
+type Middleware func(next http.RoundTripper) http.RoundTripper
+
+//RoundTripperFunc 将函数适配为 http.RoundTripper
+type RoundTripperFunc func(req *http.Request) (*http.Response, error)
+
+//RoundTrip 实现 http.RoundTripper
+func (f RoundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+//Client http客户端 | 持有中间件链，请求按注册顺序依次经过各中间件，最后由底层 transport 发出
+type Client struct {
+	timeout     time.Duration     //默认超时
+	transport   http.RoundTripper //底层传输，为空则使用 http.DefaultTransport
+	middlewares []Middleware      //已注册中间件（先注册的在最外层）
+	chain       http.RoundTripper //组装好的中间件链
+	mu          sync.RWMutex
+}
+
+//DefaultClient 默认客户端 | 包内 HTTPBaseRequest、SendGET 等函数均通过该客户端发送请求
+var DefaultClient = NewClient(10)
+
+//defaultTimeout NewClient 未指定超时时使用的默认超时
+const defaultTimeout = 10 * time.Second
+
+//NewClient 创建http客户端 timeout 默认超时秒数（s），<=0 则为10秒
+func NewClient(timeout int) *Client {
+	c := &Client{timeout: time.Duration(timeout) * time.Second}
+	if timeout <= 0 {
+		c.timeout = defaultTimeout
+	}
+	c.rebuild()
+	return c
+}
+
+//Use 为默认客户端注册中间件
+func Use(mws ...Middleware) {
+	DefaultClient.Use(mws...)
+}
+
+//Use 注册中间件 | 先注册的中间件先处理请求、后处理响应
+func (c *Client) Use(mws ...Middleware) {
+	c.mu.Lock()
+	c.middlewares = append(c.middlewares, mws...)
+	c.rebuild()
+	c.mu.Unlock()
+}
+
+//SetTransport 设置底层传输 | 为空则使用 http.DefaultTransport
+func (c *Client) SetTransport(rt http.RoundTripper) {
+	c.mu.Lock()
+	c.transport = rt
+	c.rebuild()
+	c.mu.Unlock()
+}
+
+//rebuild 重新组装中间件链，调用方需持有写锁
+func (c *Client) rebuild() {
+	var rt = c.transport
+	if rt == nil {
+		rt = http.DefaultTransport
+	}
+	for i := len(c.middlewares) - 1; i >= 0; i-- {
+		rt = c.middlewares[i](rt)
+	}
+	c.chain = rt
+}
+
+//RoundTrip 实现 http.RoundTripper，请求经过完整中间件链
+func (c *Client) RoundTrip(req *http.Request) (*http.Response, error) {
+	c.mu.RLock()
+	rt := c.chain
+	c.mu.RUnlock()
+	return rt.RoundTrip(req)
+}
+
+//HTTPClient 返回使用该客户端中间件链的 http.Client | timeout 超时秒数（s），0 为不超时（与 HTTPRequestData 等函数原有行为一致）
+func (c *Client) HTTPClient(timeout int) *http.Client {
+	return &http.Client{Timeout: time.Duration(timeout) * time.Second, Transport: c}
+}
+
+//Do 发送请求（使用默认超时）
+func (c *Client) Do(req *http.Request) (*http.Response, error) {
+	return (&http.Client{Timeout: c.timeout, Transport: c}).Do(req)
+}
+
+/*BaseRequest 发送http请求（带上下文）
+传入参数：ctx 上下文 | 中间件可从中读取 Remote_addr、请求ID 等
+				 url 请求主机地址
+				 params 参数
+				 contentType 参数格式
+				 method 请求方法 "POST" OR "GET" 注意要大写
+				 timeout 超时设置 单位 s
+				 headers map[string]string 非必填参数
+*/
+func (c *Client) BaseRequest(ctx context.Context, url, params, contentType, method string, timeout int, headers ...map[string]string) (string, error) {
+	var req *http.Request
+	var err error
+	switch method {
+	case "POST":
+		req, err = http.NewRequestWithContext(ctx, "POST", url, strings.NewReader(params))
+		if err == nil {
+			if contentType == "" {
+				contentType = "application/x-www-form-urlencoded"
+			}
+			req.Header.Set("Content-Type", contentType)
+		}
+	case "GET":
+		req, err = http.NewRequestWithContext(ctx, "GET", url+"?"+params, nil)
+	default:
+		err = fmt.Errorf("不可识别method：'%s'", method)
+	}
+	if err != nil {
+		return "", fmt.Errorf("HTTP-Request-Err :%s", err)
+	}
+	//设置heads
+	if len(headers) > 0 {
+		for key, value := range headers[0] {
+			req.Header.Add(key, value)
+		}
+	}
+	//http请求
+	resp, err := c.HTTPClient(timeout).Do(req)
+	if err != nil {
+		return "", fmt.Errorf("HTTP-Request-Err :%w", err)
+	}
+	//读取返回信息
+	body, err := ioutil.ReadAll(resp.Body)
+	defer resp.Body.Close()
+	if err != nil {
+		return "", err
+	}
+	//添加404错误检查
+	bodyString := string(body)
+	if len(bodyString) > 17 && bodyString[:18] == "404 page not found" {
+		return bodyString, errors.New("404 page not found")
+	}
+	return bodyString, nil
+}
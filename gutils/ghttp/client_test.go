@@ -0,0 +1,177 @@
+package ghttp
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestClientMiddlewareOrder(t *testing.T) {
+	var order []string
+	mark := func(name string) Middleware {
+		return func(next http.RoundTripper) http.RoundTripper {
+			return RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+				order = append(order, name+">")
+				resp, err := next.RoundTrip(req)
+				order = append(order, "<"+name)
+				return resp, err
+			})
+		}
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		order = append(order, "server")
+	}))
+	defer srv.Close()
+
+	c := NewClient(5)
+	c.Use(mark("a"), mark("b"))
+	if _, err := c.BaseRequest(context.Background(), srv.URL, "", "", "GET", 5); err != nil {
+		t.Fatal(err)
+	}
+	want := "a> b> server <b <a"
+	if got := strings.Join(order, " "); got != want {
+		t.Fatalf("order = %q, want %q", got, want)
+	}
+}
+
+func TestClientTimeout(t *testing.T) {
+	c := NewClient(0)
+	if c.timeout != defaultTimeout {
+		t.Fatalf("NewClient(0) timeout = %s, want %s", c.timeout, defaultTimeout)
+	}
+	if d := c.HTTPClient(0).Timeout; d != 0 {
+		t.Fatalf("HTTPClient(0) timeout = %s, want no timeout", d)
+	}
+	if d := c.HTTPClient(3).Timeout; d != 3*time.Second {
+		t.Fatalf("HTTPClient(3) timeout = %s", d)
+	}
+}
+
+func TestRequestHeaderMiddlewares(t *testing.T) {
+	var got http.Header
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r.Header.Clone()
+	}))
+	defer srv.Close()
+
+	c := NewClient(5)
+	c.Use(HeaderMiddleware(map[string]string{"X-App": "demo", "X-Keep": "default"}), RemoteAddrMiddleware(), RequestIDMiddleware())
+	ctx := WithRequestID(WithRemoteAddr(context.Background(), "10.1.2.3"), "req-1")
+	if _, err := c.BaseRequest(ctx, srv.URL, "", "", "GET", 5, map[string]string{"X-Keep": "caller"}); err != nil {
+		t.Fatal(err)
+	}
+	for key, want := range map[string]string{"X-App": "demo", "X-Keep": "caller", RemoteAddrHeader: "10.1.2.3", RequestIDHeader: "req-1"} {
+		if got.Get(key) != want {
+			t.Errorf("%s = %q, want %q", key, got.Get(key), want)
+		}
+	}
+
+	if _, err := c.BaseRequest(context.Background(), srv.URL, "", "", "GET", 5); err != nil {
+		t.Fatal(err)
+	}
+	if id := got.Get(RequestIDHeader); len(id) != 32 {
+		t.Errorf("generated request id = %q", id)
+	}
+	if ip := got.Get(RemoteAddrHeader); ip != "" {
+		t.Errorf("Remote_addr without context = %q", ip)
+	}
+}
+
+func TestLogMiddlewareKeepsBody(t *testing.T) {
+	body := strings.Repeat("x", 5000)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := ioutil.ReadAll(r.Body)
+		w.Write(b)
+	}))
+	defer srv.Close()
+
+	c := NewClient(5)
+	c.Use(LogMiddleware(&LogOptions{LogBody: true, MaxBodySize: 16}))
+	resp, err := c.BaseRequest(context.Background(), srv.URL, body, "text/plain", "POST", 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp != body {
+		t.Fatalf("body was truncated to %d bytes", len(resp))
+	}
+}
+
+func TestRedact(t *testing.T) {
+	redact := map[string]bool{"secret": true, "token": true}
+	u, _ := url.Parse("https://api.example.com/x?appid=1&secret=abc")
+	if got := redactURL(u, redact); strings.Contains(got, "abc") {
+		t.Errorf("redactURL = %s", got)
+	}
+	tests := []struct {
+		body, contentType string
+	}{
+		{`{"a":1,"inner":{"token":"abc"}}`, "application/json"},
+		{`{"a":1,"token":"ab`, "application/json"},
+		{"a=1&secret=abc", "application/x-www-form-urlencoded"},
+	}
+	for _, tt := range tests {
+		got := redactBody([]byte(tt.body), tt.contentType, redact)
+		if strings.Contains(got, "abc") || strings.Contains(got, `"ab`) {
+			t.Errorf("redactBody(%s) = %s", tt.body, got)
+		}
+	}
+}
+
+func TestMetricsMiddleware(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/missing" {
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer srv.Close()
+
+	m := NewMetrics()
+	c := NewClient(5)
+	c.Use(MetricsMiddleware(m))
+	c.BaseRequest(context.Background(), srv.URL+"/ok", "", "", "GET", 5)
+	c.BaseRequest(context.Background(), srv.URL+"/missing", "", "", "GET", 5)
+
+	host := strings.TrimPrefix(srv.URL, "http://")
+	s, ok := m.Host(host)
+	if !ok {
+		t.Fatalf("no stats for %s: %v", host, m.Snapshot())
+	}
+	if s.Requests != 2 || s.Status[200] != 1 || s.Status[404] != 1 || s.Errors != 0 {
+		t.Fatalf("stats = %+v", s)
+	}
+	m.Reset()
+	if len(m.Snapshot()) != 0 {
+		t.Fatal("Reset left stats behind")
+	}
+}
+
+func TestMetricsConcurrentReset(t *testing.T) {
+	m := NewMetrics()
+	var wg sync.WaitGroup
+	for g := 0; g < 4; g++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 500; i++ {
+				m.observe("h", 200, time.Millisecond, nil)
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 100; i++ {
+				m.Reset()
+			}
+		}()
+	}
+	wg.Wait()
+	m.observe("h", 200, time.Millisecond, nil)
+	if s, ok := m.Host("h"); !ok || s.Requests == 0 {
+		t.Fatalf("stats after concurrent Reset = %+v, %v", s, ok)
+	}
+}
@@ -0,0 +1,285 @@
+package ghttp
+
+import (
+	"context"
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+
+	"ackevin.com/gutils/gio"
+)
+
+//ErrChecksumMismatch 下载文件校验失败
+var ErrChecksumMismatch = errors.New("download checksum mismatch")
+
+//DownloadOptions 下载配置
+type DownloadOptions struct {
+	Resume    bool                       //断点续传 | 存在未完成的 dest.part 文件时，使用 Range 请求继续下载，并以保存的 ETag/Last-Modified 作为 If-Range，文件已变化或没有校验值时从头下载
+	Checksum  string                     //文件校验 格式 "算法:十六进制值"，支持 md5、sha1、sha256 例："sha256:9f86d0..."
+	RateLimit float64                    //下载限速 字节/秒，<=0 不限速
+	Progress  func(written, total int64) //进度回调 written 已下载字节数（含续传前已有部分），total 文件总大小，未知为 -1
+	Headers   map[string]string          //额外请求头
+}
+
+//partSuffix 未完成下载的临时文件后缀
+const partSuffix = ".part"
+
+//validatorSuffix 保存 .part 文件对应的 ETag 或 Last-Modified 的文件后缀（dest.part.validator）
+const validatorSuffix = ".validator"
+
+//Download 使用默认客户端下载文件，见 Client.Download
+func Download(ctx context.Context, url, dest string, opts ...*DownloadOptions) (int64, error) {
+	return DefaultClient.Download(ctx, url, dest, opts...)
+}
+
+/*Download 流式下载文件到 dest
+下载内容先写入 dest.part，完成并校验通过后重命名为 dest；不设置超时，通过 ctx 取消下载
+返回参数：文件总字节数，错误
+*/
+func (c *Client) Download(ctx context.Context, url, dest string, opts ...*DownloadOptions) (int64, error) {
+	var o DownloadOptions
+	if len(opts) > 0 && opts[0] != nil {
+		o = *opts[0]
+	}
+	var h hash.Hash
+	var want string
+	if o.Checksum != "" {
+		var err error
+		if h, want, err = parseChecksum(o.Checksum); err != nil {
+			return 0, err
+		}
+	}
+	partPath := dest + partSuffix
+	validatorPath := partPath + validatorSuffix
+	var offset int64
+	var validator string
+	if o.Resume {
+		//没有校验值无法确认服务器文件未变化，从头下载
+		if info, err := os.Stat(partPath); err == nil {
+			if b, err := ioutil.ReadFile(validatorPath); err == nil && len(b) > 0 {
+				offset = info.Size()
+				validator = string(b)
+			}
+		}
+	} else {
+		os.Remove(partPath)
+		os.Remove(validatorPath)
+	}
+
+	resp, err := c.downloadRequest(ctx, url, offset, validator, o.Headers)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	total := int64(-1)
+	switch {
+	case offset > 0 && resp.StatusCode == http.StatusPartialContent:
+		start, size := parseContentRange(resp.Header.Get("Content-Range"))
+		if start != offset {
+			//服务器返回的范围与本地不一致，重新下载
+			resp.Body.Close()
+			offset = 0
+			if resp, err = c.downloadRequest(ctx, url, 0, "", o.Headers); err != nil {
+				return 0, err
+			}
+			defer resp.Body.Close()
+		} else {
+			total = size
+		}
+	case offset > 0 && resp.StatusCode == http.StatusRequestedRangeNotSatisfiable:
+		//本地文件已完整
+		if _, size := parseContentRange(resp.Header.Get("Content-Range")); size == offset {
+			if h != nil {
+				if err = hashFile(h, partPath); err != nil {
+					return offset, err
+				}
+			}
+			return offset, finishDownload(partPath, dest, h, want)
+		}
+		resp.Body.Close()
+		offset = 0
+		if resp, err = c.downloadRequest(ctx, url, 0, "", o.Headers); err != nil {
+			return 0, err
+		}
+		defer resp.Body.Close()
+	default:
+		//服务器不支持 Range 或文件已变化（If-Range 不匹配返回 200），从头下载
+		offset = 0
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return 0, fmt.Errorf("download error: status %d", resp.StatusCode)
+	}
+	if total < 0 && resp.ContentLength >= 0 {
+		total = offset + resp.ContentLength
+	}
+
+	flag := os.O_CREATE | os.O_WRONLY | os.O_TRUNC
+	if offset > 0 {
+		flag = os.O_CREATE | os.O_WRONLY | os.O_APPEND
+	}
+	file, err := os.OpenFile(partPath, flag, 0666)
+	if err != nil {
+		return 0, fmt.Errorf("download error:%s", err)
+	}
+	defer file.Close()
+	if offset == 0 {
+		//保存校验值，续传时作为 If-Range 发送
+		if v := responseValidator(resp); v != "" {
+			ioutil.WriteFile(validatorPath, []byte(v), 0666)
+		} else {
+			os.Remove(validatorPath)
+		}
+	}
+	//续传时先将已下载部分计入校验
+	if h != nil && offset > 0 {
+		if err = hashFile(h, partPath); err != nil {
+			return 0, err
+		}
+	}
+
+	var src io.Reader = resp.Body
+	if o.RateLimit > 0 {
+		lr := gio.NewReaderWithContext(resp.Body, ctx)
+		lr.SetRateLimit(o.RateLimit)
+		src = lr
+	}
+	var dst io.Writer = file
+	if h != nil {
+		dst = io.MultiWriter(file, h)
+	}
+	if o.Progress != nil {
+		dst = &progressWriter{w: dst, written: offset, total: total, fn: o.Progress}
+		o.Progress(offset, total)
+	}
+	n, err := io.Copy(dst, src)
+	if err != nil {
+		return offset + n, fmt.Errorf("download error:%s", err)
+	}
+	if total >= 0 && offset+n != total {
+		return offset + n, fmt.Errorf("download error: incomplete %d/%d", offset+n, total)
+	}
+	if err = file.Close(); err != nil {
+		return offset + n, fmt.Errorf("download error:%s", err)
+	}
+	return offset + n, finishDownload(partPath, dest, h, want)
+}
+
+//downloadRequest 发送下载请求，offset > 0 时带 Range 报文头，validator 不为空时带 If-Range 报文头
+func (c *Client) downloadRequest(ctx context.Context, url string, offset int64, validator string, headers map[string]string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("HTTP-Request-Err :%s", err)
+	}
+	for key, value := range headers {
+		req.Header.Set(key, value)
+	}
+	if offset > 0 {
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-", offset))
+		if validator != "" {
+			req.Header.Set("If-Range", validator)
+		}
+	}
+	//下载不设置超时，由 ctx 控制
+	resp, err := (&http.Client{Transport: c}).Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("HTTP-Request-Err :%s", err)
+	}
+	return resp, nil
+}
+
+//responseValidator 返回可作为 If-Range 的校验值：强 ETag 优先，其次 Last-Modified
+func responseValidator(resp *http.Response) string {
+	if etag := resp.Header.Get("ETag"); etag != "" && !strings.HasPrefix(etag, "W/") {
+		return etag
+	}
+	return resp.Header.Get("Last-Modified")
+}
+
+//finishDownload 校验并将临时文件重命名为目标文件 | h 已写入完整文件内容
+func finishDownload(partPath, dest string, h hash.Hash, want string) error {
+	os.Remove(partPath + validatorSuffix)
+	if h != nil {
+		if hex.EncodeToString(h.Sum(nil)) != want {
+			os.Remove(partPath)
+			return ErrChecksumMismatch
+		}
+	}
+	if err := os.Rename(partPath, dest); err != nil {
+		return fmt.Errorf("download error:%s", err)
+	}
+	return nil
+}
+
+//parseChecksum 解析 "算法:十六进制值"
+func parseChecksum(checksum string) (hash.Hash, string, error) {
+	i := strings.Index(checksum, ":")
+	if i < 0 {
+		return nil, "", fmt.Errorf("invalid checksum '%s'", checksum)
+	}
+	want := strings.ToLower(checksum[i+1:])
+	switch strings.ToLower(checksum[:i]) {
+	case "md5":
+		return md5.New(), want, nil
+	case "sha1":
+		return sha1.New(), want, nil
+	case "sha256":
+		return sha256.New(), want, nil
+	}
+	return nil, "", fmt.Errorf("unsupported checksum algorithm '%s'", checksum[:i])
+}
+
+//hashFile 将文件内容写入 h
+func hashFile(h hash.Hash, path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("download error:%s", err)
+	}
+	defer file.Close()
+	_, err = io.Copy(h, file)
+	return err
+}
+
+//parseContentRange 解析 "bytes start-end/size" 或 "bytes */size"，无法解析的部分返回 -1
+func parseContentRange(s string) (start, size int64) {
+	start, size = -1, -1
+	s = strings.TrimPrefix(strings.TrimSpace(s), "bytes ")
+	i := strings.Index(s, "/")
+	if i < 0 {
+		return
+	}
+	if n, err := strconv.ParseInt(s[i+1:], 10, 64); err == nil {
+		size = n
+	}
+	if j := strings.Index(s[:i], "-"); j > 0 {
+		if n, err := strconv.ParseInt(s[:j], 10, 64); err == nil {
+			start = n
+		}
+	}
+	return
+}
+
+//progressWriter 写入时回调进度
+type progressWriter struct {
+	w       io.Writer
+	written int64
+	total   int64
+	fn      func(written, total int64)
+}
+
+func (p *progressWriter) Write(b []byte) (int, error) {
+	n, err := p.w.Write(b)
+	p.written += int64(n)
+	p.fn(p.written, p.total)
+	return n, err
+}
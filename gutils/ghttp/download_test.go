@@ -0,0 +1,106 @@
+package ghttp
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDownloadResume(t *testing.T) {
+	content := []byte(strings.Repeat("0123456789", 100))
+	etag := `"v1"`
+	var ranges []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ranges = append(ranges, r.Header.Get("Range")+"|"+r.Header.Get("If-Range"))
+		w.Header().Set("ETag", etag)
+		http.ServeContent(w, r, "file", time.Time{}, bytes.NewReader(content))
+	}))
+	defer srv.Close()
+
+	dest := filepath.Join(t.TempDir(), "file.bin")
+	part := dest + partSuffix
+	tests := []struct {
+		name       string
+		validator  string
+		serverETag string
+		wantRange  string
+	}{
+		{"same file resumes", `"v1"`, `"v1"`, `bytes=300-|"v1"`},
+		{"changed file restarts", `"v0"`, `"v1"`, `bytes=300-|"v0"`},
+		{"no validator restarts", "", `"v1"`, "|"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ranges = nil
+			etag = tt.serverETag
+			//本地 .part 为旧文件的前 300 字节
+			stale := bytes.ToUpper(bytes.Repeat([]byte("abcdefghij"), 30))
+			if tt.validator == tt.serverETag {
+				stale = content[:300]
+			}
+			ioutil.WriteFile(part, stale, 0666)
+			os.Remove(part + validatorSuffix)
+			if tt.validator != "" {
+				ioutil.WriteFile(part+validatorSuffix, []byte(tt.validator), 0666)
+			}
+
+			n, err := Download(context.Background(), srv.URL, dest, &DownloadOptions{Resume: true})
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, _ := ioutil.ReadFile(dest)
+			if n != int64(len(content)) || !bytes.Equal(got, content) {
+				t.Fatalf("downloaded %d bytes, content matches: %v", n, bytes.Equal(got, content))
+			}
+			if len(ranges) != 1 || ranges[0] != tt.wantRange {
+				t.Fatalf("requests = %q, want [%q]", ranges, tt.wantRange)
+			}
+			if _, err := os.Stat(part + validatorSuffix); !os.IsNotExist(err) {
+				t.Fatal("validator file left behind")
+			}
+		})
+	}
+}
+
+func TestDownloadSavesValidator(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("ETag", `"v2"`)
+		w.Header().Set("Content-Length", "10")
+		w.Write([]byte("01234"))
+		//连接中断，下载不完整
+	}))
+	defer srv.Close()
+
+	dest := filepath.Join(t.TempDir(), "file.bin")
+	if _, err := Download(context.Background(), srv.URL, dest, &DownloadOptions{Resume: true}); err == nil {
+		t.Fatal("expected incomplete download error")
+	}
+	v, _ := ioutil.ReadFile(dest + partSuffix + validatorSuffix)
+	if string(v) != `"v2"` {
+		t.Fatalf("validator = %q", v)
+	}
+}
+
+func TestDownloadChecksum(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("test"))
+	}))
+	defer srv.Close()
+
+	dir := t.TempDir()
+	sum := "sha256:9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08"
+	if _, err := Download(context.Background(), srv.URL, filepath.Join(dir, "ok"), &DownloadOptions{Checksum: sum}); err != nil {
+		t.Fatal(err)
+	}
+	_, err := Download(context.Background(), srv.URL, filepath.Join(dir, "bad"), &DownloadOptions{Checksum: "md5:00"})
+	if err != ErrChecksumMismatch {
+		t.Fatalf("err = %v, want ErrChecksumMismatch", err)
+	}
+}
@@ -1,15 +1,12 @@
 package ghttp
 
 import (
-	"crypto/tls"
-	"crypto/x509"
-	"errors"
+	"context"
 	"fmt"
 	"io"
 	"io/ioutil"
 	"net/http"
 	"strings"
-	"time"
 )
 
 /*
@@ -26,9 +23,7 @@ string http响应消息内容（string）
 error 错误返回
 */
 func HTTPRequestData(url, parameter, method string, timeout int) (int, string, error) {
-	httpweixin := &http.Client{
-		Timeout: time.Duration(timeout) * time.Second,
-	}
+	httpweixin := DefaultClient.HTTPClient(timeout)
 	var resp *http.Response
 	var err error
 
@@ -50,29 +45,14 @@ func HTTPRequestData(url, parameter, method string, timeout int) (int, string, e
 }
 
 /*
-HTTPRemoteIP 获取客户端IP | 内部反向代理程序会将真实客户端IP写入 Remote_addr,方法先读 Remote_addr,没有的话再读取HTTP自己携带的客户端IP RemoteAddr
+HTTPRemoteIP 获取客户端IP | 内部反向代理程序会将真实客户端IP写入 Remote_addr
+使用 DefaultIPResolver：只有直连地址为内网代理时才读取 Remote_addr、Forwarded、X-Forwarded-For、X-Real-IP，
+X-Forwarded-For 从右往左取第一个非内网代理的IP，支持IPv6；需要自定义可信代理时使用 NewIPResolver
 输入参数 http.Request HTTP请求
-输出 客户端ip
+输出 客户端ip（不带端口号）
 */
 func HTTPRemoteIP(req *http.Request) string {
-
-	//2019-08-15 tkw edit 修复因其他反向代理程序导致获取的IP不对的问题
-	//获取 Remote_addr(内部golang程序) 获取 X-Forwarded-For 内反向代理路径
-	//对比  Remote_addr 和 X-Forwarded-For ， 如果  X-Forwarded-For 不为空，则取 X-Forwarded-For 第一个IP
-	//如果 X-Forwarded-For 为空 则取 Remote_addr
-	//如果 X-Forwarded-For，Remote_addr 都 为空 则取HTTP协议自带 Remote_Addr
-	xForwardedFor := req.Header.Get("X-Forwarded-For")
-	goRemoteAddr := req.Header.Get("Remote_addr")
-	remoteAddr := ""        //返回的IP (不带端口号)
-	if goRemoteAddr != "" { //第一读取IP golang后台自己写进去的用户实际IP地址
-		remoteAddr = goRemoteAddr
-	} else if xForwardedFor != "" { //读取代理IP地址
-		remoteAddr = strings.Split(xForwardedFor, ",")[0]
-	} else { //读取原生IP地址
-		remoteAddr = req.RemoteAddr
-	}
-	// 用冒号分割 取[0]作为IP地址
-	remoteAddr = strings.Split(remoteAddr, ":")[0]
+	remoteAddr := DefaultIPResolver.ClientIP(req)
 	// 空则默认为本机IP地址
 	if remoteAddr == "" {
 		remoteAddr = "127.0.0.1"
@@ -83,6 +63,7 @@ func HTTPRemoteIP(req *http.Request) string {
 
 /*
 GetformatURLData 解析http get请求参数 例：data:=formatURLData(r.Form)
+同名参数只保留第一个值，需要多个值或绑定到结构体时使用 BindValues、Bind
 */
 func GetformatURLData(data map[string][]string) map[string]string {
 	m := make(map[string]string)
@@ -128,47 +109,30 @@ type CertConfig struct {
 
 //HTTPPostWithCert 提交post请求带证书
 //参考博文：https://blog.csdn.net/mario08/article/details/86243266
+//证书未配置时默认读取 ./cert 目录下的证书，证书加载失败返回错误；其他请求方法或证书格式使用 NewTLSClient
 func HTTPPostWithCert(url string, contentType string, body io.Reader, certConfig *CertConfig) (*http.Response, error) {
-	var wechatPayCert = "./cert/apiclient_cert.pem"
-	var wechatPayKey = "./cert/apiclient_key.pem"
-	var rootCa = "./cert/rootca.pem"
+	var cfg = &TLSConfig{
+		CertFile:    "./cert/apiclient_cert.pem",
+		KeyFile:     "./cert/apiclient_key.pem",
+		RootCAFiles: []string{"./cert/rootca.pem"},
+	}
 	if certConfig != nil {
 		if certConfig.WechatPayCert != "" {
-			wechatPayCert = certConfig.WechatPayCert
+			cfg.CertFile = certConfig.WechatPayCert
 		}
 		if certConfig.WechatPayKey != "" {
-			wechatPayKey = certConfig.WechatPayKey
+			cfg.KeyFile = certConfig.WechatPayKey
 		}
 		if certConfig.RootCa != "" {
-			rootCa = certConfig.RootCa
+			cfg.RootCAFiles = []string{certConfig.RootCa}
 		}
 	}
-	var tr *http.Transport
-	// 微信提供的API证书,证书和证书密钥 .pem格式
-	certs, err := tls.LoadX509KeyPair(wechatPayCert, wechatPayKey)
+	// 微信提供的API证书,证书和证书密钥 .pem格式；微信支付HTTPS服务器证书的根证书 .pem格式
+	client, err := NewTLSClient(0, cfg)
 	if err != nil {
-		//sbjlog.Debug("HTTPPostWithCert %v \n", err)
-	} else {
-		// 微信支付HTTPS服务器证书的根证书  .pem格式
-		rootCa, err := ioutil.ReadFile(rootCa)
-		if err != nil {
-			//sbjlog.Debug("HTTPPostWithCert ioutil.ReadFile %v \n", err)
-		} else {
-			pool := x509.NewCertPool()
-			pool.AppendCertsFromPEM(rootCa)
-
-			tr = &http.Transport{
-				TLSClientConfig: &tls.Config{
-					RootCAs:      pool,
-					Certificates: []tls.Certificate{certs},
-				},
-			}
-		}
-
+		return nil, fmt.Errorf("HTTPPostWithCert %s", err)
 	}
-	client := &http.Client{Transport: tr}
-	return client.Post(url, contentType, body)
-
+	return (&http.Client{Transport: client}).Post(url, contentType, body)
 }
 
 /*HTTPBaseRequest 发送http请求
@@ -180,49 +144,7 @@ func HTTPPostWithCert(url string, contentType string, body io.Reader, certConfig
 				 headers map[string]string 非必填参数 | 可用于添加实际用户IP地址 例子：map[string]string{"Remote_addr": "用户IP地址"}
 */
 func HTTPBaseRequest(url, params, contentType, method string, timeout int, headers ...map[string]string) (string, error) {
-	client := &http.Client{
-		Timeout: time.Duration(timeout) * time.Second,
-	}
-	var req *http.Request
-	var err error
-	switch method {
-	case "POST":
-		req, err = http.NewRequest("POST", url, strings.NewReader(params))
-		if contentType == "" {
-			contentType = "application/x-www-form-urlencoded"
-		}
-		req.Header.Set("Content-Type", contentType)
-	case "GET":
-		req, err = http.NewRequest("GET", url+"?"+params, nil)
-	default:
-		err = fmt.Errorf("不可识别method：'%s'", method)
-	}
-	if err != nil {
-		return "", fmt.Errorf("HTTP-Request-Err :%s", err)
-	}
-	//设置heads
-	if len(headers) > 0 {
-		for key, value := range headers[0] {
-			req.Header.Add(key, value)
-		}
-	}
-	//http请求
-	resp, err := client.Do(req)
-	if err != nil {
-		return "", fmt.Errorf("HTTP-Request-Err :%s", err)
-	}
-	//读取返回信息
-	body, err := ioutil.ReadAll(resp.Body)
-	defer resp.Body.Close()
-	if err != nil {
-		return "", err
-	}
-	//添加404错误检查
-	bodyString := string(body)
-	if len(bodyString) > 17 && bodyString[:18] == "404 page not found" {
-		return bodyString, errors.New("404 page not found")
-	}
-	return bodyString, nil
+	return DefaultClient.BaseRequest(context.Background(), url, params, contentType, method, timeout, headers...)
 }
 
 /*
@@ -239,9 +161,7 @@ string http响应消息内容（string）
 error 错误返回
 */
 func HTTPRequestDataV2(url, params, contentType, method string, timeout int) (int, string, error) {
-	client := &http.Client{
-		Timeout: time.Duration(timeout) * time.Second,
-	}
+	client := DefaultClient.HTTPClient(timeout)
 	var resp *http.Response
 	var err error
 	if method == "POST" {
@@ -252,7 +172,7 @@ func HTTPRequestDataV2(url, params, contentType, method string, timeout int) (in
 		return 0, "", fmt.Errorf("不可识别method：'%s'", method)
 	}
 	if err != nil {
-		return 0, "", fmt.Errorf("HTTP-Request-Err :%s", err)
+		return 0, "", fmt.Errorf("HTTP-Request-Err :%w", err)
 	}
 	//读取返回信息
 	body, err := ioutil.ReadAll(resp.Body)
@@ -272,9 +192,7 @@ func HTTPRequestDataV2(url, params, contentType, method string, timeout int) (in
 			heads 报文头
 */
 func HTTPBaseRequestWithHeads(url, params, contentType, method string, timeout int, heads map[string]string) (string, error) {
-	client := &http.Client{
-		Timeout: time.Duration(timeout) * time.Second,
-	}
+	client := DefaultClient.HTTPClient(timeout)
 	var req *http.Request
 	var err error
 	switch method {
@@ -302,7 +220,7 @@ func HTTPBaseRequestWithHeads(url, params, contentType, method string, timeout i
 	//http请求
 	resp, err := client.Do(req)
 	if err != nil {
-		return "", fmt.Errorf("HTTP-Request-Err :%s", err)
+		return "", fmt.Errorf("HTTP-Request-Err :%w", err)
 	}
 	//读取返回信息
 	body, err := ioutil.ReadAll(resp.Body)
@@ -315,9 +233,7 @@ func HTTPBaseRequestWithHeads(url, params, contentType, method string, timeout i
 
 //HTTPRequestWithToken 发送带token的http请求
 func HTTPRequestWithToken(url, params, method string, timeout int, tokens map[string]string) (string, error) {
-	client := &http.Client{
-		Timeout: time.Duration(timeout) * time.Second,
-	}
+	client := DefaultClient.HTTPClient(timeout)
 	var req *http.Request
 	var err error
 	switch method {
@@ -342,7 +258,7 @@ func HTTPRequestWithToken(url, params, method string, timeout int, tokens map[st
 	//http请求
 	resp, err := client.Do(req)
 	if err != nil {
-		return "", fmt.Errorf("HTTP-Request-Err :%s", err)
+		return "", fmt.Errorf("HTTP-Request-Err :%w", err)
 	}
 
 	//读取返回信息
@@ -0,0 +1,616 @@
+package ghttp
+
+import (
+	"bufio"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
+	"net"
+	"net/http"
+	"runtime/debug"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"ackevin.com/glog"
+	gmap "ackevin.com/gutils/gcontainer"
+	"golang.org/x/time/rate"
+)
+
+//HandlerMiddleware 服务端中间件 | 包装下一个 http.Handler
+type HandlerMiddleware func(next http.Handler) http.Handler
+
+/*Chain 组合服务端中间件，先传入的在最外层
+推荐顺序：
+	ghttp.Chain(mux,
+		ghttp.RequestIDHandler(),
+		ghttp.AccessLogHandler(nil),
+		ghttp.RecoverHandler(),
+		ghttp.NewIPRateLimiter(20, 40).Handler,
+		ghttp.CORSHandler(nil),
+		ghttp.BodyLimitHandler(10<<20),
+		ghttp.GzipHandler(gzip.DefaultCompression, 0),
+	)
+*/
+func Chain(h http.Handler, mws ...HandlerMiddleware) http.Handler {
+	for i := len(mws) - 1; i >= 0; i-- {
+		h = mws[i](h)
+	}
+	return h
+}
+
+//statusWriter 记录状态码和写入字节数的 ResponseWriter
+type statusWriter struct {
+	http.ResponseWriter
+	status int
+	size   int64
+}
+
+func (w *statusWriter) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *statusWriter) Write(p []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	n, err := w.ResponseWriter.Write(p)
+	w.size += int64(n)
+	return n, err
+}
+
+//Flush 支持流式响应
+func (w *statusWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		if w.status == 0 {
+			w.status = http.StatusOK
+		}
+		f.Flush()
+	}
+}
+
+//Hijack 支持 websocket 等协议升级
+func (w *statusWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	if h, ok := w.ResponseWriter.(http.Hijacker); ok {
+		return h.Hijack()
+	}
+	return nil, nil, errors.New("ghttp: ResponseWriter does not implement http.Hijacker")
+}
+
+//Unwrap 供 http.ResponseController 获取原始 ResponseWriter
+func (w *statusWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+//============================================= 访问日志 =========================================================
+
+//AccessLogOptions 访问日志中间件配置
+type AccessLogOptions struct {
+	Code       string   //glog 日志代码，默认 "304"
+	SkipPaths  []string //不记录的路径（如健康检查）
+	RedactKeys []string //需要脱敏的url参数名（不区分大小写），为空则使用 DefaultRedactKeys
+}
+
+//AccessLogHandler 访问日志中间件 | 将客户端IP、请求ID、方法、地址、状态码、响应字节数、耗时写入 glog，5xx 以 Debuger 记录
+func AccessLogHandler(opts *AccessLogOptions) HandlerMiddleware {
+	var o AccessLogOptions
+	if opts != nil {
+		o = *opts
+	}
+	if o.Code == "" {
+		o.Code = "304"
+	}
+	if len(o.RedactKeys) == 0 {
+		o.RedactKeys = DefaultRedactKeys
+	}
+	redact := make(map[string]bool, len(o.RedactKeys))
+	for _, k := range o.RedactKeys {
+		redact[strings.ToLower(k)] = true
+	}
+	skip := make(map[string]bool, len(o.SkipPaths))
+	for _, p := range o.SkipPaths {
+		skip[p] = true
+	}
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if skip[r.URL.Path] {
+				next.ServeHTTP(w, r)
+				return
+			}
+			begin := time.Now()
+			sw := &statusWriter{ResponseWriter: w}
+			defer func() {
+				status := sw.status
+				if status == 0 {
+					status = http.StatusOK
+				}
+				format := "%s %s %s %s status:%d size:%d cost:%s"
+				args := []interface{}{HTTPRemoteIP(r), RequestIDFromContext(r.Context()), r.Method, redactURL(r.URL, redact), status, sw.size, time.Since(begin)}
+				if status >= 500 {
+					glog.Debuger(o.Code, format, args...)
+				} else {
+					glog.Printfer(o.Code, format, args...)
+				}
+			}()
+			next.ServeHTTP(sw, r)
+		})
+	}
+}
+
+//============================================= 异常恢复 =========================================================
+
+//RecoverHandler panic 恢复中间件 | 将 panic 及调用栈写入 glog，未写入响应时返回 500
+//http.ErrAbortHandler 继续向上 panic（由 net/http 中断连接）
+func RecoverHandler() HandlerMiddleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			sw := &statusWriter{ResponseWriter: w}
+			defer func() {
+				rec := recover()
+				if rec == nil {
+					return
+				}
+				if rec == http.ErrAbortHandler {
+					panic(rec)
+				}
+				glog.Debuger("305", "ghttp panic %s %s 请求ID:%s %v\n%s", r.Method, r.URL.Path, RequestIDFromContext(r.Context()), rec, debug.Stack())
+				if sw.status == 0 {
+					WriteError(w, r, "", fmt.Errorf("panic: %v", rec))
+				}
+			}()
+			next.ServeHTTP(sw, r)
+		})
+	}
+}
+
+//============================================= 请求ID =========================================================
+
+//RequestIDHandler 请求ID中间件 | 沿用请求中合法的 X-Request-Id（不超过128个可见字符），没有则随机生成；
+//写入请求上下文（RequestIDFromContext 读取，同一上下文发出的请求经 RequestIDMiddleware 会带上）及响应报文头
+func RequestIDHandler() HandlerMiddleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			id := r.Header.Get(RequestIDHeader)
+			if !validRequestID(id) {
+				id = NewRequestID()
+			}
+			w.Header().Set(RequestIDHeader, id)
+			next.ServeHTTP(w, r.WithContext(WithRequestID(r.Context(), id)))
+		})
+	}
+}
+
+//validRequestID 请求ID是否合法（避免日志注入）
+func validRequestID(id string) bool {
+	if id == "" || len(id) > 128 {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		if id[i] <= ' ' || id[i] > '~' {
+			return false
+		}
+	}
+	return true
+}
+
+//============================================= 跨域 =========================================================
+
+//CORSOptions 跨域中间件配置
+type CORSOptions struct {
+	AllowOrigins     []string      //允许的来源，支持 "*" 及 "https://*.example.com" 形式的子域名通配，默认 "*"
+	AllowMethods     []string      //允许的方法，默认 GET、POST、PUT、PATCH、DELETE、HEAD、OPTIONS
+	AllowHeaders     []string      //允许的请求头，为空时允许预检请求中的所有请求头
+	ExposeHeaders    []string      //允许客户端读取的响应头，默认 X-Request-Id
+	AllowCredentials bool          //是否允许携带 cookie（仅对具体来源或子域名通配匹配的来源生效，"*" 匹配的来源不允许）
+	MaxAge           time.Duration //预检结果缓存时间，默认 10 分钟
+}
+
+//CORSHandler 跨域中间件 | 预检请求（OPTIONS + Access-Control-Request-Method）直接返回 204，不允许的来源不添加跨域报文头
+func CORSHandler(opts *CORSOptions) HandlerMiddleware {
+	var o CORSOptions
+	if opts != nil {
+		o = *opts
+	}
+	if len(o.AllowOrigins) == 0 {
+		o.AllowOrigins = []string{"*"}
+	}
+	if len(o.AllowMethods) == 0 {
+		o.AllowMethods = []string{"GET", "POST", "PUT", "PATCH", "DELETE", "HEAD", "OPTIONS"}
+	}
+	if len(o.ExposeHeaders) == 0 {
+		o.ExposeHeaders = []string{RequestIDHeader}
+	}
+	if o.MaxAge <= 0 {
+		o.MaxAge = 10 * time.Minute
+	}
+	methods := strings.Join(o.AllowMethods, ", ")
+	headers := strings.Join(o.AllowHeaders, ", ")
+	expose := strings.Join(o.ExposeHeaders, ", ")
+	maxAge := strconv.Itoa(int(o.MaxAge / time.Second))
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			origin := r.Header.Get("Origin")
+			h := w.Header()
+			h.Add("Vary", "Origin")
+			preflight := r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != ""
+			matched, wildcard := matchOrigin(o.AllowOrigins, origin)
+			if origin == "" || !matched {
+				if preflight {
+					w.WriteHeader(http.StatusNoContent)
+					return
+				}
+				next.ServeHTTP(w, r)
+				return
+			}
+			if wildcard {
+				//仅由 "*" 匹配的来源不返回 Allow-Credentials，否则任意站点都能带 cookie 跨域读取
+				h.Set("Access-Control-Allow-Origin", "*")
+			} else {
+				h.Set("Access-Control-Allow-Origin", origin)
+				if o.AllowCredentials {
+					h.Set("Access-Control-Allow-Credentials", "true")
+				}
+			}
+			if !preflight {
+				h.Set("Access-Control-Expose-Headers", expose)
+				next.ServeHTTP(w, r)
+				return
+			}
+			h.Add("Vary", "Access-Control-Request-Method")
+			h.Add("Vary", "Access-Control-Request-Headers")
+			h.Set("Access-Control-Allow-Methods", methods)
+			if headers != "" {
+				h.Set("Access-Control-Allow-Headers", headers)
+			} else if reqHeaders := r.Header.Get("Access-Control-Request-Headers"); reqHeaders != "" {
+				h.Set("Access-Control-Allow-Headers", reqHeaders)
+			}
+			h.Set("Access-Control-Max-Age", maxAge)
+			w.WriteHeader(http.StatusNoContent)
+		})
+	}
+}
+
+//matchOrigin 来源是否在允许列表中 | wildcard 为 true 表示只由 "*" 匹配
+func matchOrigin(allowed []string, origin string) (matched, wildcard bool) {
+	for _, a := range allowed {
+		if a == "*" {
+			matched, wildcard = true, true
+			continue
+		}
+		if strings.EqualFold(a, origin) {
+			return true, false
+		}
+		//https://*.example.com 匹配 https://a.example.com，不匹配 https://example.com
+		if i := strings.Index(a, "*."); i >= 0 {
+			prefix, suffix := a[:i], a[i+1:]
+			if len(origin) > len(prefix)+len(suffix) && strings.HasPrefix(strings.ToLower(origin), strings.ToLower(prefix)) &&
+				strings.HasSuffix(strings.ToLower(origin), strings.ToLower(suffix)) {
+				return true, false
+			}
+		}
+	}
+	return matched, wildcard
+}
+
+//contains 字符串切片是否包含 s
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
+
+//============================================= gzip 压缩 =========================================================
+
+//gzipWriters 按压缩级别复用的 gzip.Writer
+var gzipWriters sync.Map
+
+//gzipWriter 按需压缩的 ResponseWriter | 响应内容达到 minSize 后才开始压缩
+type gzipWriter struct {
+	http.ResponseWriter
+	level   int
+	minSize int
+	status  int
+	buf     []byte
+	decided bool //是否已写入报文头（已决定是否压缩）
+	gz      *gzip.Writer
+}
+
+func (g *gzipWriter) WriteHeader(status int) {
+	if g.status == 0 {
+		g.status = status
+	}
+	//1xx 及无响应内容的状态码直接写入
+	if status < 200 || status == http.StatusNoContent || status == http.StatusNotModified {
+		g.Header().Del("Content-Encoding")
+		g.decided = true
+		g.ResponseWriter.WriteHeader(status)
+	}
+}
+
+func (g *gzipWriter) Write(p []byte) (int, error) {
+	if g.status == 0 {
+		g.status = http.StatusOK
+	}
+	if g.decided {
+		if g.gz != nil {
+			return g.gz.Write(p)
+		}
+		return g.ResponseWriter.Write(p)
+	}
+	g.buf = append(g.buf, p...)
+	if len(g.buf) >= g.minSize {
+		if err := g.start(true); err != nil {
+			return 0, err
+		}
+	}
+	return len(p), nil
+}
+
+//start 写入报文头及缓冲的内容 compress 为 true 且响应可压缩时开始压缩
+func (g *gzipWriter) start(compress bool) error {
+	g.decided = true
+	h := g.Header()
+	if h.Get("Content-Type") == "" && len(g.buf) > 0 {
+		h.Set("Content-Type", http.DetectContentType(g.buf))
+	}
+	if compress && h.Get("Content-Encoding") == "" && compressible(h.Get("Content-Type")) {
+		h.Set("Content-Encoding", "gzip")
+		h.Del("Content-Length")
+		g.ResponseWriter.WriteHeader(g.status)
+		g.gz = getGzipWriter(g.ResponseWriter, g.level)
+		_, err := g.gz.Write(g.buf)
+		g.buf = nil
+		return err
+	}
+	if g.status != 0 {
+		g.ResponseWriter.WriteHeader(g.status)
+	}
+	var err error
+	if len(g.buf) > 0 {
+		_, err = g.ResponseWriter.Write(g.buf)
+	}
+	g.buf = nil
+	return err
+}
+
+//Flush 流式响应时立即开始压缩并刷新
+func (g *gzipWriter) Flush() {
+	if !g.decided {
+		if g.status == 0 {
+			g.status = http.StatusOK
+		}
+		g.start(true)
+	}
+	if g.gz != nil {
+		g.gz.Flush()
+	}
+	if f, ok := g.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+//Unwrap 供 http.ResponseController 获取原始 ResponseWriter
+func (g *gzipWriter) Unwrap() http.ResponseWriter {
+	return g.ResponseWriter
+}
+
+//close 写入未达到 minSize 的内容（不压缩）或结束压缩
+func (g *gzipWriter) close() error {
+	if !g.decided {
+		return g.start(false)
+	}
+	if g.gz == nil {
+		return nil
+	}
+	err := g.gz.Close()
+	putGzipWriter(g.gz, g.level)
+	g.gz = nil
+	return err
+}
+
+//getGzipWriter 从对象池获取 gzip.Writer
+func getGzipWriter(w io.Writer, level int) *gzip.Writer {
+	pool, _ := gzipWriters.LoadOrStore(level, &sync.Pool{})
+	if gz, ok := pool.(*sync.Pool).Get().(*gzip.Writer); ok {
+		gz.Reset(w)
+		return gz
+	}
+	gz, err := gzip.NewWriterLevel(w, level)
+	if err != nil {
+		gz = gzip.NewWriter(w)
+	}
+	return gz
+}
+
+//putGzipWriter 归还 gzip.Writer
+func putGzipWriter(gz *gzip.Writer, level int) {
+	gz.Reset(ioutil.Discard)
+	if pool, ok := gzipWriters.Load(level); ok {
+		pool.(*sync.Pool).Put(gz)
+	}
+}
+
+//compressible 内容类型是否值得压缩（图片、音视频、压缩包等已压缩格式不再压缩）
+func compressible(contentType string) bool {
+	ct := strings.ToLower(contentType)
+	if strings.HasPrefix(ct, "image/svg") {
+		return true
+	}
+	for _, prefix := range []string{"image/", "video/", "audio/", "font/woff", "application/zip", "application/gzip", "application/x-gzip", "application/octet-stream"} {
+		if strings.HasPrefix(ct, prefix) {
+			return false
+		}
+	}
+	return true
+}
+
+//GzipHandler gzip 压缩中间件 | 客户端支持 gzip 且响应内容不少于 minSize 字节（<=0 时为 1024）时压缩
+//level 为 gzip 压缩级别（gzip.DefaultCompression、gzip.BestSpeed 等），已设置 Content-Encoding 的响应不再压缩
+func GzipHandler(level, minSize int) HandlerMiddleware {
+	if level < gzip.HuffmanOnly || level > gzip.BestCompression {
+		level = gzip.DefaultCompression
+	}
+	if minSize <= 0 {
+		minSize = 1024
+	}
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Add("Vary", "Accept-Encoding")
+			if r.Method == http.MethodHead || !acceptsGzip(r.Header.Get("Accept-Encoding")) {
+				next.ServeHTTP(w, r)
+				return
+			}
+			gw := &gzipWriter{ResponseWriter: w, level: level, minSize: minSize}
+			defer gw.close()
+			next.ServeHTTP(gw, r)
+		})
+	}
+}
+
+//acceptsGzip Accept-Encoding 是否包含 gzip（q=0 表示不接受）
+func acceptsGzip(acceptEncoding string) bool {
+	for _, item := range strings.Split(acceptEncoding, ",") {
+		parts := strings.Split(strings.TrimSpace(item), ";")
+		if !strings.EqualFold(strings.TrimSpace(parts[0]), "gzip") {
+			continue
+		}
+		for _, p := range parts[1:] {
+			if q := strings.TrimSpace(p); strings.HasPrefix(q, "q=") {
+				if v, err := strconv.ParseFloat(q[2:], 64); err == nil && v == 0 {
+					return false
+				}
+			}
+		}
+		return true
+	}
+	return false
+}
+
+//============================================= 请求体大小限制 =========================================================
+
+//limitedBody 超出限制时返回 ErrBodyTooLarge 的请求体
+type limitedBody struct {
+	io.ReadCloser
+	remaining int64
+}
+
+func (b *limitedBody) Read(p []byte) (int, error) {
+	if b.remaining <= 0 {
+		//多读1字节判断是否超出
+		var one [1]byte
+		if n, _ := b.ReadCloser.Read(one[:]); n > 0 {
+			return 0, ErrBodyTooLarge
+		}
+		return 0, io.EOF
+	}
+	if int64(len(p)) > b.remaining {
+		p = p[:b.remaining]
+	}
+	n, err := b.ReadCloser.Read(p)
+	b.remaining -= int64(n)
+	return n, err
+}
+
+//BodyLimitHandler 请求体大小限制中间件 | Content-Length 超出时直接返回 413，
+//未声明长度（分块传输）时读取超出 maxBytes 返回 ErrBodyTooLarge（Bind 等原样返回，WriteError 按 413 处理）
+func BodyLimitHandler(maxBytes int64) HandlerMiddleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.ContentLength > maxBytes {
+				WriteError(w, r, "", ErrBodyTooLarge)
+				return
+			}
+			if r.Body != nil && r.Body != http.NoBody {
+				r.Body = &limitedBody{ReadCloser: r.Body, remaining: maxBytes}
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+//============================================= 按客户端IP限流 =========================================================
+
+//ipLimiter 单个客户端IP的令牌桶
+type ipLimiter struct {
+	limiter  *rate.Limiter
+	lastSeen int64 //最后访问时间（UnixNano）
+}
+
+//IPRateLimiter 按客户端IP（HTTPRemoteIP）限流 | 超出返回 429 及 Retry-After 报文头
+type IPRateLimiter struct {
+	limit    rate.Limit
+	burst    int
+	idle     time.Duration
+	clients  gmap.ConcurrentMapOf[string, *ipLimiter]
+	requests int64
+}
+
+//NewIPRateLimiter 创建按IP限流器 limit 每秒请求数，burst 突发请求数（<=0 时为 1）
+//超过 10 分钟未访问的IP会被清理
+func NewIPRateLimiter(limit float64, burst int) *IPRateLimiter {
+	if burst <= 0 {
+		burst = 1
+	}
+	return &IPRateLimiter{limit: rate.Limit(limit), burst: burst, idle: 10 * time.Minute, clients: gmap.NewConcurrentMapOf[*ipLimiter]()}
+}
+
+//Allow 客户端IP是否还有令牌
+func (l *IPRateLimiter) Allow(ip string) bool {
+	now := time.Now()
+	c, ok := l.clients.Get(ip)
+	if !ok {
+		//新建时即记录访问时间，避免在 Upsert 与更新 lastSeen 之间被 clean 当作过期清理
+		c = l.clients.Upsert(ip, nil, func(exist bool, old *ipLimiter, _ *ipLimiter) *ipLimiter {
+			if exist {
+				return old
+			}
+			return &ipLimiter{limiter: rate.NewLimiter(l.limit, l.burst), lastSeen: now.UnixNano()}
+		})
+	}
+	atomic.StoreInt64(&c.lastSeen, now.UnixNano())
+	if atomic.AddInt64(&l.requests, 1)%1000 == 0 {
+		go l.clean(now)
+	}
+	return c.limiter.AllowN(now, 1)
+}
+
+//clean 清理长时间未访问的IP
+func (l *IPRateLimiter) clean(now time.Time) {
+	expired := now.Add(-l.idle).UnixNano()
+	var keys []string
+	l.clients.IterCb(func(key string, c *ipLimiter) {
+		if atomic.LoadInt64(&c.lastSeen) < expired {
+			keys = append(keys, key)
+		}
+	})
+	for _, key := range keys {
+		l.clients.Remove(key)
+	}
+}
+
+//Handler 按IP限流中间件
+func (l *IPRateLimiter) Handler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !l.Allow(HTTPRemoteIP(r)) {
+			retry := 1
+			if l.limit > 0 {
+				retry = int(math.Ceil(1 / float64(l.limit)))
+			}
+			w.Header().Set("Retry-After", strconv.Itoa(retry))
+			WriteError(w, r, "", NewStatusError(http.StatusTooManyRequests, "", "too many requests"))
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,322 @@
+package ghttp
+
+import (
+	"compress/gzip"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+//serve 经中间件处理一个请求
+func serve(h http.Handler, r *http.Request) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	return w
+}
+
+func TestChainOrder(t *testing.T) {
+	var order []string
+	mw := func(name string) HandlerMiddleware {
+		return func(next http.Handler) http.Handler {
+			return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				order = append(order, name)
+				next.ServeHTTP(w, r)
+			})
+		}
+	}
+	h := Chain(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { order = append(order, "handler") }), mw("a"), mw("b"))
+	serve(h, httptest.NewRequest("GET", "/", nil))
+	if got := strings.Join(order, ","); got != "a,b,handler" {
+		t.Fatalf("order = %s", got)
+	}
+}
+
+func TestAccessLogHandler(t *testing.T) {
+	var sw *statusWriter
+	h := AccessLogHandler(&AccessLogOptions{SkipPaths: []string{"/health"}})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		sw, _ = w.(*statusWriter)
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte("hello"))
+	}))
+	w := serve(h, httptest.NewRequest("GET", "/users?token=abc", nil))
+	if w.Code != http.StatusCreated || w.Body.String() != "hello" {
+		t.Fatalf("response %d %q", w.Code, w.Body.String())
+	}
+	if sw == nil || sw.status != http.StatusCreated || sw.size != 5 {
+		t.Fatalf("statusWriter recorded %+v", sw)
+	}
+	sw = nil
+	serve(h, httptest.NewRequest("GET", "/health", nil))
+	if sw != nil {
+		t.Fatal("skipped path wrapped")
+	}
+}
+
+func TestRecoverHandler(t *testing.T) {
+	h := RecoverHandler()(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/written" {
+			w.WriteHeader(http.StatusAccepted)
+		}
+		panic("boom")
+	}))
+	if w := serve(h, httptest.NewRequest("GET", "/", nil)); w.Code != http.StatusInternalServerError {
+		t.Fatalf("status = %d, want 500", w.Code)
+	}
+	if w := serve(h, httptest.NewRequest("GET", "/written", nil)); w.Code != http.StatusAccepted {
+		t.Fatalf("status = %d, want 202 already written", w.Code)
+	}
+
+	abort := RecoverHandler()(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		panic(http.ErrAbortHandler)
+	}))
+	defer func() {
+		if rec := recover(); rec != http.ErrAbortHandler {
+			t.Fatalf("recovered %v, want http.ErrAbortHandler", rec)
+		}
+	}()
+	serve(abort, httptest.NewRequest("GET", "/", nil))
+}
+
+func TestRequestIDHandler(t *testing.T) {
+	var got string
+	h := RequestIDHandler()(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = RequestIDFromContext(r.Context())
+	}))
+	tests := []struct {
+		name, header string
+		keep         bool
+	}{
+		{"valid", "abc-123", true},
+		{"missing", "", false},
+		{"log injection", "abc\nFAKE", false},
+		{"too long", strings.Repeat("a", 129), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("GET", "/", nil)
+			r.Header.Set(RequestIDHeader, tt.header)
+			w := serve(h, r)
+			if got == "" || w.Header().Get(RequestIDHeader) != got {
+				t.Fatalf("context %q, response header %q", got, w.Header().Get(RequestIDHeader))
+			}
+			if (got == tt.header) != tt.keep {
+				t.Fatalf("request id %q, header %q keep %v", got, tt.header, tt.keep)
+			}
+		})
+	}
+}
+
+func TestCORSHandler(t *testing.T) {
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { w.Write([]byte("ok")) })
+	h := CORSHandler(&CORSOptions{AllowOrigins: []string{"https://*.example.com"}, AllowCredentials: true})(next)
+
+	r := httptest.NewRequest("OPTIONS", "/", nil)
+	r.Header.Set("Origin", "https://a.example.com")
+	r.Header.Set("Access-Control-Request-Method", "PUT")
+	r.Header.Set("Access-Control-Request-Headers", "X-Token")
+	w := serve(h, r)
+	if w.Code != http.StatusNoContent || w.Body.Len() != 0 {
+		t.Fatalf("preflight %d %q, want 204", w.Code, w.Body.String())
+	}
+	want := map[string]string{
+		"Access-Control-Allow-Origin":      "https://a.example.com",
+		"Access-Control-Allow-Credentials": "true",
+		"Access-Control-Allow-Headers":     "X-Token",
+		"Access-Control-Max-Age":           "600",
+	}
+	for k, v := range want {
+		if got := w.Header().Get(k); got != v {
+			t.Errorf("%s = %q, want %q", k, got, v)
+		}
+	}
+
+	r = httptest.NewRequest("OPTIONS", "/", nil)
+	r.Header.Set("Origin", "https://example.com")
+	r.Header.Set("Access-Control-Request-Method", "PUT")
+	if w := serve(h, r); w.Code != http.StatusNoContent || w.Header().Get("Access-Control-Allow-Origin") != "" {
+		t.Fatalf("disallowed preflight %d allow-origin %q", w.Code, w.Header().Get("Access-Control-Allow-Origin"))
+	}
+
+	r = httptest.NewRequest("GET", "/", nil)
+	r.Header.Set("Origin", "https://b.example.com")
+	w = serve(h, r)
+	if w.Body.String() != "ok" || w.Header().Get("Access-Control-Expose-Headers") != RequestIDHeader {
+		t.Fatalf("simple request %q expose %q", w.Body.String(), w.Header().Get("Access-Control-Expose-Headers"))
+	}
+
+	r = httptest.NewRequest("GET", "/", nil)
+	r.Header.Set("Origin", "https://other.com")
+	if w := serve(CORSHandler(nil)(next), r); w.Header().Get("Access-Control-Allow-Origin") != "*" {
+		t.Fatalf("default allow-origin %q, want *", w.Header().Get("Access-Control-Allow-Origin"))
+	}
+}
+
+func TestCORSWildcardCredentials(t *testing.T) {
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+	h := CORSHandler(&CORSOptions{AllowOrigins: []string{"*", "https://app.example.com"}, AllowCredentials: true})(next)
+	tests := []struct {
+		origin, wantOrigin, wantCredentials string
+	}{
+		{"https://evil.com", "*", ""},
+		{"https://app.example.com", "https://app.example.com", "true"},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest("GET", "/", nil)
+		r.Header.Set("Origin", tt.origin)
+		w := serve(h, r)
+		if got := w.Header().Get("Access-Control-Allow-Origin"); got != tt.wantOrigin {
+			t.Errorf("%s: allow-origin %q, want %q", tt.origin, got, tt.wantOrigin)
+		}
+		if got := w.Header().Get("Access-Control-Allow-Credentials"); got != tt.wantCredentials {
+			t.Errorf("%s: allow-credentials %q, want %q", tt.origin, got, tt.wantCredentials)
+		}
+	}
+}
+
+func TestGzipHandler(t *testing.T) {
+	large := strings.Repeat("hello gzip ", 20)
+	tests := []struct {
+		name     string
+		write    func(w http.ResponseWriter)
+		accept   string
+		wantGzip bool
+		wantCode int
+	}{
+		{"below minSize buffered", func(w http.ResponseWriter) { w.Write([]byte("small")) }, "gzip", false, 200},
+		{"reaches minSize across writes", func(w http.ResponseWriter) {
+			w.Write([]byte(large[:60]))
+			w.Write([]byte(large[60:]))
+		}, "gzip", true, 200},
+		{"client refuses", func(w http.ResponseWriter) { w.Write([]byte(large)) }, "gzip;q=0", false, 200},
+		{"already encoded", func(w http.ResponseWriter) {
+			w.Header().Set("Content-Encoding", "br")
+			w.Write([]byte(large))
+		}, "gzip", false, 200},
+		{"incompressible type", func(w http.ResponseWriter) {
+			w.Header().Set("Content-Type", "image/png")
+			w.Write([]byte(large))
+		}, "gzip", false, 200},
+		{"no content", func(w http.ResponseWriter) { w.WriteHeader(http.StatusNoContent) }, "gzip", false, 204},
+		{"status kept", func(w http.ResponseWriter) {
+			w.WriteHeader(http.StatusCreated)
+			w.Write([]byte(large))
+		}, "gzip", true, 201},
+		{"flush starts compression", func(w http.ResponseWriter) {
+			w.Write([]byte("x"))
+			w.(http.Flusher).Flush()
+		}, "gzip", true, 200},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := GzipHandler(gzip.BestSpeed, 100)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { tt.write(w) }))
+			r := httptest.NewRequest("GET", "/", nil)
+			r.Header.Set("Accept-Encoding", tt.accept)
+			w := serve(h, r)
+			if w.Code != tt.wantCode {
+				t.Fatalf("status %d, want %d", w.Code, tt.wantCode)
+			}
+			gzipped := w.Header().Get("Content-Encoding") == "gzip"
+			if gzipped != tt.wantGzip {
+				t.Fatalf("Content-Encoding %q, want gzip %v", w.Header().Get("Content-Encoding"), tt.wantGzip)
+			}
+			if !gzipped {
+				return
+			}
+			zr, err := gzip.NewReader(w.Body)
+			if err != nil {
+				t.Fatal(err)
+			}
+			body, err := ioutil.ReadAll(zr)
+			if err != nil || (len(body) != len(large) && string(body) != "x") {
+				t.Fatalf("decompressed %q err %v", body, err)
+			}
+		})
+	}
+}
+
+func TestBodyLimitHandler(t *testing.T) {
+	h := BodyLimitHandler(8)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			WriteError(w, r, "", err)
+			return
+		}
+		w.Write(body)
+	}))
+	tests := []struct {
+		name          string
+		body          string
+		contentLength int64
+		wantCode      int
+	}{
+		{"within limit", "12345678", 8, 200},
+		{"content-length over limit", "123456789", 9, http.StatusRequestEntityTooLarge},
+		{"chunked within limit", "1234", -1, 200},
+		{"chunked over-read", "123456789", -1, http.StatusRequestEntityTooLarge},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("POST", "/", strings.NewReader(tt.body))
+			r.ContentLength = tt.contentLength
+			if w := serve(h, r); w.Code != tt.wantCode {
+				t.Fatalf("status %d, want %d", w.Code, tt.wantCode)
+			}
+		})
+	}
+
+	r := httptest.NewRequest("POST", "/", strings.NewReader("123456789"))
+	r.ContentLength = -1
+	BodyLimitHandler(8)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, err := ioutil.ReadAll(r.Body); !errors.Is(err, ErrBodyTooLarge) {
+			t.Fatalf("read err = %v, want ErrBodyTooLarge", err)
+		}
+	})).ServeHTTP(httptest.NewRecorder(), r)
+}
+
+func TestIPRateLimiter(t *testing.T) {
+	l := NewIPRateLimiter(0.5, 1)
+	h := l.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	request := func(ip string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest("GET", "/", nil)
+		r.RemoteAddr = ip + ":1234"
+		return serve(h, r)
+	}
+	if w := request("203.0.113.1"); w.Code != http.StatusOK {
+		t.Fatalf("first request %d", w.Code)
+	}
+	w := request("203.0.113.1")
+	if w.Code != http.StatusTooManyRequests || w.Header().Get("Retry-After") != "2" {
+		t.Fatalf("second request %d Retry-After %q, want 429 and 2", w.Code, w.Header().Get("Retry-After"))
+	}
+	if w := request("203.0.113.2"); w.Code != http.StatusOK {
+		t.Fatalf("other ip %d", w.Code)
+	}
+}
+
+func TestIPRateLimiterConcurrentClean(t *testing.T) {
+	l := NewIPRateLimiter(1000, 10)
+	var wg sync.WaitGroup
+	for g := 0; g < 4; g++ {
+		wg.Add(2)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < 500; i++ {
+				l.Allow("198.51.100." + strconv.Itoa(g*50+i%50))
+			}
+		}(g)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 200; i++ {
+				//一小时后的时间点清理会删除所有IP
+				l.clean(time.Now().Add(time.Hour))
+			}
+		}()
+	}
+	wg.Wait()
+}
@@ -0,0 +1,385 @@
+package ghttp
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"ackevin.com/glog"
+	gmap "ackevin.com/gutils/gcontainer"
+)
+
+//RemoteAddrHeader 内部反向代理写入真实客户端IP的报文头（见 HTTPRemoteIP）
+const RemoteAddrHeader = "Remote_addr"
+
+//RequestIDHeader 请求ID报文头
+const RequestIDHeader = "X-Request-Id"
+
+type ctxKey int
+
+const (
+	remoteAddrKey ctxKey = iota //上下文中的客户端IP
+	requestIDKey                //上下文中的请求ID
+)
+
+//WithRemoteAddr 将客户端IP写入上下文，RemoteAddrMiddleware 会将其作为 Remote_addr 报文头发出
+func WithRemoteAddr(ctx context.Context, ip string) context.Context {
+	return context.WithValue(ctx, remoteAddrKey, ip)
+}
+
+//RemoteAddrFromContext 读取上下文中的客户端IP，没有则返回空字符串
+func RemoteAddrFromContext(ctx context.Context) string {
+	ip, _ := ctx.Value(remoteAddrKey).(string)
+	return ip
+}
+
+//WithRequestID 将请求ID写入上下文
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey, id)
+}
+
+//RequestIDFromContext 读取上下文中的请求ID，没有则返回空字符串
+func RequestIDFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey).(string)
+	return id
+}
+
+//NewRequestID 生成16字节随机请求ID（32位十六进制字符串）
+func NewRequestID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+//cloneRequest 复制请求 | RoundTripper 不应修改传入的请求
+func cloneRequest(req *http.Request) *http.Request {
+	return req.Clone(req.Context())
+}
+
+//HeaderMiddleware 为所有请求添加固定报文头（如鉴权头），请求已带同名报文头时不覆盖
+func HeaderMiddleware(headers map[string]string) Middleware {
+	return func(next http.RoundTripper) http.RoundTripper {
+		return RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			r := cloneRequest(req)
+			for key, value := range headers {
+				if r.Header.Get(key) == "" {
+					r.Header.Set(key, value)
+				}
+			}
+			return next.RoundTrip(r)
+		})
+	}
+}
+
+//RemoteAddrMiddleware 将上下文中的客户端IP（WithRemoteAddr）写入 Remote_addr 报文头，请求已带该报文头时不覆盖
+func RemoteAddrMiddleware() Middleware {
+	return func(next http.RoundTripper) http.RoundTripper {
+		return RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			ip := RemoteAddrFromContext(req.Context())
+			if ip == "" || req.Header.Get(RemoteAddrHeader) != "" {
+				return next.RoundTrip(req)
+			}
+			r := cloneRequest(req)
+			r.Header.Set(RemoteAddrHeader, ip)
+			return next.RoundTrip(r)
+		})
+	}
+}
+
+//RequestIDMiddleware 为请求添加 X-Request-Id 报文头 | 优先使用上下文中的请求ID，没有则随机生成
+func RequestIDMiddleware() Middleware {
+	return func(next http.RoundTripper) http.RoundTripper {
+		return RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			if req.Header.Get(RequestIDHeader) != "" {
+				return next.RoundTrip(req)
+			}
+			id := RequestIDFromContext(req.Context())
+			if id == "" {
+				id = NewRequestID()
+			}
+			r := cloneRequest(req)
+			r.Header.Set(RequestIDHeader, id)
+			return next.RoundTrip(r)
+		})
+	}
+}
+
+//============================================= 请求日志 =========================================================
+
+//DefaultRedactKeys 默认脱敏的参数名
+var DefaultRedactKeys = []string{"secret", "appsecret", "access_token", "refresh_token", "session_key", "password", "token", "js_code", "code"}
+
+//LogOptions 请求日志中间件配置
+type LogOptions struct {
+	Code        string   //glog 日志代码，默认 "300"
+	LogBody     bool     //是否记录请求/响应内容
+	MaxBodySize int      //记录内容的最大字节数，超出截断，默认 1024
+	RedactKeys  []string //需要脱敏的参数名（不区分大小写），对 url 参数、form 和 json 内容生效，为空则使用 DefaultRedactKeys
+}
+
+//redactedValue 脱敏后的替换值
+const redactedValue = "***"
+
+//LogMiddleware 请求日志中间件 | 将请求方法、地址、状态码、耗时写入 glog，出错时以 Debuger 记录
+//请求/响应内容只预读前 MaxBodySize 字节，不影响大文件上传下载
+func LogMiddleware(opts *LogOptions) Middleware {
+	var o LogOptions
+	if opts != nil {
+		o = *opts
+	}
+	if o.Code == "" {
+		o.Code = "300"
+	}
+	if o.MaxBodySize <= 0 {
+		o.MaxBodySize = 1024
+	}
+	if len(o.RedactKeys) == 0 {
+		o.RedactKeys = DefaultRedactKeys
+	}
+	redact := make(map[string]bool, len(o.RedactKeys))
+	for _, k := range o.RedactKeys {
+		redact[strings.ToLower(k)] = true
+	}
+	return func(next http.RoundTripper) http.RoundTripper {
+		return RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			var reqBody string
+			if o.LogBody && req.Body != nil && req.Body != http.NoBody {
+				var peek []byte
+				req = cloneRequest(req)
+				peek, req.Body = peekBody(req.Body, o.MaxBodySize)
+				reqBody = redactBody(peek, req.Header.Get("Content-Type"), redact)
+			}
+			begin := time.Now()
+			resp, err := next.RoundTrip(req)
+			cost := time.Since(begin)
+			target := redactURL(req.URL, redact)
+			if err != nil {
+				glog.Debuger(o.Code, "HTTP %s %s cost:%s err:%s req:%s", req.Method, target, cost, err, reqBody)
+				return resp, err
+			}
+			if !o.LogBody {
+				glog.Printfer(o.Code, "HTTP %s %s status:%d cost:%s", req.Method, target, resp.StatusCode, cost)
+				return resp, err
+			}
+			var peek []byte
+			peek, resp.Body = peekBody(resp.Body, o.MaxBodySize)
+			glog.Printfer(o.Code, "HTTP %s %s status:%d cost:%s req:%s resp:%s", req.Method, target, resp.StatusCode, cost,
+				reqBody, redactBody(peek, resp.Header.Get("Content-Type"), redact))
+			return resp, err
+		})
+	}
+}
+
+//readCloser 组合 Reader 和原始 Closer
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
+
+//peekBody 预读 body 前 n 字节，返回预读内容和可完整读取的新 body
+func peekBody(body io.ReadCloser, n int) ([]byte, io.ReadCloser) {
+	peek := make([]byte, n)
+	m, _ := io.ReadFull(body, peek)
+	peek = peek[:m]
+	return peek, readCloser{io.MultiReader(bytes.NewReader(peek), body), body}
+}
+
+//redactURL 返回脱敏后的地址字符串
+func redactURL(u *url.URL, redact map[string]bool) string {
+	if u.RawQuery == "" {
+		return u.String()
+	}
+	cp := *u
+	cp.RawQuery = redactQuery(u.RawQuery, redact)
+	return cp.String()
+}
+
+//redactQuery 对 url 参数格式的字符串脱敏
+func redactQuery(raw string, redact map[string]bool) string {
+	values, err := url.ParseQuery(raw)
+	if err != nil {
+		return raw
+	}
+	for k := range values {
+		if redact[strings.ToLower(k)] {
+			values[k] = []string{redactedValue}
+		}
+	}
+	return values.Encode()
+}
+
+//redactBody 按内容格式对 body 脱敏 | json 递归替换字段值，form 替换参数值，其他格式原样返回
+func redactBody(body []byte, contentType string, redact map[string]bool) string {
+	if len(body) == 0 {
+		return ""
+	}
+	trimmed := bytes.TrimSpace(body)
+	if strings.Contains(contentType, "json") || (len(trimmed) > 0 && (trimmed[0] == '{' || trimmed[0] == '[')) {
+		var v interface{}
+		if json.Unmarshal(trimmed, &v) == nil {
+			b, _ := json.Marshal(redactJSON(v, redact))
+			return string(b)
+		}
+		//内容被截断无法解析时，按字段正则替换
+		return jsonFieldRegexp.ReplaceAllStringFunc(string(body), func(field string) string {
+			m := jsonFieldRegexp.FindStringSubmatch(field)
+			if !redact[strings.ToLower(m[1])] {
+				return field
+			}
+			return `"` + m[1] + `":"` + redactedValue + `"`
+		})
+	}
+	if strings.Contains(contentType, "x-www-form-urlencoded") {
+		return redactQuery(string(body), redact)
+	}
+	return string(body)
+}
+
+//jsonFieldRegexp 匹配 json 字段 "key":value（value 可能被截断）
+var jsonFieldRegexp = regexp.MustCompile(`"([^"\\]+)"\s*:\s*("(?:[^"\\]|\\.)*"?|[^,}\]\s]+)`)
+
+//redactJSON 递归替换 json 中需要脱敏的字段值
+func redactJSON(v interface{}, redact map[string]bool) interface{} {
+	switch val := v.(type) {
+	case map[string]interface{}:
+		for k, item := range val {
+			if redact[strings.ToLower(k)] {
+				val[k] = redactedValue
+			} else {
+				val[k] = redactJSON(item, redact)
+			}
+		}
+	case []interface{}:
+		for i, item := range val {
+			val[i] = redactJSON(item, redact)
+		}
+	}
+	return v
+}
+
+//============================================= 按主机统计 =========================================================
+
+//HostStats 单个主机的请求统计
+type HostStats struct {
+	Requests  int64         //请求总数
+	Errors    int64         //网络错误数（未拿到响应）
+	Status    map[int]int64 //按http状态码计数
+	TotalTime time.Duration //累计耗时
+	MaxTime   time.Duration //最大耗时
+}
+
+//AvgTime 平均耗时
+func (s HostStats) AvgTime() time.Duration {
+	if s.Requests == 0 {
+		return 0
+	}
+	return s.TotalTime / time.Duration(s.Requests)
+}
+
+//hostCounter 单个主机的统计计数器
+type hostCounter struct {
+	mu    sync.Mutex
+	stats HostStats
+}
+
+//Metrics 按主机统计请求数、错误数、状态码和耗时
+type Metrics struct {
+	hosts gmap.ConcurrentMapOf[string, *hostCounter]
+}
+
+//NewMetrics 创建请求统计
+func NewMetrics() *Metrics {
+	return &Metrics{hosts: gmap.NewConcurrentMapOf[*hostCounter]()}
+}
+
+//counter 获取主机计数器，不存在则创建 | 用 Upsert 一次完成读取或插入，避免与 Reset 并发时拿到 nil
+func (m *Metrics) counter(host string) *hostCounter {
+	if c, ok := m.hosts.Get(host); ok {
+		return c
+	}
+	return m.hosts.Upsert(host, nil, func(exist bool, old *hostCounter, _ *hostCounter) *hostCounter {
+		if exist {
+			return old
+		}
+		return &hostCounter{stats: HostStats{Status: map[int]int64{}}}
+	})
+}
+
+//observe 记录一次请求
+func (m *Metrics) observe(host string, status int, cost time.Duration, err error) {
+	c := m.counter(host)
+	c.mu.Lock()
+	c.stats.Requests++
+	if err != nil {
+		c.stats.Errors++
+	} else {
+		c.stats.Status[status]++
+	}
+	c.stats.TotalTime += cost
+	if cost > c.stats.MaxTime {
+		c.stats.MaxTime = cost
+	}
+	c.mu.Unlock()
+}
+
+//Host 返回单个主机的统计快照
+func (m *Metrics) Host(host string) (HostStats, bool) {
+	c, ok := m.hosts.Get(host)
+	if !ok {
+		return HostStats{}, false
+	}
+	return c.snapshot(), true
+}
+
+//Snapshot 返回所有主机的统计快照
+func (m *Metrics) Snapshot() map[string]HostStats {
+	result := make(map[string]HostStats)
+	m.hosts.IterCb(func(host string, c *hostCounter) {
+		result[host] = c.snapshot()
+	})
+	return result
+}
+
+//Reset 清空统计
+func (m *Metrics) Reset() {
+	for _, host := range m.hosts.Keys() {
+		m.hosts.Remove(host)
+	}
+}
+
+//snapshot 复制当前统计
+func (c *hostCounter) snapshot() HostStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	s := c.stats
+	s.Status = make(map[int]int64, len(c.stats.Status))
+	for code, n := range c.stats.Status {
+		s.Status[code] = n
+	}
+	return s
+}
+
+//MetricsMiddleware 按主机统计请求的中间件
+func MetricsMiddleware(m *Metrics) Middleware {
+	return func(next http.RoundTripper) http.RoundTripper {
+		return RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			begin := time.Now()
+			resp, err := next.RoundTrip(req)
+			status := 0
+			if resp != nil {
+				status = resp.StatusCode
+			}
+			m.observe(req.URL.Host, status, time.Since(begin), err)
+			return resp, err
+		})
+	}
+}
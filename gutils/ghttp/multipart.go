@@ -0,0 +1,259 @@
+package ghttp
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"ackevin.com/gutils/gio"
+)
+
+//formField 普通表单字段
+type formField struct {
+	name  string
+	value string
+}
+
+//formFile 表单文件 | path 和 reader 二选一
+type formFile struct {
+	fieldName   string
+	fileName    string
+	contentType string
+	path        string
+	reader      io.Reader
+	size        int64 //文件大小，未知为 -1
+}
+
+//MultipartForm multipart/form-data 表单 | 文件内容在发送时边读边写，不会整体读入内存
+type MultipartForm struct {
+	fields    []formField
+	files     []formFile
+	rateLimit float64 //上传限速 字节/秒，0 不限速
+	boundary  string
+	err       error //添加文件时的错误，发送时返回
+}
+
+//NewMultipartForm 创建 multipart 表单
+func NewMultipartForm() *MultipartForm {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return &MultipartForm{boundary: "ghttp" + hex.EncodeToString(b)}
+}
+
+//AddField 添加普通字段
+func (f *MultipartForm) AddField(name, value string) *MultipartForm {
+	f.fields = append(f.fields, formField{name: name, value: value})
+	return f
+}
+
+//AddFile 添加本地文件 | fieldName 表单字段名（如微信素材上传为 "media"），path 文件路径
+func (f *MultipartForm) AddFile(fieldName, path string) *MultipartForm {
+	info, err := os.Stat(path)
+	if err != nil {
+		if f.err == nil {
+			f.err = fmt.Errorf("multipart file error:%s", err)
+		}
+		return f
+	}
+	if info.IsDir() {
+		if f.err == nil {
+			f.err = fmt.Errorf("multipart file error:%s is a directory", path)
+		}
+		return f
+	}
+	f.files = append(f.files, formFile{fieldName: fieldName, fileName: filepath.Base(path), path: path, size: info.Size()})
+	return f
+}
+
+//AddReader 添加文件内容 | r 为 *bytes.Reader、*strings.Reader 等可获取长度的类型时，请求会带 Content-Length
+func (f *MultipartForm) AddReader(fieldName, fileName string, r io.Reader) *MultipartForm {
+	size := int64(-1)
+	if l, ok := r.(interface{ Len() int }); ok {
+		size = int64(l.Len())
+	}
+	f.files = append(f.files, formFile{fieldName: fieldName, fileName: fileName, reader: r, size: size})
+	return f
+}
+
+//SetFileContentType 设置最后添加文件的 Content-Type，默认 application/octet-stream
+func (f *MultipartForm) SetFileContentType(contentType string) *MultipartForm {
+	if len(f.files) > 0 {
+		f.files[len(f.files)-1].contentType = contentType
+	}
+	return f
+}
+
+//SetRateLimit 设置上传限速（字节/秒），<=0 不限速
+func (f *MultipartForm) SetRateLimit(bytesPerSec float64) *MultipartForm {
+	f.rateLimit = bytesPerSec
+	return f
+}
+
+//ContentType 表单的 Content-Type（含 boundary）
+func (f *MultipartForm) ContentType() string {
+	return "multipart/form-data; boundary=" + f.boundary
+}
+
+//partHeader 文件分段报文头
+func (file *formFile) partHeader() textproto.MIMEHeader {
+	contentType := file.contentType
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+	h := make(textproto.MIMEHeader)
+	h.Set("Content-Disposition", fmt.Sprintf(`form-data; name="%s"; filename="%s"`, escapeQuotes(file.fieldName), escapeQuotes(file.fileName)))
+	h.Set("Content-Type", contentType)
+	return h
+}
+
+var quoteEscaper = strings.NewReplacer("\\", "\\\\", `"`, "\\\"")
+
+func escapeQuotes(s string) string {
+	return quoteEscaper.Replace(s)
+}
+
+//writeTo 将表单写入 w | withContent 为 false 时只写分段结构（用于计算长度）
+func (f *MultipartForm) writeTo(w io.Writer, withContent bool) error {
+	mw := multipart.NewWriter(w)
+	if err := mw.SetBoundary(f.boundary); err != nil {
+		return err
+	}
+	for _, field := range f.fields {
+		if err := mw.WriteField(field.name, field.value); err != nil {
+			return err
+		}
+	}
+	for i := range f.files {
+		file := &f.files[i]
+		part, err := mw.CreatePart(file.partHeader())
+		if err != nil {
+			return err
+		}
+		if !withContent {
+			continue
+		}
+		if err = file.copyTo(part); err != nil {
+			return err
+		}
+	}
+	return mw.Close()
+}
+
+//copyTo 将文件内容写入 w
+func (file *formFile) copyTo(w io.Writer) error {
+	r := file.reader
+	if file.path != "" {
+		fp, err := os.Open(file.path)
+		if err != nil {
+			return fmt.Errorf("multipart file error:%s", err)
+		}
+		defer fp.Close()
+		r = fp
+	}
+	_, err := io.Copy(w, r)
+	return err
+}
+
+//ContentLength 计算表单总长度，存在未知长度的文件时返回 -1
+func (f *MultipartForm) ContentLength() int64 {
+	var counter countWriter
+	for _, file := range f.files {
+		if file.size < 0 {
+			return -1
+		}
+		counter += countWriter(file.size)
+	}
+	if f.writeTo(&counter, false) != nil {
+		return -1
+	}
+	return int64(counter)
+}
+
+//countWriter 只统计写入字节数
+type countWriter int64
+
+func (c *countWriter) Write(p []byte) (int, error) {
+	*c += countWriter(len(p))
+	return len(p), nil
+}
+
+//Body 返回流式表单内容 | 内容由后台协程经 io.Pipe 写入，设置了限速时经 gio.Writer 限速
+func (f *MultipartForm) Body(ctx context.Context) (io.ReadCloser, error) {
+	if f.err != nil {
+		return nil, f.err
+	}
+	pr, pw := io.Pipe()
+	go func() {
+		var w io.Writer = pw
+		if f.rateLimit > 0 {
+			lw := gio.NewWriterWithContext(pw, ctx)
+			lw.SetRateLimit(f.rateLimit)
+			w = lw
+		}
+		pw.CloseWithError(f.writeTo(w, true))
+	}()
+	return pr, nil
+}
+
+//NewMultipartRequest 创建 multipart 上传请求
+func NewMultipartRequest(ctx context.Context, url string, form *MultipartForm) (*http.Request, error) {
+	body, err := form.Body(ctx)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, "POST", url, body)
+	if err != nil {
+		body.Close()
+		return nil, err
+	}
+	req.Header.Set("Content-Type", form.ContentType())
+	req.ContentLength = form.ContentLength()
+	return req, nil
+}
+
+/*PostMultipart 发送 multipart/form-data 请求
+传入参数：ctx 上下文
+				 url 请求地址
+				 form 表单
+				 timeout 超时设置 单位 s | 大文件上传注意设置足够的超时
+				 headers map[string]string 非必填参数
+*/
+func (c *Client) PostMultipart(ctx context.Context, url string, form *MultipartForm, timeout int, headers ...map[string]string) (string, error) {
+	req, err := NewMultipartRequest(ctx, url, form)
+	if err != nil {
+		return "", fmt.Errorf("HTTP-Request-Err :%s", err)
+	}
+	if len(headers) > 0 {
+		for key, value := range headers[0] {
+			req.Header.Add(key, value)
+		}
+	}
+	resp, err := c.HTTPClient(timeout).Do(req)
+	if err != nil {
+		return "", fmt.Errorf("HTTP-Request-Err :%s", err)
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return "", err
+	}
+	return string(body), nil
+}
+
+/*SendPostMultipart 发送 multipart/form-data 请求（默认客户端）
+例子（微信上传临时素材）：
+	form := ghttp.NewMultipartForm().AddFile("media", "./a.jpg")
+	ghttp.SendPostMultipart("https://api.weixin.qq.com/cgi-bin/media/upload?access_token=TOKEN&type=image", form, 30)
+*/
+func SendPostMultipart(url string, form *MultipartForm, timeout int, headers ...map[string]string) (string, error) {
+	return DefaultClient.PostMultipart(context.Background(), url, form, timeout, headers...)
+}
@@ -0,0 +1,65 @@
+package ghttp
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestPostMultipart(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "a.txt")
+	ioutil.WriteFile(path, []byte("file content"), 0644)
+
+	var contentLength int64
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		contentLength = r.ContentLength
+		if err := r.ParseMultipartForm(1 << 20); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		var parts []string
+		for _, name := range []string{"media", "raw"} {
+			fh := r.MultipartForm.File[name][0]
+			f, _ := fh.Open()
+			b, _ := ioutil.ReadAll(f)
+			f.Close()
+			parts = append(parts, fh.Filename+"="+string(b)+"("+fh.Header.Get("Content-Type")+")")
+		}
+		w.Write([]byte(r.FormValue("type") + " " + strings.Join(parts, " ")))
+	}))
+	defer srv.Close()
+
+	form := NewMultipartForm().
+		AddField("type", "image").
+		AddFile("media", path).
+		AddReader("raw", "b.json", strings.NewReader(`{}`)).SetFileContentType("application/json")
+	resp, err := NewClient(5).PostMultipart(context.Background(), srv.URL, form, 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "image a.txt=file content(application/octet-stream) b.json={}(application/json)"; resp != want {
+		t.Fatalf("resp = %q, want %q", resp, want)
+	}
+	if contentLength <= 0 || contentLength != form.ContentLength() {
+		t.Fatalf("Content-Length %d, form %d", contentLength, form.ContentLength())
+	}
+}
+
+func TestMultipartFormErrors(t *testing.T) {
+	if _, err := NewMultipartForm().AddFile("media", filepath.Join(os.TempDir(), "ghttp-missing-file")).Body(context.Background()); err == nil {
+		t.Fatal("missing file accepted")
+	}
+	if _, err := NewMultipartForm().AddFile("media", t.TempDir()).Body(context.Background()); err == nil {
+		t.Fatal("directory accepted")
+	}
+	form := NewMultipartForm().AddReader("raw", "r", ioutil.NopCloser(strings.NewReader("x")))
+	if form.ContentLength() != -1 {
+		t.Fatalf("unknown size ContentLength = %d, want -1", form.ContentLength())
+	}
+}
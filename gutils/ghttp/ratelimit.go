@@ -0,0 +1,102 @@
+package ghttp
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	gmap "ackevin.com/gutils/gcontainer"
+	"golang.org/x/time/rate"
+)
+
+//ErrRateLimited 请求超出客户端限流（不等待模式）
+var ErrRateLimited = errors.New("client rate limit exceeded")
+
+//RateLimitRule 限流规则（令牌桶）
+type RateLimitRule struct {
+	Host  string  //主机 例："api.weixin.qq.com"，为空或 "*" 匹配所有主机（每个主机单独计数）
+	Path  string  //路径前缀 例："/cgi-bin/user/info"，为空匹配所有路径
+	Limit float64 //每秒请求数 | 每分钟 N 次可写为 N/60.0
+	Burst int     //突发请求数，<=0 时为 1
+}
+
+//RateLimiter 客户端限流器 | 请求按顺序匹配第一条规则，未匹配任何规则的请求不限流
+type RateLimiter struct {
+	rules    []RateLimitRule
+	wait     bool
+	limiters gmap.ConcurrentMapOf[string, *rate.Limiter]
+}
+
+//NewRateLimiter 创建限流器
+//wait 为 true 时超出限流等待令牌（受请求 context 控制，等待时间超过 context 截止时间则立即返回错误），
+//为 false 时直接返回 ErrRateLimited
+func NewRateLimiter(wait bool, rules ...RateLimitRule) *RateLimiter {
+	return &RateLimiter{rules: rules, wait: wait, limiters: gmap.NewConcurrentMapOf[*rate.Limiter]()}
+}
+
+//match 匹配规则，返回令牌桶 key 和规则
+func (l *RateLimiter) match(req *http.Request) (string, *RateLimitRule) {
+	host := req.URL.Host
+	for i := range l.rules {
+		rule := &l.rules[i]
+		if rule.Host != "" && rule.Host != "*" && !strings.EqualFold(rule.Host, req.URL.Hostname()) && !strings.EqualFold(rule.Host, host) {
+			continue
+		}
+		if rule.Path != "" && !strings.HasPrefix(req.URL.Path, rule.Path) {
+			continue
+		}
+		return fmt.Sprintf("%d|%s", i, host), rule
+	}
+	return "", nil
+}
+
+//limiter 获取令牌桶，不存在则创建（Upsert 保证并发时只创建一个）
+func (l *RateLimiter) limiter(key string, rule *RateLimitRule) *rate.Limiter {
+	if lim, ok := l.limiters.Get(key); ok {
+		return lim
+	}
+	burst := rule.Burst
+	if burst <= 0 {
+		burst = 1
+	}
+	return l.limiters.Upsert(key, nil, func(exist bool, old *rate.Limiter, _ *rate.Limiter) *rate.Limiter {
+		if exist {
+			return old
+		}
+		return rate.NewLimiter(rate.Limit(rule.Limit), burst)
+	})
+}
+
+//Take 为请求获取令牌，未匹配规则直接返回 nil
+func (l *RateLimiter) Take(req *http.Request) error {
+	key, rule := l.match(req)
+	if rule == nil {
+		return nil
+	}
+	lim := l.limiter(key, rule)
+	if !l.wait {
+		if !lim.Allow() {
+			return fmt.Errorf("%w: %s%s", ErrRateLimited, req.URL.Host, req.URL.Path)
+		}
+		return nil
+	}
+	if err := lim.Wait(req.Context()); err != nil {
+		return fmt.Errorf("%w: %s%s %s", ErrRateLimited, req.URL.Host, req.URL.Path, err)
+	}
+	return nil
+}
+
+//RateLimitMiddleware 客户端限流中间件 | 超出限流返回的错误可用 errors.Is(err, ErrRateLimited) 判断
+//例（微信用户信息接口每分钟 600 次）：
+//	ghttp.Use(ghttp.RateLimitMiddleware(ghttp.NewRateLimiter(true, ghttp.RateLimitRule{Host: "api.weixin.qq.com", Path: "/cgi-bin/user/info", Limit: 600 / 60.0, Burst: 10})))
+func RateLimitMiddleware(l *RateLimiter) Middleware {
+	return func(next http.RoundTripper) http.RoundTripper {
+		return RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			if err := l.Take(req); err != nil {
+				return nil, err
+			}
+			return next.RoundTrip(req)
+		})
+	}
+}
@@ -0,0 +1,53 @@
+package ghttp
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestRateLimiterTake(t *testing.T) {
+	l := NewRateLimiter(false,
+		RateLimitRule{Host: "api.weixin.qq.com", Path: "/cgi-bin/user/info", Limit: 1, Burst: 2},
+		RateLimitRule{Host: "*", Limit: 1},
+	)
+	req := func(url string) *http.Request {
+		r, _ := http.NewRequest("GET", url, nil)
+		return r
+	}
+	for i := 0; i < 2; i++ {
+		if err := l.Take(req("https://api.weixin.qq.com/cgi-bin/user/info?openid=1")); err != nil {
+			t.Fatalf("burst request %d: %v", i, err)
+		}
+	}
+	if err := l.Take(req("https://api.weixin.qq.com/cgi-bin/user/info")); !errors.Is(err, ErrRateLimited) {
+		t.Fatalf("err = %v, want ErrRateLimited", err)
+	}
+	//"*" 规则每个主机单独计数
+	if err := l.Take(req("https://a.example.com/")); err != nil {
+		t.Fatal(err)
+	}
+	if err := l.Take(req("https://b.example.com/")); err != nil {
+		t.Fatal(err)
+	}
+	if err := l.Take(req("https://a.example.com/")); !errors.Is(err, ErrRateLimited) {
+		t.Fatalf("err = %v, want ErrRateLimited", err)
+	}
+}
+
+func TestRateLimiterWait(t *testing.T) {
+	l := NewRateLimiter(true, RateLimitRule{Limit: 0.1})
+	r, _ := http.NewRequest("GET", "https://api.example.com/", nil)
+	if err := l.Take(r); err != nil {
+		t.Fatal(err)
+	}
+	//下一个令牌需要等待 10 秒，超过 context 截止时间立即返回
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	begin := time.Now()
+	if err := l.Take(r.WithContext(ctx)); !errors.Is(err, ErrRateLimited) || time.Since(begin) > time.Second {
+		t.Fatalf("err = %v after %s", err, time.Since(begin))
+	}
+}
@@ -0,0 +1,367 @@
+package ghttp
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+//ErrFixtureNotFound 回放模式下没有匹配的录制文件
+var ErrFixtureNotFound = errors.New("ghttp fixture not found")
+
+//RecordMode 录制模式
+type RecordMode int
+
+//录制模式
+const (
+	RecordModeReplay RecordMode = iota //只回放，没有录制文件返回 ErrFixtureNotFound（不会发出真实请求）
+	RecordModeRecord                   //总是发出真实请求并覆盖录制文件
+	RecordModeAuto                     //有录制文件则回放，没有则发出真实请求并录制
+)
+
+//RecorderOptions 录制器配置
+type RecorderOptions struct {
+	Mode         RecordMode //录制模式，默认 RecordModeReplay
+	RedactKeys   []string   //录制时脱敏的参数名（url参数、form、json字段，响应内容及响应报文头同样脱敏），为空则使用 DefaultRedactKeys；脱敏后的值也用于匹配，不同的 token 可匹配同一录制文件
+	IgnoreParams []string   //匹配时忽略的参数名，默认 timestamp、nonce、noncestr、sign、signature
+}
+
+//Fixture 录制文件内容
+type Fixture struct {
+	Request    FixtureRequest  `json:"request"`
+	Response   FixtureResponse `json:"response"`
+	RecordedAt time.Time       `json:"recorded_at"`
+}
+
+//FixtureRequest 录制的请求（已脱敏）
+type FixtureRequest struct {
+	Method      string `json:"method"`
+	URL         string `json:"url"`
+	ContentType string `json:"content_type,omitempty"`
+	Body        string `json:"body,omitempty"`
+}
+
+//FixtureResponse 录制的响应 | 非 UTF-8 内容以 base64 保存（BodyEncoding 为 "base64"）
+type FixtureResponse struct {
+	StatusCode   int         `json:"status_code"`
+	Header       http.Header `json:"header,omitempty"`
+	Body         string      `json:"body"`
+	BodyEncoding string      `json:"body_encoding,omitempty"`
+}
+
+/*Recorder 请求录制/回放 | 录制文件为 JSON，每个请求一个文件，可手工编辑或提交到仓库作为测试数据
+请求按 方法+地址+参数+内容 匹配录制文件（参数按名称排序，脱敏参数以 *** 参与匹配，IgnoreParams 不参与匹配）
+例（测试中回放 Code2Session，首次以 RecordModeAuto 运行录制）：
+	old := ghttp.DefaultClient
+	ghttp.DefaultClient = ghttp.NewClient(10)
+	ghttp.DefaultClient.Use(ghttp.RecordMiddleware(ghttp.NewRecorder("testdata/fixtures", nil)))
+	defer func() { ghttp.DefaultClient = old }()
+	userinfo, err := gmpwx.Code2Session(appid, secret, code)
+*/
+type Recorder struct {
+	dir    string
+	mode   RecordMode
+	redact map[string]bool
+	ignore map[string]bool
+	mu     sync.Mutex
+}
+
+//NewRecorder 创建录制器 dir 录制文件目录，opts 为空使用默认配置（只回放）
+func NewRecorder(dir string, opts *RecorderOptions) *Recorder {
+	var o RecorderOptions
+	if opts != nil {
+		o = *opts
+	}
+	if len(o.RedactKeys) == 0 {
+		o.RedactKeys = DefaultRedactKeys
+	}
+	if len(o.IgnoreParams) == 0 {
+		o.IgnoreParams = []string{"timestamp", "nonce", "noncestr", "sign", "signature"}
+	}
+	r := &Recorder{dir: dir, mode: o.Mode, redact: map[string]bool{}, ignore: map[string]bool{}}
+	for _, k := range o.RedactKeys {
+		r.redact[strings.ToLower(k)] = true
+	}
+	for _, k := range o.IgnoreParams {
+		r.ignore[strings.ToLower(k)] = true
+	}
+	return r
+}
+
+//RecordMiddleware 录制/回放中间件 | 回放时不会发出真实请求；也可包装底层传输：
+//	client.SetTransport(ghttp.RecordMiddleware(rec)(http.DefaultTransport))
+func RecordMiddleware(r *Recorder) Middleware {
+	return func(next http.RoundTripper) http.RoundTripper {
+		return RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			var body []byte
+			if req.Body != nil && req.Body != http.NoBody {
+				var err error
+				if body, err = ioutil.ReadAll(req.Body); err != nil {
+					return nil, err
+				}
+				req.Body.Close()
+				req = cloneRequest(req)
+				req.Body = ioutil.NopCloser(bytes.NewReader(body))
+			}
+			fixtureReq := r.fixtureRequest(req, body)
+			path := filepath.Join(r.dir, r.fixtureName(req, fixtureReq))
+			if r.mode != RecordModeRecord {
+				f, err := r.load(path)
+				if err == nil {
+					return f.response(req), nil
+				}
+				if r.mode == RecordModeReplay {
+					return nil, fmt.Errorf("%w: %s %s (%s)", ErrFixtureNotFound, fixtureReq.Method, fixtureReq.URL, path)
+				}
+			}
+			resp, err := next.RoundTrip(req)
+			if err != nil {
+				return resp, err
+			}
+			respBody, err := ioutil.ReadAll(resp.Body)
+			resp.Body.Close()
+			if err != nil {
+				return nil, err
+			}
+			resp.Body = ioutil.NopCloser(bytes.NewReader(respBody))
+			f := &Fixture{Request: *fixtureReq, RecordedAt: time.Now()}
+			f.Response.StatusCode = resp.StatusCode
+			f.Response.Header = r.redactHeader(resp.Header)
+			if utf8.Valid(respBody) {
+				f.Response.Body = r.redactResponseBody(respBody, resp.Header.Get("Content-Type"))
+			} else {
+				f.Response.Body = base64.StdEncoding.EncodeToString(respBody)
+				f.Response.BodyEncoding = "base64"
+			}
+			if err := r.save(path, f); err != nil {
+				return nil, fmt.Errorf("ghttp record %s: %s", path, err)
+			}
+			return resp, nil
+		})
+	}
+}
+
+//fixtureRequest 脱敏并去掉忽略参数后的请求，用于匹配和保存
+func (r *Recorder) fixtureRequest(req *http.Request, body []byte) *FixtureRequest {
+	u := *req.URL
+	u.RawQuery = r.normalizeQuery(u.RawQuery)
+	u.Fragment = ""
+	contentType := req.Header.Get("Content-Type")
+	fr := &FixtureRequest{Method: req.Method, URL: u.String(), ContentType: contentType}
+	if len(body) == 0 {
+		return fr
+	}
+	trimmed := bytes.TrimSpace(body)
+	switch {
+	case strings.Contains(contentType, "x-www-form-urlencoded"):
+		fr.Body = r.normalizeQuery(string(body))
+	case strings.Contains(contentType, "json") || (len(trimmed) > 0 && (trimmed[0] == '{' || trimmed[0] == '[')):
+		var v interface{}
+		if json.Unmarshal(trimmed, &v) == nil {
+			if m, ok := v.(map[string]interface{}); ok {
+				for k := range m {
+					if r.ignore[strings.ToLower(k)] {
+						delete(m, k)
+					}
+				}
+			}
+			b, _ := json.Marshal(redactJSON(v, r.redact)) //map 按键排序输出
+			fr.Body = string(b)
+		} else {
+			fr.Body = string(body)
+		}
+	case utf8.Valid(body):
+		fr.Body = string(body)
+	default:
+		sum := sha1.Sum(body)
+		fr.Body = "sha1:" + hex.EncodeToString(sum[:])
+	}
+	return fr
+}
+
+//redactHeader 复制响应报文头，去掉 Set-Cookie、Date 并对脱敏参数名对应的报文头脱敏
+func (r *Recorder) redactHeader(header http.Header) http.Header {
+	h := header.Clone()
+	h.Del("Set-Cookie")
+	h.Del("Date")
+	for k := range h {
+		if r.redact[strings.ToLower(k)] {
+			h[k] = []string{redactedValue}
+		}
+	}
+	return h
+}
+
+//redactResponseBody 响应内容脱敏（如 access_token 接口返回的 token）| json 数字按原文保留，避免大整数丢失精度
+func (r *Recorder) redactResponseBody(body []byte, contentType string) string {
+	trimmed := bytes.TrimSpace(body)
+	if strings.Contains(contentType, "json") || (len(trimmed) > 0 && (trimmed[0] == '{' || trimmed[0] == '[')) {
+		var v interface{}
+		dec := json.NewDecoder(bytes.NewReader(trimmed))
+		dec.UseNumber()
+		if dec.Decode(&v) != nil || dec.More() {
+			return string(body)
+		}
+		b, _ := json.Marshal(redactJSON(v, r.redact))
+		return string(b)
+	}
+	return redactBody(body, contentType, r.redact)
+}
+
+//normalizeQuery 参数排序、脱敏并去掉忽略参数
+func (r *Recorder) normalizeQuery(raw string) string {
+	if raw == "" {
+		return ""
+	}
+	values, err := url.ParseQuery(raw)
+	if err != nil {
+		return raw
+	}
+	for k := range values {
+		switch {
+		case r.ignore[strings.ToLower(k)]:
+			delete(values, k)
+		case r.redact[strings.ToLower(k)]:
+			values[k] = []string{redactedValue}
+		}
+	}
+	return values.Encode()
+}
+
+//fixtureNameRegexp 文件名中需要替换的字符
+var fixtureNameRegexp = regexp.MustCompile(`[^A-Za-z0-9.\-]+`)
+
+//fixtureName 录制文件名 例：get_api.weixin.qq.com_sns_jscode2session_1a2b3c4d5e.json
+func (r *Recorder) fixtureName(req *http.Request, fr *FixtureRequest) string {
+	name := fixtureNameRegexp.ReplaceAllString(req.URL.Host+req.URL.Path, "_")
+	if len(name) > 80 {
+		name = name[:80]
+	}
+	sum := sha1.Sum([]byte(fr.Method + " " + fr.URL + "\n" + fr.Body))
+	return strings.ToLower(fr.Method) + "_" + strings.Trim(name, "_") + "_" + hex.EncodeToString(sum[:5]) + ".json"
+}
+
+//load 读取录制文件
+func (r *Recorder) load(path string) (*Fixture, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	f := &Fixture{}
+	if err := json.Unmarshal(data, f); err != nil {
+		return nil, fmt.Errorf("ghttp fixture %s: %s", path, err)
+	}
+	return f, nil
+}
+
+//save 写入录制文件（先写临时文件再改名）
+func (r *Recorder) save(path string, f *Fixture) error {
+	data, err := json.MarshalIndent(f, "", "  ")
+	if err != nil {
+		return err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := ioutil.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+//response 由录制文件构造响应
+func (f *Fixture) response(req *http.Request) *http.Response {
+	body := []byte(f.Response.Body)
+	if f.Response.BodyEncoding == "base64" {
+		body, _ = base64.StdEncoding.DecodeString(f.Response.Body)
+	}
+	header := f.Response.Header.Clone()
+	if header == nil {
+		header = http.Header{}
+	}
+	header.Del("Content-Length")
+	header.Del("Content-Encoding")
+	status := f.Response.StatusCode
+	if status == 0 {
+		status = http.StatusOK
+	}
+	return &http.Response{
+		Status:        strconv.Itoa(status) + " " + http.StatusText(status),
+		StatusCode:    status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          ioutil.NopCloser(bytes.NewReader(body)),
+		ContentLength: int64(len(body)),
+		Request:       req,
+	}
+}
+
+//NewRewriteHostMiddleware 将请求主机改写到其他地址，用于测试时把固定的外部接口地址指向本地 httptest.Server
+//rules 键为原主机（如 "api.weixin.qq.com"，带端口时需完全一致），值为目标地址（如 srv.URL "http://127.0.0.1:12345"），目标地址无效返回错误
+//原主机写入 X-Forwarded-Host 报文头
+func NewRewriteHostMiddleware(rules map[string]string) (Middleware, error) {
+	targets := make(map[string]*url.URL, len(rules))
+	for host, target := range rules {
+		u, err := url.Parse(target)
+		if err != nil || u.Host == "" {
+			return nil, fmt.Errorf("ghttp rewrite host: invalid target '%s' for '%s'", target, host)
+		}
+		targets[strings.ToLower(host)] = u
+	}
+	return rewriteHost(targets), nil
+}
+
+//RewriteHostMiddleware 同 NewRewriteHostMiddleware，目标地址无效时 panic（用于目标地址固定的测试代码）例：
+//	ghttp.DefaultClient.Use(ghttp.RewriteHostMiddleware(map[string]string{"api.weixin.qq.com": srv.URL}))
+func RewriteHostMiddleware(rules map[string]string) Middleware {
+	mw, err := NewRewriteHostMiddleware(rules)
+	if err != nil {
+		panic(err)
+	}
+	return mw
+}
+
+//rewriteHost 按目标地址改写请求主机
+func rewriteHost(targets map[string]*url.URL) Middleware {
+	return func(next http.RoundTripper) http.RoundTripper {
+		return RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			target, ok := targets[strings.ToLower(req.URL.Host)]
+			if !ok {
+				target, ok = targets[strings.ToLower(req.URL.Hostname())]
+			}
+			if !ok {
+				return next.RoundTrip(req)
+			}
+			r := cloneRequest(req)
+			r.Header.Set("X-Forwarded-Host", req.URL.Host)
+			r.URL.Scheme = target.Scheme
+			r.URL.Host = target.Host
+			if p := strings.TrimSuffix(target.Path, "/"); p != "" {
+				r.URL.Path = p + r.URL.Path
+				r.URL.RawPath = ""
+			}
+			r.Host = ""
+			return next.RoundTrip(r)
+		})
+	}
+}
@@ -0,0 +1,104 @@
+package ghttp
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestRecordMiddleware(t *testing.T) {
+	hits := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits++
+		w.Write([]byte(`{"openid":"o1"}`))
+	}))
+	defer srv.Close()
+	dir := t.TempDir()
+	get := func(mode RecordMode, query string) (string, error) {
+		c := NewClient(5)
+		c.Use(RecordMiddleware(NewRecorder(dir, &RecorderOptions{Mode: mode})))
+		return c.BaseRequest(context.Background(), srv.URL+"/sns/jscode2session", query, "", "GET", 5)
+	}
+
+	c := NewClient(5)
+	c.Use(RecordMiddleware(NewRecorder(dir, nil)))
+	req, _ := http.NewRequest("GET", srv.URL+"/sns/jscode2session?appid=a", nil)
+	if _, err := c.Do(req); !errors.Is(err, ErrFixtureNotFound) || hits != 0 {
+		t.Fatalf("replay without fixture err %v after %d hits", err, hits)
+	}
+	if resp, err := get(RecordModeAuto, "appid=a&secret=s1&timestamp=1"); err != nil || resp != `{"openid":"o1"}` || hits != 1 {
+		t.Fatalf("record %q err %v after %d hits", resp, err, hits)
+	}
+	//脱敏参数及忽略参数不同也匹配同一录制文件
+	if resp, err := get(RecordModeReplay, "secret=s2&appid=a&timestamp=2"); err != nil || resp != `{"openid":"o1"}` || hits != 1 {
+		t.Fatalf("replay %q err %v after %d hits", resp, err, hits)
+	}
+	files, _ := ioutil.ReadDir(dir)
+	if len(files) != 1 {
+		t.Fatalf("%d fixture files, want 1", len(files))
+	}
+	data, _ := ioutil.ReadFile(dir + "/" + files[0].Name())
+	if strings.Contains(string(data), "secret=s1") || strings.Contains(string(data), "timestamp") {
+		t.Fatalf("fixture not redacted: %s", data)
+	}
+}
+
+func TestRecordRedactsResponse(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Access_token", "header-token")
+		w.Write([]byte(`{"access_token":"tok-123","expires_in":7200,"openid":12345678901234567890}`))
+	}))
+	defer srv.Close()
+	dir := t.TempDir()
+	c := NewClient(5)
+	c.Use(RecordMiddleware(NewRecorder(dir, &RecorderOptions{Mode: RecordModeRecord})))
+	resp, err := c.BaseRequest(context.Background(), srv.URL+"/cgi-bin/token", "grant_type=client_credential", "", "GET", 5)
+	if err != nil || !strings.Contains(resp, "tok-123") {
+		t.Fatalf("live response %q err %v", resp, err)
+	}
+
+	files, _ := ioutil.ReadDir(dir)
+	data, _ := ioutil.ReadFile(dir + "/" + files[0].Name())
+	if strings.Contains(string(data), "tok-123") || strings.Contains(string(data), "header-token") {
+		t.Fatalf("fixture not redacted: %s", data)
+	}
+	c = NewClient(5)
+	c.Use(RecordMiddleware(NewRecorder(dir, nil)))
+	resp, err = c.BaseRequest(context.Background(), srv.URL+"/cgi-bin/token", "grant_type=client_credential", "", "GET", 5)
+	want := `{"access_token":"***","expires_in":7200,"openid":12345678901234567890}`
+	if err != nil || resp != want {
+		t.Fatalf("replay %q err %v, want %q", resp, err, want)
+	}
+}
+
+func TestRewriteHostMiddleware(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.Header.Get("X-Forwarded-Host") + " " + r.URL.Path))
+	}))
+	defer srv.Close()
+	mw, err := NewRewriteHostMiddleware(map[string]string{"API.weixin.qq.com": srv.URL + "/mock/"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := NewClient(5)
+	c.Use(mw)
+	resp, err := c.BaseRequest(context.Background(), "https://api.weixin.qq.com/cgi-bin/token", "", "", "GET", 5)
+	if err != nil || resp != "api.weixin.qq.com /mock/cgi-bin/token" {
+		t.Fatalf("resp %q err %v", resp, err)
+	}
+
+	if _, err := NewRewriteHostMiddleware(map[string]string{"api.weixin.qq.com": "127.0.0.1:8080"}); err == nil {
+		t.Fatal("target without scheme accepted")
+	}
+	defer func() {
+		if recover() == nil {
+			t.Fatal("RewriteHostMiddleware did not panic on invalid target")
+		}
+	}()
+	RewriteHostMiddleware(map[string]string{"api.weixin.qq.com": "::bad"})
+}
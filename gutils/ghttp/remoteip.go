@@ -0,0 +1,182 @@
+package ghttp
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+)
+
+//客户端IP相关报文头
+const (
+	HeaderXForwardedFor = "X-Forwarded-For"
+	HeaderXRealIP       = "X-Real-IP"
+	HeaderForwarded     = "Forwarded" //RFC 7239
+)
+
+//PrivateCIDRs 内网及本机地址段，DefaultIPResolver 默认信任这些地址的代理
+var PrivateCIDRs = []string{"127.0.0.0/8", "10.0.0.0/8", "172.16.0.0/12", "192.168.0.0/16", "::1/128", "fc00::/7"}
+
+/*IPResolver 客户端IP解析器
+只有直连地址（req.RemoteAddr）属于可信代理时才读取代理报文头，直连地址无法解析时也不读取，避免客户端伪造IP：
+	Remote_addr、X-Real-IP 取报文头中的IP
+	X-Forwarded-For、Forwarded 从右往左跳过可信代理，取第一个不可信的IP；全部可信则取最左边的IP
+*/
+type IPResolver struct {
+	trusted []*net.IPNet
+	headers []string
+}
+
+//DefaultIPResolver 默认解析器 | 信任内网代理（PrivateCIDRs），HTTPRemoteIP 使用该解析器
+var DefaultIPResolver, _ = NewIPResolver(PrivateCIDRs...)
+
+//NewIPResolver 创建客户端IP解析器 trustedProxies 可信代理地址，支持 CIDR 或单个IP
+//默认按 Remote_addr、Forwarded、X-Forwarded-For、X-Real-IP 顺序读取报文头，可用 SetHeaders 修改
+func NewIPResolver(trustedProxies ...string) (*IPResolver, error) {
+	r := &IPResolver{headers: []string{RemoteAddrHeader, HeaderForwarded, HeaderXForwardedFor, HeaderXRealIP}}
+	for _, s := range trustedProxies {
+		if !strings.Contains(s, "/") {
+			ip := net.ParseIP(s)
+			if ip == nil {
+				return nil, fmt.Errorf("invalid trusted proxy '%s'", s)
+			}
+			bits := 128
+			if ip.To4() != nil {
+				ip, bits = ip.To4(), 32
+			}
+			r.trusted = append(r.trusted, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		_, ipnet, err := net.ParseCIDR(s)
+		if err != nil {
+			return nil, fmt.Errorf("invalid trusted proxy '%s'", s)
+		}
+		r.trusted = append(r.trusted, ipnet)
+	}
+	return r, nil
+}
+
+//SetHeaders 设置读取的报文头及顺序，只支持 Remote_addr、X-Real-IP、X-Forwarded-For、Forwarded
+func (r *IPResolver) SetHeaders(headers ...string) *IPResolver {
+	r.headers = headers
+	return r
+}
+
+//IsTrusted 判断IP是否为可信代理
+func (r *IPResolver) IsTrusted(ip net.IP) bool {
+	if ip == nil {
+		return false
+	}
+	for _, ipnet := range r.trusted {
+		if ipnet.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+//ClientIP 解析客户端IP（不带端口号），无法解析返回空字符串
+func (r *IPResolver) ClientIP(req *http.Request) string {
+	peer := ParseIP(req.RemoteAddr)
+	if peer == nil {
+		return ""
+	}
+	if !r.IsTrusted(peer) {
+		return peer.String()
+	}
+	for _, header := range r.headers {
+		var ip net.IP
+		switch http.CanonicalHeaderKey(header) {
+		case http.CanonicalHeaderKey(HeaderXForwardedFor):
+			ip = r.walk(splitList(req.Header.Values(HeaderXForwardedFor)))
+		case HeaderForwarded:
+			ip = r.walk(forwardedFor(req.Header.Values(HeaderForwarded)))
+		default:
+			ip = ParseIP(strings.TrimSpace(req.Header.Get(header)))
+		}
+		if ip != nil {
+			return ip.String()
+		}
+	}
+	return peer.String()
+}
+
+//walk 从右往左跳过可信代理，返回第一个不可信的IP，全部可信则返回最左边的IP
+func (r *IPResolver) walk(chain []string) net.IP {
+	var leftmost net.IP
+	for i := len(chain) - 1; i >= 0; i-- {
+		ip := ParseIP(chain[i])
+		if ip == nil {
+			//无法解析（如 unknown、混淆标识）时真实IP未知
+			return nil
+		}
+		if !r.IsTrusted(ip) {
+			return ip
+		}
+		leftmost = ip
+	}
+	return leftmost
+}
+
+//Handler http中间件 | 将解析出的客户端IP存入请求上下文（RemoteAddrFromContext 读取），
+//同一上下文发出的请求经 RemoteAddrMiddleware 会自动带上 Remote_addr 报文头
+func (r *IPResolver) Handler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		next.ServeHTTP(w, req.WithContext(WithRemoteAddr(req.Context(), r.ClientIP(req))))
+	})
+}
+
+//ParseIP 解析 IP、IP:端口、[IPv6]:端口 及带引号的地址，无法解析返回 nil
+func ParseIP(s string) net.IP {
+	s = strings.Trim(strings.TrimSpace(s), `"`)
+	if s == "" {
+		return nil
+	}
+	if ip := net.ParseIP(s); ip != nil {
+		return normalizeIP(ip)
+	}
+	if host, _, err := net.SplitHostPort(s); err == nil {
+		s = host
+	}
+	s = strings.TrimSuffix(strings.TrimPrefix(s, "["), "]")
+	//去掉IPv6 zone
+	if i := strings.LastIndex(s, "%"); i > 0 {
+		s = s[:i]
+	}
+	return normalizeIP(net.ParseIP(s))
+}
+
+//normalizeIP IPv4 统一为4字节
+func normalizeIP(ip net.IP) net.IP {
+	if v4 := ip.To4(); v4 != nil {
+		return v4
+	}
+	return ip
+}
+
+//splitList 拆分逗号分隔的多个报文头值
+func splitList(values []string) []string {
+	var list []string
+	for _, v := range values {
+		for _, item := range strings.Split(v, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				list = append(list, item)
+			}
+		}
+	}
+	return list
+}
+
+//forwardedFor 取 RFC 7239 Forwarded 报文头中所有 for= 的值 例：for=192.0.2.60;proto=http, for="[2001:db8::1]:4711"
+func forwardedFor(values []string) []string {
+	var list []string
+	for _, element := range splitList(values) {
+		for _, pair := range strings.Split(element, ";") {
+			kv := strings.SplitN(strings.TrimSpace(pair), "=", 2)
+			if len(kv) == 2 && strings.EqualFold(kv[0], "for") {
+				list = append(list, kv[1])
+			}
+		}
+	}
+	return list
+}
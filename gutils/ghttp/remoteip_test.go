@@ -0,0 +1,49 @@
+package ghttp
+
+import (
+	"net/http/httptest"
+	"testing"
+)
+
+func TestClientIP(t *testing.T) {
+	tests := []struct {
+		name       string
+		remoteAddr string
+		headers    map[string]string
+		want       string
+	}{
+		{"direct client", "203.0.113.7:5000", nil, "203.0.113.7"},
+		{"untrusted peer spoofing", "203.0.113.7:5000", map[string]string{"X-Forwarded-For": "1.2.3.4", "Remote_addr": "1.2.3.4"}, "203.0.113.7"},
+		{"unknown peer spoofing", "@", map[string]string{"X-Forwarded-For": "1.2.3.4", "X-Real-IP": "1.2.3.4"}, ""},
+		{"internal proxy Remote_addr", "10.0.0.2:80", map[string]string{"Remote_addr": "198.51.100.1"}, "198.51.100.1"},
+		{"xff skips trusted hops", "10.0.0.2:80", map[string]string{"X-Forwarded-For": "1.2.3.4, 198.51.100.1, 10.0.0.3"}, "198.51.100.1"},
+		{"xff unknown hop", "10.0.0.2:80", map[string]string{"X-Forwarded-For": "198.51.100.1, unknown"}, "10.0.0.2"},
+		{"forwarded ipv6", "[::1]:80", map[string]string{"Forwarded": `for="[2001:db8::1]:4711";proto=https`}, "2001:db8::1"},
+		{"proxy without headers", "127.0.0.1:80", nil, "127.0.0.1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/", nil)
+			req.RemoteAddr = tt.remoteAddr
+			for k, v := range tt.headers {
+				req.Header.Set(k, v)
+			}
+			if got := DefaultIPResolver.ClientIP(req); got != tt.want {
+				t.Fatalf("ClientIP = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewIPResolver(t *testing.T) {
+	r, err := NewIPResolver("198.51.100.9", "2001:db8::/32")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !r.IsTrusted(ParseIP("198.51.100.9")) || !r.IsTrusted(ParseIP("2001:db8::5")) || r.IsTrusted(ParseIP("10.0.0.1")) {
+		t.Fatal("unexpected trusted set")
+	}
+	if _, err := NewIPResolver("not-an-ip"); err == nil {
+		t.Fatal("invalid proxy accepted")
+	}
+}
@@ -0,0 +1,158 @@
+package ghttp
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"sync"
+
+	"ackevin.com/glog"
+)
+
+//响应信封 status 取值
+const (
+	StatusTrue  = "True"
+	StatusFalse = "False"
+)
+
+//Response 标准响应信封 例：{"type":"10","status":"True","errcode":"","data":"1565595752538","msg":""}（gtools.GetTimeStamp 解析的格式）
+type Response struct {
+	Type    string      `json:"type"`    //业务类型
+	Status  string      `json:"status"`  //StatusTrue 成功 | StatusFalse 失败
+	Errcode string      `json:"errcode"` //错误码，成功为空
+	Data    interface{} `json:"data"`    //数据
+	Msg     string      `json:"msg"`     //提示信息
+}
+
+//StatusError 带http状态码和错误码的错误，WriteError 原样返回 Errcode、Msg
+type StatusError struct {
+	Status  int    //http状态码
+	Errcode string //错误码，为空时使用状态码
+	Msg     string //返回给客户端的提示信息
+	Err     error  //内部错误（只写入日志，不返回给客户端）
+}
+
+//NewStatusError 创建带状态码的错误
+func NewStatusError(status int, errcode, msg string) *StatusError {
+	return &StatusError{Status: status, Errcode: errcode, Msg: msg}
+}
+
+func (e *StatusError) Error() string {
+	if e.Err != nil {
+		return e.Msg + ": " + e.Err.Error()
+	}
+	return e.Msg
+}
+
+func (e *StatusError) Unwrap() error {
+	return e.Err
+}
+
+//errorStatus 错误与http状态码的对应关系
+type errorStatus struct {
+	target error
+	status int
+}
+
+var (
+	errorStatusMu sync.RWMutex
+	errorStatuses = []errorStatus{
+		{ErrBodyTooLarge, http.StatusRequestEntityTooLarge},
+		{ErrSignature, http.StatusUnauthorized},
+		{ErrSignExpired, http.StatusUnauthorized},
+		{ErrSignReplayed, http.StatusUnauthorized},
+		{ErrRateLimited, http.StatusTooManyRequests},
+		{ErrCircuitOpen, http.StatusServiceUnavailable},
+		{context.DeadlineExceeded, http.StatusGatewayTimeout},
+	}
+)
+
+//RegisterErrorStatus 注册错误对应的http状态码（errors.Is 匹配），后注册的优先
+func RegisterErrorStatus(target error, status int) {
+	errorStatusMu.Lock()
+	defer errorStatusMu.Unlock()
+	errorStatuses = append([]errorStatus{{target, status}}, errorStatuses...)
+}
+
+/*ErrorStatus 错误对应的http状态码（按以下顺序匹配）
+	nil：200
+	*StatusError：Status
+	BindErrors、*BindError：400
+	RegisterErrorStatus 注册的错误：注册的状态码
+	ErrBodyTooLarge：413
+	ErrSignature、ErrSignExpired、ErrSignReplayed：401
+	ErrRateLimited：429
+	ErrCircuitOpen：503
+	context.DeadlineExceeded：504
+	其他：500
+*/
+func ErrorStatus(err error) int {
+	if err == nil {
+		return http.StatusOK
+	}
+	var statusErr *StatusError
+	if errors.As(err, &statusErr) {
+		return statusErr.Status
+	}
+	var bindErrs BindErrors
+	var bindErr *BindError
+	if errors.As(err, &bindErrs) || errors.As(err, &bindErr) {
+		return http.StatusBadRequest
+	}
+	errorStatusMu.RLock()
+	defer errorStatusMu.RUnlock()
+	for _, es := range errorStatuses {
+		if errors.Is(err, es.target) {
+			return es.status
+		}
+	}
+	return http.StatusInternalServerError
+}
+
+//WriteJSON 以 JSON 格式写入响应
+func WriteJSON(w http.ResponseWriter, status int, v interface{}) error {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(v); err != nil {
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return err
+	}
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(status)
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+//WriteOK 写入成功响应（200）
+func WriteOK(w http.ResponseWriter, typ string, data interface{}) error {
+	return WriteJSON(w, http.StatusOK, &Response{Type: typ, Status: StatusTrue, Data: data})
+}
+
+/*WriteError 按 ErrorStatus 写入失败响应
+errcode：*StatusError 取 Errcode，其他为http状态码
+msg：*StatusError 取 Msg；4xx 为错误信息；5xx 只返回状态描述，错误详情写入 glog，避免泄露内部信息
+*/
+func WriteError(w http.ResponseWriter, r *http.Request, typ string, err error) error {
+	status := ErrorStatus(err)
+	resp := &Response{Type: typ, Status: StatusFalse, Errcode: strconv.Itoa(status)}
+	var statusErr *StatusError
+	switch {
+	case errors.As(err, &statusErr):
+		if statusErr.Errcode != "" {
+			resp.Errcode = statusErr.Errcode
+		}
+		resp.Msg = statusErr.Msg
+	case status < 500:
+		resp.Msg = err.Error()
+	default:
+		resp.Msg = http.StatusText(status)
+	}
+	if status >= 500 {
+		glog.Printfer("303", "ghttp %s %s 请求ID:%s 状态码:%d 错误:%s", r.Method, r.URL.Path, RequestIDFromContext(r.Context()), status, err)
+	}
+	return WriteJSON(w, status, resp)
+}
@@ -0,0 +1,74 @@
+package ghttp
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestErrorStatus(t *testing.T) {
+	errCustom := errors.New("custom")
+	RegisterErrorStatus(errCustom, http.StatusConflict)
+	tests := []struct {
+		err  error
+		want int
+	}{
+		{nil, 200},
+		{NewStatusError(http.StatusForbidden, "40301", "denied"), 403},
+		{BindErrors{{Field: "name", Reason: "is required"}}, 400},
+		{fmt.Errorf("wrapped: %w", errCustom), 409},
+		{ErrBodyTooLarge, 413},
+		{fmt.Errorf("%w: bad nonce", ErrSignReplayed), 401},
+		{ErrRateLimited, 429},
+		{ErrCircuitOpen, 503},
+		{context.DeadlineExceeded, 504},
+		{errors.New("db down"), 500},
+	}
+	for _, tt := range tests {
+		if got := ErrorStatus(tt.err); got != tt.want {
+			t.Errorf("ErrorStatus(%v) = %d, want %d", tt.err, got, tt.want)
+		}
+	}
+}
+
+func TestWriteError(t *testing.T) {
+	tests := []struct {
+		name        string
+		err         error
+		wantStatus  int
+		wantErrcode string
+		wantMsg     string
+	}{
+		{"status error", &StatusError{Status: 403, Errcode: "40301", Msg: "denied", Err: errors.New("internal detail")}, 403, "40301", "denied"},
+		{"client error", BindErrors{{Field: "name", Reason: "is required"}}, 400, "400", "name: is required"},
+		{"server error hidden", errors.New("db password wrong"), 500, "500", "Internal Server Error"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			WriteError(w, httptest.NewRequest("GET", "/", nil), "10", tt.err)
+			var resp Response
+			if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+				t.Fatal(err)
+			}
+			if w.Code != tt.wantStatus || resp.Status != StatusFalse || resp.Type != "10" || resp.Errcode != tt.wantErrcode || resp.Msg != tt.wantMsg {
+				t.Fatalf("got %d %+v", w.Code, resp)
+			}
+		})
+	}
+}
+
+func TestWriteOK(t *testing.T) {
+	w := httptest.NewRecorder()
+	WriteOK(w, "10", "<a&b>")
+	if w.Code != 200 || w.Header().Get("Content-Type") != "application/json; charset=utf-8" {
+		t.Fatalf("got %d %s", w.Code, w.Header().Get("Content-Type"))
+	}
+	if want := `{"type":"10","status":"True","errcode":"","data":"<a&b>","msg":""}` + "\n"; w.Body.String() != want {
+		t.Fatalf("body %s, want %s", w.Body.String(), want)
+	}
+}
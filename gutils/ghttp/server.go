@@ -0,0 +1,343 @@
+package ghttp
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"ackevin.com/glog"
+	"ackevin.com/gutils/gtimer"
+)
+
+//ServerOptions 服务配置
+type ServerOptions struct {
+	ShutdownTimeout   time.Duration //等待处理中请求完成的最长时间，超时强制关闭连接，默认 30s
+	PreStopDelay      time.Duration //收到退出信号后、停止接收请求前的等待时间（就绪检查已返回 503，等待负载均衡摘除实例），默认 0
+	HookTimeout       time.Duration //关闭回调（含 glog 写入）的最长执行时间，默认 10s
+	LivenessPath      string        //存活检查地址，默认 "/healthz"，"-" 表示不启用
+	ReadinessPath     string        //就绪检查地址，默认 "/readyz"，"-" 表示不启用
+	ReadHeaderTimeout time.Duration //读取请求头超时，默认 10s
+	IdleTimeout       time.Duration //keep-alive 连接空闲超时，默认 120s
+	Signals           []os.Signal   //触发优雅退出的信号，默认 SIGINT、SIGTERM
+	StopTimers        bool          //退出时停止 gtimer 定时器（gtimer.StopAll 为全局操作，停止后不能再启动），默认 false
+}
+
+//shutdownHook 关闭回调
+type shutdownHook struct {
+	name string
+	fn   func(ctx context.Context) error
+}
+
+//readinessCheck 就绪检查
+type readinessCheck struct {
+	name string
+	fn   func(ctx context.Context) error
+}
+
+/*Server 支持优雅退出的http服务
+收到退出信号（或调用 Stop）后：
+	1. 就绪检查返回 503，等待 PreStopDelay
+	2. 停止接收新请求，等待处理中的请求完成（最长 ShutdownTimeout，超时强制关闭；再次收到信号立即强制关闭）
+	3. 设置了 StopTimers 时停止 gtimer 定时器（gtimer.StopAll）
+	4. 按注册顺序执行 OnShutdown 回调（共用 HookTimeout，超时的回调不再等待）
+	5. 将 glog 缓存的日志写入文件（glog.Flush）
+例：
+	srv := ghttp.NewServer(":8080", ghttp.Chain(mux, ghttp.RecoverHandler()), nil)
+	srv.OnShutdown("db", func(ctx context.Context) error { return db.Close() })
+	if err := srv.ListenAndServe(); err != nil {
+		log.Fatal(err)
+	}
+*/
+type Server struct {
+	HTTPServer *http.Server //底层服务，可在启动前修改 TLSConfig 等配置
+	opts       ServerOptions
+	mu         sync.Mutex
+	hooks      []shutdownHook
+	checks     []readinessCheck
+	ready      int32 //是否就绪（启动后为 1，开始退出后为 0）
+	manual     int32 //SetReady(false) 手动标记未就绪
+	stopOnce   sync.Once
+	stop       chan struct{}
+}
+
+//NewServer 创建服务 addr 监听地址 例：":8080"，opts 为空使用默认配置
+func NewServer(addr string, handler http.Handler, opts *ServerOptions) *Server {
+	var o ServerOptions
+	if opts != nil {
+		o = *opts
+	}
+	if o.ShutdownTimeout <= 0 {
+		o.ShutdownTimeout = 30 * time.Second
+	}
+	if o.HookTimeout <= 0 {
+		o.HookTimeout = 10 * time.Second
+	}
+	if o.LivenessPath == "" {
+		o.LivenessPath = "/healthz"
+	}
+	if o.ReadinessPath == "" {
+		o.ReadinessPath = "/readyz"
+	}
+	if o.ReadHeaderTimeout <= 0 {
+		o.ReadHeaderTimeout = 10 * time.Second
+	}
+	if o.IdleTimeout <= 0 {
+		o.IdleTimeout = 120 * time.Second
+	}
+	if len(o.Signals) == 0 {
+		o.Signals = []os.Signal{syscall.SIGINT, syscall.SIGTERM}
+	}
+	if handler == nil {
+		handler = http.DefaultServeMux
+	}
+	s := &Server{opts: o, stop: make(chan struct{})}
+	s.HTTPServer = &http.Server{
+		Addr:              addr,
+		Handler:           s.probeHandler(handler),
+		ReadHeaderTimeout: o.ReadHeaderTimeout,
+		IdleTimeout:       o.IdleTimeout,
+	}
+	return s
+}
+
+//OnShutdown 注册关闭回调，请求处理完成后按注册顺序执行，ctx 在 HookTimeout 后超时
+func (s *Server) OnShutdown(name string, hook func(ctx context.Context) error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.hooks = append(s.hooks, shutdownHook{name, hook})
+}
+
+//AddReadinessCheck 注册就绪检查（如数据库连接），任一检查返回错误时就绪检查返回 503
+func (s *Server) AddReadinessCheck(name string, check func(ctx context.Context) error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.checks = append(s.checks, readinessCheck{name, check})
+}
+
+//SetReady 手动标记是否就绪（如预热完成前标记为 false）
+func (s *Server) SetReady(ready bool) {
+	if ready {
+		atomic.StoreInt32(&s.manual, 0)
+	} else {
+		atomic.StoreInt32(&s.manual, 1)
+	}
+}
+
+//Stop 触发优雅退出（与收到退出信号相同），ListenAndServe 在退出流程完成后返回
+func (s *Server) Stop() {
+	s.stopOnce.Do(func() {
+		close(s.stop)
+	})
+}
+
+//probeHandler 处理存活、就绪检查地址，其他请求交给 next
+func (s *Server) probeHandler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case s.opts.LivenessPath:
+			if s.opts.LivenessPath != "-" {
+				WriteOK(w, "", "ok")
+				return
+			}
+		case s.opts.ReadinessPath:
+			if s.opts.ReadinessPath != "-" {
+				s.serveReadiness(w, r)
+				return
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+//serveReadiness 就绪检查：未启动、正在退出、手动标记未就绪或任一检查失败时返回 503
+func (s *Server) serveReadiness(w http.ResponseWriter, r *http.Request) {
+	if atomic.LoadInt32(&s.ready) == 0 {
+		notReady(w, "shutting down")
+		return
+	}
+	if atomic.LoadInt32(&s.manual) == 1 {
+		notReady(w, "not ready")
+		return
+	}
+	s.mu.Lock()
+	checks := append([]readinessCheck(nil), s.checks...)
+	s.mu.Unlock()
+	var failed []string
+	for _, c := range checks {
+		if err := c.fn(r.Context()); err != nil {
+			failed = append(failed, c.name+": "+err.Error())
+		}
+	}
+	if len(failed) > 0 {
+		notReady(w, strings.Join(failed, "; "))
+		return
+	}
+	WriteOK(w, "", "ready")
+}
+
+//notReady 返回 503（不经过 WriteError，避免探测请求写入错误日志）
+func notReady(w http.ResponseWriter, msg string) {
+	WriteJSON(w, http.StatusServiceUnavailable, &Response{Status: StatusFalse, Errcode: strconv.Itoa(http.StatusServiceUnavailable), Msg: msg})
+}
+
+//ListenAndServe 监听 TCP 地址并提供服务，阻塞到退出流程完成；正常退出返回 nil
+func (s *Server) ListenAndServe() error {
+	l, err := net.Listen("tcp", s.addr())
+	if err != nil {
+		return err
+	}
+	return s.Serve(l)
+}
+
+//ListenAndServeTLS 以 HTTPS 提供服务（证书也可通过 HTTPServer.TLSConfig 设置，此时 certFile、keyFile 传空）
+func (s *Server) ListenAndServeTLS(certFile, keyFile string) error {
+	config := s.HTTPServer.TLSConfig
+	if config == nil {
+		config = &tls.Config{}
+	} else {
+		config = config.Clone()
+	}
+	if !contains(config.NextProtos, "http/1.1") {
+		config.NextProtos = append(config.NextProtos, "http/1.1")
+	}
+	if certFile != "" || keyFile != "" {
+		cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+		if err != nil {
+			return err
+		}
+		config.Certificates = append(config.Certificates, cert)
+	}
+	l, err := net.Listen("tcp", s.addr())
+	if err != nil {
+		return err
+	}
+	return s.Serve(tls.NewListener(l, config))
+}
+
+//addr 监听地址，为空时为 ":http"
+func (s *Server) addr() string {
+	if s.HTTPServer.Addr == "" {
+		return ":http"
+	}
+	return s.HTTPServer.Addr
+}
+
+//Serve 在监听器上提供服务，阻塞到退出流程完成；正常退出返回 nil
+func (s *Server) Serve(l net.Listener) error {
+	signals := make(chan os.Signal, 2)
+	signal.Notify(signals, s.opts.Signals...)
+	defer signal.Stop(signals)
+
+	serveErr := make(chan error, 1)
+	go func() {
+		serveErr <- s.HTTPServer.Serve(l)
+	}()
+	atomic.StoreInt32(&s.ready, 1)
+	glog.Printfer("306", "ghttp 服务已启动 %s", l.Addr())
+
+	select {
+	case err := <-serveErr:
+		atomic.StoreInt32(&s.ready, 0)
+		glog.Debuger("306", "ghttp 服务异常退出 %s", err)
+		s.runHooks()
+		return err
+	case sig := <-signals:
+		glog.Printfer("306", "ghttp 收到信号 %s，开始退出", sig)
+	case <-s.stop:
+		glog.Printfer("306", "ghttp 开始退出")
+	}
+	return s.shutdown(signals)
+}
+
+//shutdown 优雅退出流程
+func (s *Server) shutdown(signals chan os.Signal) error {
+	atomic.StoreInt32(&s.ready, 0)
+	if s.opts.PreStopDelay > 0 {
+		select {
+		case <-time.After(s.opts.PreStopDelay):
+		case <-signals:
+		}
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), s.opts.ShutdownTimeout)
+	defer cancel()
+	//再次收到信号立即强制关闭
+	go func() {
+		select {
+		case <-signals:
+			glog.Printfer("306", "ghttp 再次收到信号，强制关闭")
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+	begin := time.Now()
+	var errs []string
+	if err := s.HTTPServer.Shutdown(ctx); err != nil {
+		glog.Debuger("306", "ghttp 等待请求完成超时（%s），强制关闭连接", time.Since(begin))
+		s.HTTPServer.Close()
+		errs = append(errs, "shutdown: "+err.Error())
+	} else {
+		glog.Printfer("306", "ghttp 请求已处理完成 耗时:%s", time.Since(begin))
+	}
+	errs = append(errs, s.runHooks()...)
+	if len(errs) > 0 {
+		return fmt.Errorf("ghttp server shutdown: %s", strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+//runHooks 停止定时器、执行关闭回调并写入日志，返回回调错误
+func (s *Server) runHooks() []string {
+	ctx, cancel := context.WithTimeout(context.Background(), s.opts.HookTimeout)
+	defer cancel()
+	if s.opts.StopTimers {
+		gtimer.StopAll()
+	}
+	s.mu.Lock()
+	hooks := append([]shutdownHook(nil), s.hooks...)
+	s.mu.Unlock()
+	var errs []string
+	for _, h := range hooks {
+		if err := runHook(ctx, h); err != nil {
+			glog.Debuger("306", "ghttp 关闭回调 %s 失败:%s", h.name, err)
+			errs = append(errs, h.name+": "+err.Error())
+		}
+	}
+	glog.Printfer("306", "ghttp 服务已退出")
+	timeout := s.opts.HookTimeout
+	if deadline, ok := ctx.Deadline(); ok {
+		timeout = time.Until(deadline)
+	}
+	if err := glog.Flush(timeout); err != nil {
+		errs = append(errs, err.Error())
+	}
+	return errs
+}
+
+//runHook 在新协程中执行回调，ctx 超时后不再等待（回调协程继续运行），回调 panic 时转为错误
+func runHook(ctx context.Context, h shutdownHook) error {
+	result := make(chan error, 1)
+	go func() {
+		defer func() {
+			if rec := recover(); rec != nil {
+				result <- fmt.Errorf("panic: %v", rec)
+			}
+		}()
+		result <- h.fn(ctx)
+	}()
+	select {
+	case err := <-result:
+		return err
+	case <-ctx.Done():
+		return fmt.Errorf("timeout: %s", ctx.Err())
+	}
+}
@@ -0,0 +1,88 @@
+package ghttp
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestServerShutdown(t *testing.T) {
+	s := NewServer("127.0.0.1:0", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}), &ServerOptions{HookTimeout: 100 * time.Millisecond})
+	var order []string
+	s.OnShutdown("db", func(ctx context.Context) error {
+		order = append(order, "db")
+		return nil
+	})
+	s.OnShutdown("panic", func(ctx context.Context) error {
+		order = append(order, "panic")
+		panic("boom")
+	})
+	//不响应 ctx 的回调在 HookTimeout 后不再等待
+	block := make(chan struct{})
+	defer close(block)
+	s.OnShutdown("stuck", func(ctx context.Context) error {
+		<-block
+		return nil
+	})
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	done := make(chan error, 1)
+	go func() { done <- s.Serve(l) }()
+
+	url := "http://" + l.Addr().String()
+	waitReady(t, url+"/readyz")
+	if resp, err := http.Get(url + "/healthz"); err != nil || resp.StatusCode != 200 {
+		t.Fatalf("liveness %v %v", resp, err)
+	}
+	s.SetReady(false)
+	if resp, err := http.Get(url + "/readyz"); err != nil || resp.StatusCode != http.StatusServiceUnavailable {
+		t.Fatalf("manual not ready %v %v", resp, err)
+	}
+
+	s.Stop()
+	select {
+	case err = <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("stuck hook blocked shutdown")
+	}
+	if err == nil || !strings.Contains(err.Error(), "panic: panic: boom") || !strings.Contains(err.Error(), "stuck: timeout") {
+		t.Fatalf("err = %v", err)
+	}
+	if got := strings.Join(order, ","); got != "db,panic" {
+		t.Fatalf("hooks ran %s", got)
+	}
+}
+
+func TestServerReadinessCheck(t *testing.T) {
+	s := NewServer("", nil, &ServerOptions{LivenessPath: "-"})
+	s.AddReadinessCheck("db", func(ctx context.Context) error { return errors.New("down") })
+	s.ready = 1
+	w := serve(s.HTTPServer.Handler, httptest.NewRequest("GET", "/readyz", nil))
+	if w.Code != http.StatusServiceUnavailable || !strings.Contains(w.Body.String(), "db: down") {
+		t.Fatalf("readiness %d %s", w.Code, w.Body.String())
+	}
+	//LivenessPath 为 "-" 时交给业务 handler（DefaultServeMux 返回 404）
+	if w := serve(s.HTTPServer.Handler, httptest.NewRequest("GET", "/healthz", nil)); w.Code != http.StatusNotFound {
+		t.Fatalf("disabled liveness %d", w.Code)
+	}
+}
+
+func waitReady(t *testing.T, url string) {
+	for i := 0; i < 100; i++ {
+		if resp, err := http.Get(url); err == nil {
+			resp.Body.Close()
+			if resp.StatusCode == 200 {
+				return
+			}
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatal("server not ready")
+}
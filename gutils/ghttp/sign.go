@@ -0,0 +1,337 @@
+package ghttp
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	gmap "ackevin.com/gutils/gcontainer"
+	"ackevin.com/gutils/gstring"
+)
+
+//签名算法
+const (
+	SignMD5        = "MD5"
+	SignSHA1       = "SHA1"
+	SignSHA256     = "SHA256"
+	SignHMACSHA256 = "HMAC-SHA256"
+)
+
+//签名校验错误
+var (
+	ErrSignature    = errors.New("signature mismatch")
+	ErrSignExpired  = errors.New("signature timestamp expired")
+	ErrSignReplayed = errors.New("signature nonce replayed")
+)
+
+/*Signer 排序参数签名
+待签名字符串：参数按参数名字典序拼接为 "k1=v1&k2=v2"（不做url编码，不含签名参数）
+	MD5、SHA1、SHA256：Secret 不为空时追加 "&key=Secret"（参数名见 SecretParam）后计算哈希
+	HMAC-SHA256：以 Secret 为密钥计算 HMAC
+SignMiddleware 对非表单请求体（JSON 等）计算 SHA256 摘要，作为参数 BodyParam 一并签名
+零值 Signer 可直接使用，空字段在使用时取默认值
+微信 JS-SDK 签名（见 gwxofficialaccount.CreateSignature）即 SHA1、无 Secret；微信支付签名即 MD5、SecretParam "key"、大写
+*/
+type Signer struct {
+	Algorithm      string        //签名算法 SignMD5、SignSHA1、SignSHA256、SignHMACSHA256
+	Secret         string        //密钥
+	SecretParam    string        //非 HMAC 算法时密钥追加的参数名，默认 "key"
+	SignParam      string        //签名参数名，默认 "sign"
+	TimestampParam string        //时间戳（秒）参数名，默认 "timestamp"，"-" 表示不添加
+	NonceParam     string        //随机字符串参数名，默认 "nonce"，"-" 表示不添加
+	BodyParam      string        //请求体摘要参数名，默认 "body_sha256"，"-" 表示不添加
+	SkipEmpty      bool          //空值参数不参与签名
+	UpperCase      bool          //签名使用大写十六进制
+	MaxSkew        time.Duration //校验时允许的时间戳误差，默认 5 分钟
+	nonceOnce      sync.Once
+	nonces         gmap.ConcurrentMapOf[string, int64]
+	verified       int64
+}
+
+//NewSigner 创建签名器（其他字段使用默认值，可在创建后修改）
+func NewSigner(algorithm, secret string) *Signer {
+	return &Signer{
+		Algorithm:      algorithm,
+		Secret:         secret,
+		SecretParam:    "key",
+		SignParam:      "sign",
+		TimestampParam: "timestamp",
+		NonceParam:     "nonce",
+		BodyParam:      "body_sha256",
+		MaxSkew:        5 * time.Minute,
+	}
+}
+
+//param 参数名，未设置时返回默认值
+func param(name, def string) string {
+	if name == "" {
+		return def
+	}
+	return name
+}
+
+//maxSkew 允许的时间戳误差，未设置时为 5 分钟
+func (s *Signer) maxSkew() time.Duration {
+	if s.MaxSkew <= 0 {
+		return 5 * time.Minute
+	}
+	return s.MaxSkew
+}
+
+//SortedParams 参数按参数名字典序拼接为 "k1=v1&k2=v2"（不做url编码）skipEmpty 跳过空值
+func SortedParams(params map[string]string, skipEmpty bool) string {
+	keys := make([]string, 0, len(params))
+	for k, v := range params {
+		if skipEmpty && v == "" {
+			continue
+		}
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var buf bytes.Buffer
+	for i, k := range keys {
+		if i > 0 {
+			buf.WriteByte('&')
+		}
+		buf.WriteString(k)
+		buf.WriteByte('=')
+		buf.WriteString(params[k])
+	}
+	return buf.String()
+}
+
+//Sign 计算参数签名（params 中的签名参数不参与计算）
+func (s *Signer) Sign(params map[string]string) (string, error) {
+	signParam := param(s.SignParam, "sign")
+	p := make(map[string]string, len(params))
+	for k, v := range params {
+		if k != signParam {
+			p[k] = v
+		}
+	}
+	plain := SortedParams(p, s.SkipEmpty)
+	var h hash.Hash
+	switch strings.ToUpper(s.Algorithm) {
+	case SignMD5:
+		h = md5.New()
+	case SignSHA1:
+		h = sha1.New()
+	case SignSHA256:
+		h = sha256.New()
+	case SignHMACSHA256:
+		h = hmac.New(sha256.New, []byte(s.Secret))
+	default:
+		return "", fmt.Errorf("unsupported sign algorithm '%s'", s.Algorithm)
+	}
+	if s.Secret != "" && strings.ToUpper(s.Algorithm) != SignHMACSHA256 {
+		secretParam := param(s.SecretParam, "key")
+		if plain != "" {
+			plain += "&"
+		}
+		plain += secretParam + "=" + s.Secret
+	}
+	h.Write([]byte(plain))
+	sign := hex.EncodeToString(h.Sum(nil))
+	if s.UpperCase {
+		sign = strings.ToUpper(sign)
+	}
+	return sign, nil
+}
+
+//enabled 参数名是否启用
+func enabled(param string) bool {
+	return param != "-"
+}
+
+//bodyDigest 请求体 SHA256 摘要（十六进制），空请求体返回空字符串
+func bodyDigest(body []byte) string {
+	if len(body) == 0 {
+		return ""
+	}
+	sum := sha256.Sum256(body)
+	return hex.EncodeToString(sum[:])
+}
+
+//isForm 请求内容是否为 form 表单
+func isForm(header http.Header) bool {
+	return strings.HasPrefix(header.Get("Content-Type"), "application/x-www-form-urlencoded")
+}
+
+//SignMiddleware 请求签名中间件 | 添加时间戳、随机字符串并签名
+//form 表单请求对 url 参数+表单参数签名，签名等参数写入表单；其他请求对 url 参数+请求体摘要签名，签名等参数写入 url
+func SignMiddleware(s *Signer) Middleware {
+	return func(next http.RoundTripper) http.RoundTripper {
+		return RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			r := cloneRequest(req)
+			query := r.URL.Query()
+			var form url.Values
+			var body []byte
+			if r.Body != nil && r.Body != http.NoBody {
+				var err error
+				body, err = ioutil.ReadAll(r.Body)
+				r.Body.Close()
+				if err != nil {
+					return nil, err
+				}
+				if isForm(r.Header) {
+					if form, err = url.ParseQuery(string(body)); err != nil {
+						return nil, fmt.Errorf("sign form error:%s", err)
+					}
+				}
+			}
+			target := query
+			if form != nil {
+				target = form
+			} else if bodyParam := param(s.BodyParam, "body_sha256"); enabled(bodyParam) {
+				if digest := bodyDigest(body); digest != "" {
+					query.Set(bodyParam, digest)
+				} else {
+					query.Del(bodyParam)
+				}
+			}
+			if p := param(s.TimestampParam, "timestamp"); enabled(p) {
+				target.Set(p, strconv.FormatInt(time.Now().Unix(), 10))
+			}
+			if p := param(s.NonceParam, "nonce"); enabled(p) {
+				target.Set(p, gstring.RandomString(16, ""))
+			}
+			sign, err := s.Sign(mergeValues(query, form))
+			if err != nil {
+				return nil, err
+			}
+			target.Set(param(s.SignParam, "sign"), sign)
+			r.URL.RawQuery = query.Encode()
+			if form != nil {
+				body = []byte(form.Encode())
+			}
+			if body != nil {
+				r.Body = ioutil.NopCloser(bytes.NewReader(body))
+				r.ContentLength = int64(len(body))
+				r.GetBody = func() (io.ReadCloser, error) {
+					return ioutil.NopCloser(bytes.NewReader(body)), nil
+				}
+			}
+			return next.RoundTrip(r)
+		})
+	}
+}
+
+//mergeValues 合并参数，同名参数取第一个值，后面的 values 覆盖前面的
+func mergeValues(values ...url.Values) map[string]string {
+	params := map[string]string{}
+	for _, v := range values {
+		for k := range v {
+			params[k] = v.Get(k)
+		}
+	}
+	return params
+}
+
+//Verify 校验请求签名（服务端）| 校验时间戳误差、随机字符串防重放和签名
+//非表单请求会读取请求体计算摘要（读取后 r.Body 可再次读取）
+func (s *Signer) Verify(r *http.Request) error {
+	var digest string
+	if !isForm(r.Header) && r.Body != nil && r.Body != http.NoBody {
+		body, err := ioutil.ReadAll(r.Body)
+		r.Body.Close()
+		if err != nil {
+			return fmt.Errorf("%w: %s", ErrSignature, err)
+		}
+		r.Body = ioutil.NopCloser(bytes.NewReader(body))
+		digest = bodyDigest(body)
+	}
+	if err := r.ParseForm(); err != nil {
+		return fmt.Errorf("%w: %s", ErrSignature, err)
+	}
+	params := mergeValues(r.URL.Query(), r.PostForm)
+	//摘要以实际请求体为准，请求体被篡改或去掉时签名不一致
+	if bodyParam := param(s.BodyParam, "body_sha256"); enabled(bodyParam) && !isForm(r.Header) {
+		if digest != "" {
+			params[bodyParam] = digest
+		} else {
+			delete(params, bodyParam)
+		}
+	}
+	return s.VerifyParams(params)
+}
+
+//VerifyParams 校验参数签名 | 校验时间戳误差、随机字符串防重放和签名
+func (s *Signer) VerifyParams(params map[string]string) error {
+	now := time.Now()
+	maxSkew := s.maxSkew()
+	if p := param(s.TimestampParam, "timestamp"); enabled(p) {
+		ts, err := strconv.ParseInt(params[p], 10, 64)
+		if err != nil {
+			return fmt.Errorf("%w: invalid timestamp", ErrSignExpired)
+		}
+		if skew := now.Sub(time.Unix(ts, 0)); skew > maxSkew || skew < -maxSkew {
+			return ErrSignExpired
+		}
+	}
+	want, err := s.Sign(params)
+	if err != nil {
+		return err
+	}
+	if !hmac.Equal([]byte(strings.ToLower(want)), []byte(strings.ToLower(params[param(s.SignParam, "sign")]))) {
+		return ErrSignature
+	}
+	if p := param(s.NonceParam, "nonce"); enabled(p) {
+		nonce := params[p]
+		if nonce == "" {
+			return fmt.Errorf("%w: empty nonce", ErrSignReplayed)
+		}
+		s.nonceOnce.Do(func() {
+			s.nonces = gmap.NewConcurrentMapOf[int64]()
+		})
+		//随机字符串保留到时间戳失效为止
+		expires := now.Add(2 * maxSkew).UnixNano()
+		if !s.nonces.SetIfAbsent(nonce, expires) {
+			return ErrSignReplayed
+		}
+		if atomic.AddInt64(&s.verified, 1)%1000 == 0 {
+			go s.cleanNonces()
+		}
+	}
+	return nil
+}
+
+//cleanNonces 清理过期的随机字符串
+func (s *Signer) cleanNonces() {
+	now := time.Now().UnixNano()
+	var expired []string
+	s.nonces.IterCb(func(key string, expires int64) {
+		if expires < now {
+			expired = append(expired, key)
+		}
+	})
+	for _, key := range expired {
+		s.nonces.Remove(key)
+	}
+}
+
+//VerifyHandler 签名校验http中间件 | 校验失败返回 401
+func (s *Signer) VerifyHandler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := s.Verify(r); err != nil {
+			http.Error(w, err.Error(), http.StatusUnauthorized)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
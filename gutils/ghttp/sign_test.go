@@ -0,0 +1,119 @@
+package ghttp
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestSignerSign(t *testing.T) {
+	params := map[string]string{"b": "2", "a": "1", "empty": "", "sign": "ignored"}
+	tests := []struct {
+		name   string
+		signer *Signer
+		want   string
+	}{
+		//sha1("a=1&b=2&empty=")，与 gwxofficialaccount.CreateSignature 的用法相同
+		{"zero value sha1", &Signer{Algorithm: SignSHA1}, "1667a521eebd9cdcc11a225987ed1f761a212fa4"},
+		//md5("a=1&b=2&key=secret")
+		{"md5 skip empty upper", &Signer{Algorithm: SignMD5, Secret: "secret", SkipEmpty: true, UpperCase: true}, "9F565CCD686CFA5DC3B06B3A89E4E3AD"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.signer.Sign(params)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Fatalf("Sign = %s, want %s", got, tt.want)
+			}
+		})
+	}
+	if _, err := (&Signer{Algorithm: "RSA"}).Sign(params); err == nil {
+		t.Fatal("unsupported algorithm accepted")
+	}
+}
+
+//signServer 校验签名的测试服务器，返回收到的请求体
+func signServer(s *Signer) *httptest.Server {
+	return httptest.NewServer(s.VerifyHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		w.Write(body)
+	})))
+}
+
+func TestSignMiddleware(t *testing.T) {
+	//零值 Signer 使用默认参数名、时间误差并自动创建随机字符串缓存
+	verifier := &Signer{Algorithm: SignHMACSHA256, Secret: "secret"}
+	srv := signServer(verifier)
+	defer srv.Close()
+	c := NewClient(5)
+	c.Use(SignMiddleware(&Signer{Algorithm: SignHMACSHA256, Secret: "secret"}))
+
+	tests := []struct {
+		name, contentType, body string
+	}{
+		{"json", "application/json", `{"amount":100}`},
+		{"form", "application/x-www-form-urlencoded", "amount=100"},
+		{"empty", "text/plain", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, err := c.BaseRequest(context.Background(), srv.URL+"?q=1", tt.body, tt.contentType, "POST", 5)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.contentType == "application/json" && resp != tt.body {
+				t.Fatalf("handler read %q, want %q", resp, tt.body)
+			}
+		})
+	}
+}
+
+func TestSignBodyTampered(t *testing.T) {
+	s := NewSigner(SignSHA256, "secret")
+	var signed *http.Request
+	mw := SignMiddleware(s)(RoundTripperFunc(func(r *http.Request) (*http.Response, error) {
+		signed = r
+		return &http.Response{StatusCode: 200, Body: http.NoBody}, nil
+	}))
+	req, _ := http.NewRequest("POST", "http://example.com/pay", strings.NewReader(`{"amount":100}`))
+	req.Header.Set("Content-Type", "application/json")
+	if _, err := mw.RoundTrip(req); err != nil {
+		t.Fatal(err)
+	}
+	if signed.URL.Query().Get("body_sha256") == "" {
+		t.Fatal("body digest not added")
+	}
+
+	tampered := httptest.NewRequest("POST", signed.URL.String(), strings.NewReader(`{"amount":1}`))
+	tampered.Header.Set("Content-Type", "application/json")
+	if err := s.Verify(tampered); !errors.Is(err, ErrSignature) {
+		t.Fatalf("tampered body err = %v, want ErrSignature", err)
+	}
+	body, _ := signed.GetBody()
+	replay := httptest.NewRequest("POST", signed.URL.String(), body)
+	replay.Header.Set("Content-Type", "application/json")
+	if err := s.Verify(replay); err != nil {
+		t.Fatalf("original body err = %v", err)
+	}
+	body, _ = signed.GetBody()
+	replay = httptest.NewRequest("POST", signed.URL.String(), body)
+	replay.Header.Set("Content-Type", "application/json")
+	if err := s.Verify(replay); !errors.Is(err, ErrSignReplayed) {
+		t.Fatalf("replayed nonce err = %v, want ErrSignReplayed", err)
+	}
+}
+
+func TestVerifyParamsExpired(t *testing.T) {
+	s := &Signer{Algorithm: SignMD5, NonceParam: "-"}
+	params := map[string]string{"timestamp": "1000"}
+	params["sign"], _ = s.Sign(params)
+	if err := s.VerifyParams(params); !errors.Is(err, ErrSignExpired) {
+		t.Fatalf("err = %v, want ErrSignExpired", err)
+	}
+}
@@ -0,0 +1,224 @@
+package ghttp
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"ackevin.com/glog"
+	"software.sslmate.com/src/go-pkcs12"
+)
+
+/*TLSConfig 双向TLS（客户端证书）配置
+客户端证书三选一：CertFile+KeyFile（PEM文件） | CertPEM+KeyPEM（内存PEM） | PKCS12File 或 PKCS12Data（.p12/.pfx，微信支付 apiclient_cert.p12 即此格式）
+PKCS#12 支持传统的 3DES/RC2 加密及 OpenSSL 3 默认的 AES 加密，证书链中的中间证书一并发送
+根证书：RootCAFiles、RootCAPEM 都为空时使用系统根证书
+*/
+type TLSConfig struct {
+	CertFile       string        //客户端证书 PEM 文件路径
+	KeyFile        string        //客户端证书私钥 PEM 文件路径
+	CertPEM        []byte        //客户端证书 PEM 内容
+	KeyPEM         []byte        //客户端证书私钥 PEM 内容
+	PKCS12File     string        //PKCS#12 证书文件路径
+	PKCS12Data     []byte        //PKCS#12 证书内容
+	PKCS12Password string        //PKCS#12 证书密码（微信支付默认为商户号）
+	RootCAFiles    []string      //自定义根证书 PEM 文件路径
+	RootCAPEM      []byte        //自定义根证书 PEM 内容
+	ServerName     string        //校验服务端证书使用的域名，为空则使用请求地址的域名
+	ReloadInterval time.Duration //证书文件热加载检查间隔，>0 时握手前按间隔检查证书文件修改时间，变化则重新加载（只对文件形式的证书生效）
+}
+
+//ErrNoClientCert 未配置客户端证书
+var ErrNoClientCert = errors.New("tls config: no client certificate configured")
+
+//certReloader 客户端证书加载器 | 支持按文件修改时间热加载
+type certReloader struct {
+	cfg       *TLSConfig
+	mu        sync.RWMutex
+	cert      *tls.Certificate
+	modTime   time.Time //证书文件最后修改时间
+	checkedAt time.Time //上次检查时间
+}
+
+//newCertReloader 创建证书加载器并立即加载证书，加载失败返回错误
+func newCertReloader(cfg *TLSConfig) (*certReloader, error) {
+	r := &certReloader{cfg: cfg}
+	cert, err := loadClientCert(cfg)
+	if err != nil {
+		return nil, err
+	}
+	r.cert = cert
+	r.modTime = cfg.certModTime()
+	r.checkedAt = time.Now()
+	return r, nil
+}
+
+//GetClientCertificate 供 tls.Config 使用，返回当前证书，到达检查间隔时尝试热加载
+func (r *certReloader) GetClientCertificate(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+	if r.cfg.ReloadInterval > 0 && r.cfg.isFile() {
+		r.maybeReload()
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.cert, nil
+}
+
+//maybeReload 证书文件有修改则重新加载 | 加载失败保留旧证书并记录日志
+//未到检查间隔时只持有读锁，不影响并发握手
+func (r *certReloader) maybeReload() {
+	r.mu.RLock()
+	due := time.Since(r.checkedAt) >= r.cfg.ReloadInterval
+	r.mu.RUnlock()
+	if !due {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	//其他握手已完成本次检查
+	if time.Since(r.checkedAt) < r.cfg.ReloadInterval {
+		return
+	}
+	r.checkedAt = time.Now()
+	modTime := r.cfg.certModTime()
+	if !modTime.After(r.modTime) {
+		return
+	}
+	cert, err := loadClientCert(r.cfg)
+	if err != nil {
+		glog.Debuger("301", "ghttp 客户端证书热加载失败，继续使用旧证书 Err:%s", err)
+		return
+	}
+	r.cert = cert
+	r.modTime = modTime
+	glog.Printfer("301", "ghttp 客户端证书已重新加载")
+}
+
+//isFile 证书是否为文件形式
+func (cfg *TLSConfig) isFile() bool {
+	return cfg.PKCS12File != "" || cfg.CertFile != ""
+}
+
+//certModTime 证书文件中最新的修改时间
+func (cfg *TLSConfig) certModTime() time.Time {
+	var latest time.Time
+	for _, path := range []string{cfg.CertFile, cfg.KeyFile, cfg.PKCS12File} {
+		if path == "" {
+			continue
+		}
+		if info, err := os.Stat(path); err == nil && info.ModTime().After(latest) {
+			latest = info.ModTime()
+		}
+	}
+	return latest
+}
+
+//loadClientCert 按配置加载客户端证书
+func loadClientCert(cfg *TLSConfig) (*tls.Certificate, error) {
+	var cert tls.Certificate
+	var err error
+	switch {
+	case cfg.PKCS12File != "" || len(cfg.PKCS12Data) > 0:
+		data := cfg.PKCS12Data
+		if cfg.PKCS12File != "" {
+			if data, err = ioutil.ReadFile(cfg.PKCS12File); err != nil {
+				return nil, fmt.Errorf("tls config: read pkcs12 file error:%s", err)
+			}
+		}
+		cert, err = parsePKCS12(data, cfg.PKCS12Password)
+	case cfg.CertFile != "" || cfg.KeyFile != "":
+		cert, err = tls.LoadX509KeyPair(cfg.CertFile, cfg.KeyFile)
+	case len(cfg.CertPEM) > 0 || len(cfg.KeyPEM) > 0:
+		cert, err = tls.X509KeyPair(cfg.CertPEM, cfg.KeyPEM)
+	default:
+		return nil, ErrNoClientCert
+	}
+	if err != nil {
+		return nil, fmt.Errorf("tls config: load client certificate error:%s", err)
+	}
+	return &cert, nil
+}
+
+//parsePKCS12 解析 PKCS#12 证书为 tls.Certificate（含证书链）
+func parsePKCS12(data []byte, password string) (tls.Certificate, error) {
+	key, cert, caCerts, err := pkcs12.DecodeChain(data, password)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+	tlsCert := tls.Certificate{
+		Certificate: [][]byte{cert.Raw},
+		PrivateKey:  key,
+		Leaf:        cert,
+	}
+	for _, ca := range caCerts {
+		tlsCert.Certificate = append(tlsCert.Certificate, ca.Raw)
+	}
+	return tlsCert, nil
+}
+
+//rootCAPool 按配置加载根证书，未配置返回 nil（使用系统根证书）
+func rootCAPool(cfg *TLSConfig) (*x509.CertPool, error) {
+	if len(cfg.RootCAFiles) == 0 && len(cfg.RootCAPEM) == 0 {
+		return nil, nil
+	}
+	pool := x509.NewCertPool()
+	for _, path := range cfg.RootCAFiles {
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("tls config: read root ca error:%s", err)
+		}
+		if !pool.AppendCertsFromPEM(data) {
+			return nil, fmt.Errorf("tls config: no certificate found in root ca %s", path)
+		}
+	}
+	if len(cfg.RootCAPEM) > 0 && !pool.AppendCertsFromPEM(cfg.RootCAPEM) {
+		return nil, errors.New("tls config: no certificate found in RootCAPEM")
+	}
+	return pool, nil
+}
+
+//NewTLSTransport 按配置创建带客户端证书的 http.Transport | 证书或根证书加载失败时返回错误
+func NewTLSTransport(cfg *TLSConfig) (*http.Transport, error) {
+	if cfg == nil {
+		return nil, ErrNoClientCert
+	}
+	reloader, err := newCertReloader(cfg)
+	if err != nil {
+		return nil, err
+	}
+	pool, err := rootCAPool(cfg)
+	if err != nil {
+		return nil, err
+	}
+	tr := http.DefaultTransport.(*http.Transport).Clone()
+	tr.TLSClientConfig = &tls.Config{
+		RootCAs:              pool,
+		ServerName:           cfg.ServerName,
+		GetClientCertificate: reloader.GetClientCertificate,
+	}
+	return tr, nil
+}
+
+//SetTLSConfig 为客户端设置双向TLS配置（替换底层传输），加载失败时返回错误且不修改客户端
+func (c *Client) SetTLSConfig(cfg *TLSConfig) error {
+	tr, err := NewTLSTransport(cfg)
+	if err != nil {
+		return err
+	}
+	c.SetTransport(tr)
+	return nil
+}
+
+//NewTLSClient 创建带客户端证书的http客户端，可用于任意请求方法 timeout 默认超时秒数（s）
+func NewTLSClient(timeout int, cfg *TLSConfig) (*Client, error) {
+	c := NewClient(timeout)
+	if err := c.SetTLSConfig(cfg); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
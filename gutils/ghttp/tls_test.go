@@ -0,0 +1,100 @@
+package ghttp
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"software.sslmate.com/src/go-pkcs12"
+)
+
+//newTestCert 生成自签名证书，parent 为空时自签名
+func newTestCert(t *testing.T, cn string, parent *x509.Certificate, parentKey *ecdsa.PrivateKey) (*x509.Certificate, *ecdsa.PrivateKey) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(time.Now().UnixNano()),
+		Subject:               pkix.Name{CommonName: cn},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  parent == nil,
+		BasicConstraintsValid: true,
+	}
+	if parent == nil {
+		parent, parentKey = tmpl, key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, parent, &key.PublicKey, parentKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, _ := x509.ParseCertificate(der)
+	return cert, key
+}
+
+func TestParsePKCS12(t *testing.T) {
+	ca, caKey := newTestCert(t, "test ca", nil, nil)
+	leaf, leafKey := newTestCert(t, "client", ca, caKey)
+	encoders := map[string]*pkcs12.Encoder{
+		"aes (openssl 3 default)": pkcs12.Modern,
+		"3des (legacy)":           pkcs12.LegacyDES,
+	}
+	for name, enc := range encoders {
+		t.Run(name, func(t *testing.T) {
+			data, err := enc.Encode(leafKey, leaf, []*x509.Certificate{ca}, "1230000109")
+			if err != nil {
+				t.Fatal(err)
+			}
+			cert, err := parsePKCS12(data, "1230000109")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(cert.Certificate) != 2 || cert.Leaf.Subject.CommonName != "client" {
+				t.Fatalf("chain length %d, leaf %v", len(cert.Certificate), cert.Leaf.Subject)
+			}
+			if _, err := parsePKCS12(data, "wrong"); err == nil {
+				t.Fatal("wrong password accepted")
+			}
+		})
+	}
+}
+
+func TestCertReloader(t *testing.T) {
+	ca, caKey := newTestCert(t, "test ca", nil, nil)
+	path := filepath.Join(t.TempDir(), "apiclient_cert.p12")
+	write := func(cn string, mod time.Time) {
+		leaf, key := newTestCert(t, cn, ca, caKey)
+		data, err := pkcs12.Modern.Encode(key, leaf, nil, "pw")
+		if err != nil {
+			t.Fatal(err)
+		}
+		ioutil.WriteFile(path, data, 0666)
+		os.Chtimes(path, mod, mod)
+	}
+	write("first", time.Now().Add(-time.Minute))
+
+	r, err := newCertReloader(&TLSConfig{PKCS12File: path, PKCS12Password: "pw", ReloadInterval: time.Hour})
+	if err != nil {
+		t.Fatal(err)
+	}
+	write("second", time.Now())
+	//未到检查间隔，继续使用旧证书
+	cert, _ := r.GetClientCertificate(nil)
+	if cert.Leaf.Subject.CommonName != "first" {
+		t.Fatalf("reloaded before interval: %s", cert.Leaf.Subject.CommonName)
+	}
+	r.checkedAt = time.Now().Add(-2 * time.Hour)
+	cert, _ = r.GetClientCertificate(nil)
+	if cert.Leaf.Subject.CommonName != "second" {
+		t.Fatalf("not reloaded: %s", cert.Leaf.Subject.CommonName)
+	}
+}
@@ -9,16 +9,23 @@ import (
 )
  
 /*
-JSONToMap Json格式化为 jsonqMAP
+JSONToMap Json格式化为 jsonqMAP | 解析失败时返回空对象，需要错误信息请使用 JSONToQuery
 */
 func JSONToMap(bodystring string) *jsonq.JSONQuery {
-	datastring := map[string]interface{}{}
-	dec := json.NewDecoder(strings.NewReader(bodystring)) //configdatastring 是json的数据字符串
-	dec.Decode(&datastring)
-	jqdata := jsonq.NewQuery(datastring)
+	jqdata, err := JSONToQuery(bodystring)
+	if err != nil {
+		jqdata, _ = jsonq.NewQuery(map[string]interface{}{})
+	}
 	return jqdata
 }
 
+/*
+JSONToQuery Json格式化为 jsonq 查询对象，支持顶层为数组或标量的json，解析失败返回错误
+*/
+func JSONToQuery(bodystring string) (*jsonq.JSONQuery, error) {
+	return jsonq.FromString(bodystring)
+}
+
 /*MapToJSON map转json */
 func MapToJSON(v interface{}) string {
 	// 存在html标签转义问题
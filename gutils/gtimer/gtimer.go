@@ -4,9 +4,23 @@ import (
 	"fmt"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 )
 
+var (
+	stopChannel = make(chan struct{}) //关闭后所有定时器退出
+	stopOnce    sync.Once
+)
+
+//StopAll 停止所有 TimedExec、TimedExecWithParams、DelayExec 启动的定时器（正在执行的函数不会被中断），停止后不能再启动
+//用于程序退出前停止定时任务，如 ghttp.Server 的关闭回调
+func StopAll() {
+	stopOnce.Do(func() {
+		close(stopChannel)
+	})
+}
+
 //时间参数
 type timerArgs struct {
 	hour int //小时
@@ -105,7 +119,12 @@ func timerHandle(t timerArgs, f func()) {
 
 		fmt.Println("TimedExec下一次执行间隔时间:", next.Sub(now))
 		timer = time.NewTimer(next.Sub(now))
-		<-timer.C
+		select {
+		case <-timer.C:
+		case <-stopChannel:
+			timer.Stop()
+			return
+		}
 
 		f()
 	}
@@ -138,7 +157,12 @@ func timerHandleWithParams(t timerArgs, f func(...interface{}), params ...interf
 
 		fmt.Println("TimedExec下一次执行间隔时间:", next.Sub(now))
 		timer = time.NewTimer(next.Sub(now))
-		<-timer.C
+		select {
+		case <-timer.C:
+		case <-stopChannel:
+			timer.Stop()
+			return
+		}
 
 		f(params...)
 	}
@@ -214,7 +238,12 @@ func DelayExec(sec int, first bool, f func()) error {
 			next = now.Add(time.Duration(sec) * time.Second)
 			//	fmt.Println("DelayExec下一次执行间隔时间:", next.Sub(now))
 			timer = time.NewTimer(next.Sub(now))
-			<-timer.C
+			select {
+			case <-timer.C:
+			case <-stopChannel:
+				timer.Stop()
+				return
+			}
 			fn()
 		}
 	}(f)
@@ -0,0 +1,474 @@
+package jsonq
+
+import (
+	"encoding"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DecodeOptions configures DecodeWith. A nil *DecodeOptions decodes like Decode.
+type DecodeOptions struct {
+	// WeaklyTyped additionally converts numbers and bools to strings, and
+	// "true"/"false"/"1"/"0" and the numbers 0 and 1 to bools. Numeric strings
+	// are always accepted for number fields, as by the Int and Float getters.
+	WeaklyTyped bool
+	// DisallowUnknownFields fails with a *PathError wrapping ErrUnknownField
+	// when an object has a member that matches no field of the target struct.
+	DisallowUnknownFields bool
+}
+
+// Decode converts the value at path into v, which must be a non-nil pointer,
+// without a round trip through encoding/json text. Struct fields are matched
+// by their json tags (or names, case-insensitively) as by encoding/json, and
+// types implementing json.Unmarshaler or encoding.TextUnmarshaler decode
+// themselves. As with the getters, numeric strings such as "123" are accepted
+// for number fields, integer fields reject fractional values, and
+// time.Duration fields accept "1m30s" or a number of seconds.
+//
+//	var user struct {
+//		OpenID string `json:"openid"`
+//		Age    int    `json:"age"`
+//	}
+//	err := jq.Decode(&user, "data.user")
+func (j *JSONQuery) Decode(v interface{}, s ...string) error {
+	return j.DecodeWith(v, nil, s...)
+}
+
+// DecodeWith is like Decode with options.
+func (j *JSONQuery) DecodeWith(v interface{}, opts *DecodeOptions, s ...string) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() {
+		return fmt.Errorf("jsonq: Decode requires a non-nil pointer, got %T", v)
+	}
+	p := toPath(j.blob, s)
+	val, err := p.resolve(j.blob)
+	if err != nil {
+		return err
+	}
+	d := &decoder{}
+	if opts != nil {
+		d.opts = *opts
+	}
+	return d.decode(val, rv.Elem(), p)
+}
+
+// Get decodes the value at path into a new T (see Decode):
+//
+//	items, err := jsonq.Get[[]Item](jq, "data.items")
+func Get[T any](j *JSONQuery, s ...string) (T, error) {
+	var v T
+	err := j.Decode(&v, s...)
+	return v, err
+}
+
+type decoder struct {
+	opts DecodeOptions
+}
+
+var (
+	jsonUnmarshalerType = reflect.TypeOf((*json.Unmarshaler)(nil)).Elem()
+	textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+	durationType        = reflect.TypeOf(time.Duration(0))
+	numberType          = reflect.TypeOf(json.Number(""))
+)
+
+// errorf returns an ErrTypeMismatch error for decoding val into t.
+func (d *decoder) errorf(p Path, val interface{}, t reflect.Type) error {
+	return &PathError{Err: ErrTypeMismatch, Path: p, Expected: t.String(), Actual: jsonTypeName(val)}
+}
+
+// failed returns an ErrTypeMismatch error for an Unmarshaler that rejected val.
+func (d *decoder) failed(p Path, val interface{}, t reflect.Type, cause error) error {
+	return &PathError{Err: ErrTypeMismatch, Path: p, Expected: t.String(), Actual: jsonTypeName(val), Reason: "rejected by " + t.String(), Cause: cause}
+}
+
+// decode stores val into rv; p is the location of val used in errors.
+func (d *decoder) decode(val interface{}, rv reflect.Value, p Path) error {
+	if val == nil {
+		switch rv.Kind() {
+		case reflect.Ptr, reflect.Interface, reflect.Map, reflect.Slice:
+			rv.Set(reflect.Zero(rv.Type()))
+		}
+		return nil
+	}
+	if rv.Kind() != reflect.Ptr && rv.CanAddr() {
+		if pv := rv.Addr(); pv.Type().Implements(jsonUnmarshalerType) {
+			data, err := json.Marshal(val)
+			if err != nil {
+				return err
+			}
+			if err := pv.Interface().(json.Unmarshaler).UnmarshalJSON(data); err != nil {
+				return d.failed(p, val, rv.Type(), err)
+			}
+			return nil
+		} else if str, ok := val.(string); ok && pv.Type().Implements(textUnmarshalerType) {
+			if err := pv.Interface().(encoding.TextUnmarshaler).UnmarshalText([]byte(str)); err != nil {
+				return d.failed(p, val, rv.Type(), err)
+			}
+			return nil
+		}
+	}
+	switch rv.Type() {
+	case durationType:
+		dur, err := durationFromInterface(val)
+		if err != nil {
+			return d.errorf(p, val, rv.Type())
+		}
+		rv.SetInt(int64(dur))
+		return nil
+	case numberType:
+		dec, err := decimalString(val)
+		if err != nil {
+			return d.errorf(p, val, rv.Type())
+		}
+		if n, ok := val.(json.Number); ok {
+			dec = string(n)
+		}
+		rv.SetString(dec)
+		return nil
+	}
+	switch rv.Kind() {
+	case reflect.Ptr:
+		if rv.IsNil() {
+			rv.Set(reflect.New(rv.Type().Elem()))
+		}
+		return d.decode(val, rv.Elem(), p)
+	case reflect.Interface:
+		if rv.NumMethod() == 0 {
+			rv.Set(reflect.ValueOf(copyValue(val)))
+			return nil
+		}
+		if reflect.TypeOf(val).AssignableTo(rv.Type()) {
+			rv.Set(reflect.ValueOf(val))
+			return nil
+		}
+	case reflect.Struct:
+		if obj, ok := val.(map[string]interface{}); ok {
+			return d.decodeStruct(obj, rv, p)
+		}
+	case reflect.Map:
+		if obj, ok := val.(map[string]interface{}); ok {
+			return d.decodeMap(obj, rv, p)
+		}
+	case reflect.Slice:
+		if str, ok := val.(string); ok && rv.Type().Elem().Kind() == reflect.Uint8 {
+			b, err := base64.StdEncoding.DecodeString(str)
+			if err != nil {
+				return d.errorf(p, val, rv.Type())
+			}
+			rv.SetBytes(b)
+			return nil
+		}
+		if arr, ok := val.([]interface{}); ok {
+			s := reflect.MakeSlice(rv.Type(), len(arr), len(arr))
+			for i, e := range arr {
+				if err := d.decode(e, s.Index(i), p.Append(Segment{Index: i, IsIndex: true})); err != nil {
+					return err
+				}
+			}
+			rv.Set(s)
+			return nil
+		}
+	case reflect.Array:
+		if arr, ok := val.([]interface{}); ok {
+			if len(arr) > rv.Len() {
+				return &PathError{Err: ErrTypeMismatch, Path: p, Expected: rv.Type().String(), Actual: "array", Reason: fmt.Sprintf("%d elements", len(arr))}
+			}
+			for i := 0; i < rv.Len(); i++ {
+				if i >= len(arr) {
+					rv.Index(i).Set(reflect.Zero(rv.Type().Elem()))
+				} else if err := d.decode(arr[i], rv.Index(i), p.Append(Segment{Index: i, IsIndex: true})); err != nil {
+					return err
+				}
+			}
+			return nil
+		}
+	case reflect.String:
+		if str, ok := val.(string); ok {
+			rv.SetString(str)
+			return nil
+		}
+		if d.opts.WeaklyTyped {
+			if b, ok := val.(bool); ok {
+				rv.SetString(strconv.FormatBool(b))
+				return nil
+			}
+			if dec, err := decimalString(val); err == nil {
+				rv.SetString(dec)
+				return nil
+			}
+		}
+	case reflect.Bool:
+		if b, ok := val.(bool); ok {
+			rv.SetBool(b)
+			return nil
+		}
+		if d.opts.WeaklyTyped {
+			if b, ok := weakBool(val); ok {
+				rv.SetBool(b)
+				return nil
+			}
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if _, ok := val.(bool); !ok {
+			i, err := exactInt64(val, rv.Type().String())
+			if err != nil {
+				return atPath(err, p)
+			}
+			if rv.OverflowInt(i) {
+				return &PathError{Err: ErrTypeMismatch, Path: p, Expected: rv.Type().String(), Actual: "number", Reason: "out of range"}
+			}
+			rv.SetInt(i)
+			return nil
+		}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		if _, ok := val.(bool); !ok {
+			u, err := exactUint64(val, rv.Type().String())
+			if err != nil {
+				return atPath(err, p)
+			}
+			if rv.OverflowUint(u) {
+				return &PathError{Err: ErrTypeMismatch, Path: p, Expected: rv.Type().String(), Actual: "number", Reason: "out of range"}
+			}
+			rv.SetUint(u)
+			return nil
+		}
+	case reflect.Float32, reflect.Float64:
+		if _, ok := val.(bool); !ok {
+			if f, err := floatFromInterface(val); err == nil {
+				if rv.OverflowFloat(f) {
+					return &PathError{Err: ErrTypeMismatch, Path: p, Expected: rv.Type().String(), Actual: "number", Reason: "out of range"}
+				}
+				rv.SetFloat(f)
+				return nil
+			}
+		}
+	}
+	return d.errorf(p, val, rv.Type())
+}
+
+// weakBool converts "true"/"false"/"1"/"0" and the numbers 0 and 1 to a bool.
+func weakBool(val interface{}) (bool, bool) {
+	if s, ok := val.(string); ok {
+		b, err := strconv.ParseBool(strings.TrimSpace(s))
+		return b, err == nil
+	}
+	if r, ok := numberRat(val); ok && r.IsInt() && r.Num().IsInt64() {
+		switch r.Num().Int64() {
+		case 0:
+			return false, true
+		case 1:
+			return true, true
+		}
+	}
+	return false, false
+}
+
+func (d *decoder) decodeStruct(obj map[string]interface{}, rv reflect.Value, p Path) error {
+	fields := cachedFields(rv.Type())
+	for key, val := range obj {
+		f := fields.lookup(key)
+		if f == nil {
+			if d.opts.DisallowUnknownFields {
+				return &PathError{Err: ErrUnknownField, Path: p.Append(Segment{Key: key}), Reason: "no such field in " + rv.Type().String()}
+			}
+			continue
+		}
+		fv, ok := fieldByIndex(rv, f.index)
+		if !ok {
+			continue
+		}
+		fp := p.Append(Segment{Key: key})
+		if str, isString := val.(string); isString && f.quoted && fv.Kind() == reflect.Bool {
+			if b, ok := weakBool(str); ok {
+				val = b
+			}
+		}
+		if err := d.decode(val, fv, fp); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// fieldByIndex is like reflect.Value.FieldByIndex but allocates nil embedded
+// struct pointers; it fails for unexported embedded pointers.
+func fieldByIndex(rv reflect.Value, index []int) (reflect.Value, bool) {
+	for i, x := range index {
+		if i > 0 && rv.Kind() == reflect.Ptr {
+			if rv.IsNil() {
+				if !rv.CanSet() {
+					return reflect.Value{}, false
+				}
+				rv.Set(reflect.New(rv.Type().Elem()))
+			}
+			rv = rv.Elem()
+		}
+		rv = rv.Field(x)
+	}
+	return rv, true
+}
+
+func (d *decoder) decodeMap(obj map[string]interface{}, rv reflect.Value, p Path) error {
+	t := rv.Type()
+	kt := t.Key()
+	if rv.IsNil() {
+		rv.Set(reflect.MakeMapWithSize(t, len(obj)))
+	}
+	for key, val := range obj {
+		kv := reflect.New(kt).Elem()
+		switch {
+		case reflect.PtrTo(kt).Implements(textUnmarshalerType):
+			if err := kv.Addr().Interface().(encoding.TextUnmarshaler).UnmarshalText([]byte(key)); err != nil {
+				return &PathError{Err: ErrTypeMismatch, Path: p.Append(Segment{Key: key}), Expected: kt.String(), Actual: "object key", Reason: "rejected by " + kt.String(), Cause: err}
+			}
+		case kt.Kind() == reflect.String:
+			kv.SetString(key)
+		case kt.Kind() >= reflect.Int && kt.Kind() <= reflect.Int64:
+			n, err := strconv.ParseInt(key, 10, 64)
+			if err != nil || kv.OverflowInt(n) {
+				return &PathError{Err: ErrTypeMismatch, Path: p.Append(Segment{Key: key}), Expected: kt.String(), Actual: "object key"}
+			}
+			kv.SetInt(n)
+		case kt.Kind() >= reflect.Uint && kt.Kind() <= reflect.Uintptr:
+			n, err := strconv.ParseUint(key, 10, 64)
+			if err != nil || kv.OverflowUint(n) {
+				return &PathError{Err: ErrTypeMismatch, Path: p.Append(Segment{Key: key}), Expected: kt.String(), Actual: "object key"}
+			}
+			kv.SetUint(n)
+		default:
+			return fmt.Errorf("jsonq: unsupported map key type %s at %s", kt, pathName(p))
+		}
+		ev := reflect.New(t.Elem()).Elem()
+		if err := d.decode(val, ev, p.Append(Segment{Key: key})); err != nil {
+			return err
+		}
+		rv.SetMapIndex(kv, ev)
+	}
+	return nil
+}
+
+// structField is a decodable field of a struct type.
+type structField struct {
+	name   string
+	index  []int
+	tagged bool // name comes from a json tag
+	quoted bool // ",string" option; numeric strings are accepted anyway, this also lets bools be quoted
+}
+
+// structFields are the fields of a struct type by json name.
+type structFields struct {
+	byName map[string]*structField
+	list   []*structField
+}
+
+// lookup finds the field for a json key, preferring an exact match and
+// falling back to a case-insensitive one like encoding/json.
+func (fs *structFields) lookup(key string) *structField {
+	if f, ok := fs.byName[key]; ok {
+		return f
+	}
+	for _, f := range fs.list {
+		if strings.EqualFold(f.name, key) {
+			return f
+		}
+	}
+	return nil
+}
+
+var fieldCache sync.Map // map[reflect.Type]*structFields
+
+func cachedFields(t reflect.Type) *structFields {
+	if fs, ok := fieldCache.Load(t); ok {
+		return fs.(*structFields)
+	}
+	var all []*structField
+	collectFields(t, nil, map[reflect.Type]bool{t: true}, &all)
+	byName := map[string][]*structField{}
+	for _, f := range all {
+		byName[f.name] = append(byName[f.name], f)
+	}
+	fs := &structFields{byName: map[string]*structField{}}
+	for name, candidates := range byName {
+		if f := dominantField(candidates); f != nil {
+			fs.byName[name] = f
+		}
+	}
+	for _, f := range all {
+		if fs.byName[f.name] == f {
+			fs.list = append(fs.list, f)
+		}
+	}
+	fieldCache.Store(t, fs)
+	return fs
+}
+
+// dominantField picks the field that a json name decodes into, following
+// encoding/json: the shallowest field wins, a tagged field wins over untagged
+// ones at the same depth, and any other tie hides the name.
+func dominantField(candidates []*structField) *structField {
+	depth := len(candidates[0].index)
+	for _, f := range candidates[1:] {
+		if len(f.index) < depth {
+			depth = len(f.index)
+		}
+	}
+	var shallow, tagged []*structField
+	for _, f := range candidates {
+		if len(f.index) != depth {
+			continue
+		}
+		shallow = append(shallow, f)
+		if f.tagged {
+			tagged = append(tagged, f)
+		}
+	}
+	switch {
+	case len(shallow) == 1:
+		return shallow[0]
+	case len(tagged) == 1:
+		return tagged[0]
+	}
+	return nil
+}
+
+// collectFields appends the fields of t to out, descending into embedded
+// structs. visited holds the struct types on the current embedding chain, so
+// a type embedding a pointer to itself is not expanded again.
+func collectFields(t reflect.Type, index []int, visited map[reflect.Type]bool, out *[]*structField) {
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		tag := sf.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, opts, _ := strings.Cut(tag, ",")
+		idx := append(append([]int(nil), index...), i)
+		ft := sf.Type
+		if ft.Kind() == reflect.Ptr {
+			ft = ft.Elem()
+		}
+		if sf.Anonymous && name == "" && ft.Kind() == reflect.Struct {
+			if !visited[ft] {
+				visited[ft] = true
+				collectFields(ft, idx, visited, out)
+				delete(visited, ft)
+			}
+			continue
+		}
+		if !sf.IsExported() {
+			continue
+		}
+		tagged := name != ""
+		if !tagged {
+			name = sf.Name
+		}
+		*out = append(*out, &structField{name: name, index: idx, tagged: tagged, quoted: strings.Contains(","+opts+",", ",string,")})
+	}
+}
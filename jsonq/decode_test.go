@@ -0,0 +1,128 @@
+package jsonq
+
+import (
+	"errors"
+	"math/big"
+	"testing"
+	"time"
+)
+
+type decodeBase struct {
+	ID int64 `json:"id"`
+}
+
+type decodeItem struct {
+	decodeBase
+	Name    string         `json:"name"`
+	Price   float64        `json:"price"`
+	Tags    []string       `json:"tags"`
+	Extra   map[string]int `json:"extra"`
+	When    time.Time      `json:"when"`
+	Timeout time.Duration  `json:"timeout"`
+	Big     *big.Int       `json:"big"`
+	Ptr     *int           `json:"ptr"`
+	On      bool           `json:"on,string"`
+	Skip    string         `json:"-"`
+	ByNum   map[int]string `json:"bynum"`
+	Raw     []byte         `json:"raw"`
+	Fixed   [2]int         `json:"fixed"`
+}
+
+const decodeDoc = `{"data":{"items":[{"id":"9007199254740993","NAME":"a","price":"1.5","tags":["x"],"extra":{"k":"2"},"when":"2020-01-02T03:04:05Z","timeout":"1m","big":123456789012345678901234567890,"ptr":3,"on":"true","Skip":"no","bynum":{"1":"one"},"raw":"aGk=","fixed":[1]}],"n":1.5,"b":1}}`
+
+func TestDecode(t *testing.T) {
+	j := mustQuery(t, decodeDoc)
+	items, err := Get[[]decodeItem](j, "data.items")
+	if err != nil {
+		t.Fatal(err)
+	}
+	it := items[0]
+	if it.ID != 9007199254740993 || it.Name != "a" || it.Price != 1.5 || it.Tags[0] != "x" || it.Extra["k"] != 2 ||
+		it.When.Year() != 2020 || it.Timeout != time.Minute || it.Big.String() != "123456789012345678901234567890" ||
+		*it.Ptr != 3 || !it.On || it.Skip != "" || it.ByNum[1] != "one" || string(it.Raw) != "hi" || it.Fixed != [2]int{1, 0} {
+		t.Errorf("decoded %+v", it)
+	}
+	if _, err := Get[int](j, "data.n"); !errors.Is(err, ErrTypeMismatch) {
+		t.Errorf("fraction into int: %v", err)
+	}
+	if _, err := Get[string](j, "data.n"); err == nil {
+		t.Error("number into string without WeaklyTyped succeeded")
+	}
+	var bad []decodeItem
+	if err := mustQuery(t, `{"items":[{"tags":["ok",{"x":1}]}]}`).Decode(&bad, "items"); err == nil {
+		t.Error("object into string element succeeded")
+	}
+	if err := j.Decode(bad, "data.items"); err == nil {
+		t.Error("Decode into a non-pointer succeeded")
+	}
+}
+
+func TestDecodeWeaklyTyped(t *testing.T) {
+	j := mustQuery(t, decodeDoc)
+	opts := &DecodeOptions{WeaklyTyped: true}
+	var s string
+	if err := j.DecodeWith(&s, opts, "data.n"); err != nil || s != "1.5" {
+		t.Errorf("number into string = %q, %v", s, err)
+	}
+	var b bool
+	if err := j.DecodeWith(&b, opts, "data.b"); err != nil || !b {
+		t.Errorf("1 into bool = %v, %v", b, err)
+	}
+}
+
+func TestDecodeUnknownFields(t *testing.T) {
+	j := mustQuery(t, `{"item":{"id":1,"extra":2}}`)
+	var strict struct{ ID int64 }
+	err := j.DecodeWith(&strict, &DecodeOptions{DisallowUnknownFields: true}, "item")
+	var pe *PathError
+	if !errors.As(err, &pe) || !errors.Is(err, ErrUnknownField) {
+		t.Fatalf("unknown field error = %#v, want a *PathError wrapping ErrUnknownField", err)
+	}
+	if pe.Path.String() != "item.extra" {
+		t.Errorf("PathError path = %s, want item.extra", pe.Path)
+	}
+	if err := j.Decode(&strict, "item"); err != nil || strict.ID != 1 {
+		t.Errorf("unknown fields are ignored by default: %d, %v", strict.ID, err)
+	}
+}
+
+// decodeNode embeds a pointer to itself.
+type decodeNode struct {
+	*decodeNode
+	Name string
+}
+
+type decodeTagged struct {
+	ID int `json:"ID"`
+}
+
+type decodeUntagged struct {
+	ID   int
+	Kind string
+}
+
+type decodeOther struct {
+	Kind string
+}
+
+type decodeConflict struct {
+	decodeTagged
+	decodeUntagged
+	decodeOther
+}
+
+func TestDecodeEmbedded(t *testing.T) {
+	var n decodeNode
+	if err := mustQuery(t, `{"Name":"root"}`).Decode(&n); err != nil || n.Name != "root" {
+		t.Errorf("self-embedding struct = %+v, %v", n, err)
+	}
+	var c decodeConflict
+	err := mustQuery(t, `{"ID":7,"Kind":"k"}`).Decode(&c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the tagged ID wins, two untagged Kind fields hide each other
+	if c.decodeTagged.ID != 7 || c.decodeUntagged.ID != 0 || c.decodeUntagged.Kind != "" || c.decodeOther.Kind != "" {
+		t.Errorf("decoded %+v", c)
+	}
+}
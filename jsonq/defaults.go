@@ -0,0 +1,169 @@
+package jsonq
+
+import "time"
+
+// The Or getters return def when the path is missing, the value is null or it
+// has the wrong type, so config lookups with fallbacks are one line each:
+//
+//	port := cfg.IntOr(8080, "server.port")
+//	timeout := cfg.DurationOr(10*time.Second, "server.timeout")
+
+// IntOr extracts an int from the JsonQuery, or def.
+func (j *JSONQuery) IntOr(def int, s ...string) int {
+	val, err := j.Int(s...)
+	if err != nil {
+		return def
+	}
+	return val
+}
+
+// Int64Or extracts an int64 from the JsonQuery, or def.
+func (j *JSONQuery) Int64Or(def int64, s ...string) int64 {
+	val, err := j.Int64(s...)
+	if err != nil {
+		return def
+	}
+	return val
+}
+
+// FloatOr extracts a float from the JsonQuery, or def.
+func (j *JSONQuery) FloatOr(def float64, s ...string) float64 {
+	val, err := j.Float(s...)
+	if err != nil {
+		return def
+	}
+	return val
+}
+
+// BoolOr extracts a bool from the JsonQuery, or def.
+func (j *JSONQuery) BoolOr(def bool, s ...string) bool {
+	val, err := j.Bool(s...)
+	if err != nil {
+		return def
+	}
+	return val
+}
+
+// StringOr extracts a string from the JsonQuery, or def.
+func (j *JSONQuery) StringOr(def string, s ...string) string {
+	val, err := j.String(s...)
+	if err != nil {
+		return def
+	}
+	return val
+}
+
+// DurationOr extracts a time.Duration (see Duration) from the JsonQuery, or def.
+func (j *JSONQuery) DurationOr(def time.Duration, s ...string) time.Duration {
+	val, err := j.Duration(s...)
+	if err != nil {
+		return def
+	}
+	return val
+}
+
+// StringSliceOr extracts an array of strings from the JsonQuery, or def if the
+// value is missing, not an array or contains a non-string element.
+func (j *JSONQuery) StringSliceOr(def []string, s ...string) []string {
+	val, err := j.ArrayOfStrings(s...)
+	if err != nil {
+		return def
+	}
+	return val
+}
+
+// The Must getters panic with the *PathError returned by the corresponding
+// getter. They are meant for values the program cannot run without, such as
+// required config keys read at startup:
+//
+//	appid := cfg.MustString("wechat.appid")
+
+// MustInt extracts an int from the JsonQuery, or panics.
+func (j *JSONQuery) MustInt(s ...string) int {
+	val, err := j.Int(s...)
+	if err != nil {
+		panic(err)
+	}
+	return val
+}
+
+// MustInt64 extracts an int64 from the JsonQuery, or panics.
+func (j *JSONQuery) MustInt64(s ...string) int64 {
+	val, err := j.Int64(s...)
+	if err != nil {
+		panic(err)
+	}
+	return val
+}
+
+// MustUint64 extracts a uint64 from the JsonQuery, or panics.
+func (j *JSONQuery) MustUint64(s ...string) uint64 {
+	val, err := j.Uint64(s...)
+	if err != nil {
+		panic(err)
+	}
+	return val
+}
+
+// MustFloat extracts a float from the JsonQuery, or panics.
+func (j *JSONQuery) MustFloat(s ...string) float64 {
+	val, err := j.Float(s...)
+	if err != nil {
+		panic(err)
+	}
+	return val
+}
+
+// MustBool extracts a bool from the JsonQuery, or panics.
+func (j *JSONQuery) MustBool(s ...string) bool {
+	val, err := j.Bool(s...)
+	if err != nil {
+		panic(err)
+	}
+	return val
+}
+
+// MustString extracts a string from the JsonQuery, or panics.
+func (j *JSONQuery) MustString(s ...string) string {
+	val, err := j.String(s...)
+	if err != nil {
+		panic(err)
+	}
+	return val
+}
+
+// MustDuration extracts a time.Duration (see Duration) from the JsonQuery, or panics.
+func (j *JSONQuery) MustDuration(s ...string) time.Duration {
+	val, err := j.Duration(s...)
+	if err != nil {
+		panic(err)
+	}
+	return val
+}
+
+// MustStringSlice extracts an array of strings from the JsonQuery, or panics.
+func (j *JSONQuery) MustStringSlice(s ...string) []string {
+	val, err := j.ArrayOfStrings(s...)
+	if err != nil {
+		panic(err)
+	}
+	return val
+}
+
+// MustObject extracts a json object from the JsonQuery, or panics.
+func (j *JSONQuery) MustObject(s ...string) map[string]interface{} {
+	val, err := j.Object(s...)
+	if err != nil {
+		panic(err)
+	}
+	return val
+}
+
+// MustArray extracts a json array from the JsonQuery, or panics.
+func (j *JSONQuery) MustArray(s ...string) []interface{} {
+	val, err := j.Array(s...)
+	if err != nil {
+		panic(err)
+	}
+	return val
+}
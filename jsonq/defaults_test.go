@@ -0,0 +1,94 @@
+package jsonq
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+const configDoc = `{"port":"9090","t":"1m30s","n":2.5,"big":1e19,"neg":-3,"on":true,"name":"api","tags":["a","b"],"mixed":["a",1],"null":null}`
+
+func TestOrGetters(t *testing.T) {
+	j := mustQuery(t, configDoc)
+	if j.IntOr(1, "port") != 9090 || j.IntOr(1, "nope") != 1 || j.IntOr(7, "null") != 7 || j.IntOr(7, "n") != 7 {
+		t.Error("IntOr")
+	}
+	if j.Int64Or(1, "big") != 1 || j.Int64Or(1, "neg") != -3 {
+		t.Error("Int64Or")
+	}
+	if j.FloatOr(0, "n") != 2.5 || j.FloatOr(1.5, "on") != 1.5 {
+		t.Error("FloatOr")
+	}
+	if j.DurationOr(0, "t") != 90*time.Second || j.DurationOr(0, "n") != 2500*time.Millisecond || j.DurationOr(time.Second, "on") != time.Second {
+		t.Error("DurationOr")
+	}
+	if !j.BoolOr(false, "on") || !j.BoolOr(true, "x") || j.GetBool("port") {
+		t.Error("BoolOr")
+	}
+	if j.StringOr("d", "name") != "api" || j.StringOr("d", "n") != "d" {
+		t.Error("StringOr")
+	}
+	if v := j.StringSliceOr([]string{"d"}, "mixed"); len(v) != 1 || v[0] != "d" {
+		t.Errorf("StringSliceOr with a non-string element = %v", v)
+	}
+	if v := j.StringSliceOr(nil, "tags"); len(v) != 2 {
+		t.Errorf("StringSliceOr = %v", v)
+	}
+}
+
+func TestIntegerGetters(t *testing.T) {
+	j := mustQuery(t, configDoc)
+	if _, err := j.Int64("big"); !errors.Is(err, ErrTypeMismatch) {
+		t.Errorf("Int64 overflow: %v", err)
+	}
+	if v, err := j.Uint64("big"); err != nil || v != 1e19 {
+		t.Errorf("Uint64 = %d, %v", v, err)
+	}
+	if _, err := j.Uint64("neg"); !errors.Is(err, ErrTypeMismatch) {
+		t.Errorf("Uint64 of a negative number: %v", err)
+	}
+	if v, err := j.Int64("port"); v != 9090 || err != nil {
+		t.Errorf("Int64 of a numeric string = %d, %v", v, err)
+	}
+}
+
+// mustPanic runs f and returns the *PathError it panicked with.
+func mustPanic(t *testing.T, f func()) (pe *PathError) {
+	t.Helper()
+	defer func() {
+		err, _ := recover().(error)
+		if !errors.As(err, &pe) {
+			t.Errorf("panicked with %v, want a *PathError", err)
+		}
+	}()
+	f()
+	return nil
+}
+
+func TestMustGetters(t *testing.T) {
+	j := mustQuery(t, configDoc)
+	if j.MustInt("port") != 9090 || j.MustInt64("neg") != -3 || j.MustUint64("big") != 1e19 || j.MustFloat("n") != 2.5 ||
+		!j.MustBool("on") || j.MustString("name") != "api" || j.MustDuration("t") != 90*time.Second ||
+		len(j.MustStringSlice("tags")) != 2 || len(j.MustArray("tags")) != 2 || len(j.MustObject()) != 10 {
+		t.Error("Must getters returned wrong values")
+	}
+	tests := []struct {
+		name string
+		f    func()
+		err  error
+	}{
+		{"MustInt missing", func() { j.MustInt("server.port") }, ErrNotFound},
+		{"MustString null", func() { j.MustString("null") }, ErrNotFound},
+		{"MustBool mismatch", func() { j.MustBool("name") }, ErrTypeMismatch},
+		{"MustStringSlice element", func() { j.MustStringSlice("mixed") }, ErrTypeMismatch},
+		{"MustArray index", func() { j.MustArray("tags[5]") }, ErrIndexOutOfRange},
+	}
+	for _, tt := range tests {
+		if pe := mustPanic(t, tt.f); pe != nil && !errors.Is(pe, tt.err) {
+			t.Errorf("%s: %v, want %v", tt.name, pe, tt.err)
+		}
+	}
+	if pe := mustPanic(t, func() { j.MustInt("server.port") }); pe != nil && pe.Path.String() != "server" {
+		t.Errorf("PathError path = %s", pe.Path)
+	}
+}
@@ -0,0 +1,142 @@
+package jsonq
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Sentinel errors returned (wrapped in a *PathError) by the getters, Decode,
+// the mutation methods and ApplyPatch; test for them with errors.Is.
+var (
+	// ErrNotFound means an object has no such member, or the value is null.
+	ErrNotFound = errors.New("jsonq: not found")
+	// ErrTypeMismatch means a value has a different json type than required,
+	// or a number does not fit the requested type.
+	ErrTypeMismatch = errors.New("jsonq: type mismatch")
+	// ErrIndexOutOfRange means an array index is past either end of the array.
+	ErrIndexOutOfRange = errors.New("jsonq: index out of range")
+	// ErrUnknownField means Decode with DisallowUnknownFields found an object
+	// member that has no matching struct field.
+	ErrUnknownField = errors.New("jsonq: unknown field")
+	// ErrTestFailed means a JSON Patch "test" operation found a different
+	// value at its path.
+	ErrTestFailed = errors.New("jsonq: patch test failed")
+	// ErrInvalidPatch means a JSON Patch operation cannot be applied as
+	// written: an unknown op, a malformed JSON Pointer or array index, or a
+	// move into one of the value's own children.
+	ErrInvalidPatch = errors.New("jsonq: invalid patch operation")
+)
+
+// PathError describes a failed lookup or conversion. It never contains values
+// from the document, so it is safe to log for documents holding secrets:
+//
+//	jsonq: type mismatch at data.items[2].price: expected integer, got number (fractional part)
+//
+// Use errors.As to inspect the failing location:
+//
+//	var pe *jsonq.PathError
+//	if errors.As(err, &pe) {
+//		log.Println(pe.Path, pe.Expected, pe.Actual)
+//	}
+type PathError struct {
+	Err      error  // one of the sentinel errors above
+	Path     Path   // path up to and including the failing segment
+	Expected string // expected type, e.g. "object", "array", "integer", "int64"
+	Actual   string // actual json type: "object", "array", "string", "number", "bool" or "null"
+	Index    int    // the requested index, for ErrIndexOutOfRange
+	Length   int    // the array length, for ErrIndexOutOfRange
+	Reason   string // optional detail such as "fractional part"
+	// Cause is an error returned by a json.Unmarshaler or
+	// encoding.TextUnmarshaler during Decode. Its text may contain document
+	// values, so it is not part of Error().
+	Cause error
+}
+
+// Error formats the error without document contents.
+func (e *PathError) Error() string {
+	var b strings.Builder
+	b.WriteString(e.Err.Error())
+	b.WriteString(" at ")
+	b.WriteString(pathName(e.Path))
+	switch e.Err {
+	case ErrNotFound:
+		if n := len(e.Path); n > 0 && !e.Path[n-1].IsIndex && e.Reason == "" {
+			fmt.Fprintf(&b, ": no field %q", e.Path[n-1].Key)
+		}
+	case ErrIndexOutOfRange:
+		fmt.Fprintf(&b, ": index %d with length %d", e.Index, e.Length)
+	case ErrTypeMismatch:
+		if e.Expected != "" {
+			fmt.Fprintf(&b, ": expected %s, got %s", e.Expected, e.Actual)
+		}
+	}
+	if e.Reason != "" {
+		fmt.Fprintf(&b, " (%s)", e.Reason)
+	}
+	return b.String()
+}
+
+// Unwrap returns the sentinel error.
+func (e *PathError) Unwrap() error {
+	return e.Err
+}
+
+// Segment returns the failing path segment.
+func (e *PathError) Segment() (Segment, bool) {
+	if len(e.Path) == 0 {
+		return Segment{}, false
+	}
+	return e.Path[len(e.Path)-1], true
+}
+
+// notFound returns an ErrNotFound error; the path is filled in by atPath.
+func notFound(reason string) error {
+	return &PathError{Err: ErrNotFound, Reason: reason}
+}
+
+// mismatch returns an ErrTypeMismatch error for val.
+func mismatch(expected string, val interface{}) error {
+	return &PathError{Err: ErrTypeMismatch, Expected: expected, Actual: jsonTypeName(val)}
+}
+
+// outOfRange returns an ErrIndexOutOfRange error.
+func outOfRange(index, length int) error {
+	return &PathError{Err: ErrIndexOutOfRange, Index: index, Length: length}
+}
+
+// atPath sets the path of a *PathError created without one.
+func atPath(err error, p Path) error {
+	if pe, ok := err.(*PathError); ok && pe.Path == nil {
+		pe.Path = append(Path{}, p...)
+	}
+	return err
+}
+
+// pathName formats p for error messages.
+func pathName(p Path) string {
+	if len(p) == 0 {
+		return "document root"
+	}
+	return p.String()
+}
+
+// jsonTypeName is the json type of a decoded value.
+func jsonTypeName(val interface{}) string {
+	switch val.(type) {
+	case nil:
+		return "null"
+	case map[string]interface{}:
+		return "object"
+	case []interface{}:
+		return "array"
+	case string:
+		return "string"
+	case bool:
+		return "bool"
+	case float64, json.Number, int, int64:
+		return "number"
+	}
+	return fmt.Sprintf("%T", val)
+}
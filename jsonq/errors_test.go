@@ -0,0 +1,109 @@
+package jsonq
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+const secretDoc = `{"secret":"hunter2","data":{"items":[{"price":1.5,"name":"hunter2"}],"n":null,"list":["a",{"x":"hunter2"}],"big":99999999999999999999}}`
+
+func TestPathErrorMessages(t *testing.T) {
+	j := mustQuery(t, secretDoc)
+	var v struct{ Items []struct{ Price int } }
+	_, errMissing := j.String("data.items[0].missing")
+	_, errIndex := j.String("data", "items", "3", "name")
+	_, errType := j.Int("data.items[0].name")
+	_, errFraction := j.Int("data.items[0].price")
+	_, errOverflow := j.Int64("data.big")
+	_, errNull := j.String("data.n")
+	_, errElement := j.ArrayOfStrings("data.list")
+	_, errDescend := j.Object("data.items[0].name.first")
+	tests := []struct {
+		err  error
+		is   error
+		want string
+	}{
+		{errMissing, ErrNotFound, `jsonq: not found at data.items[0].missing: no field "missing"`},
+		{errIndex, ErrIndexOutOfRange, `jsonq: index out of range at data.items[3]: index 3 with length 1`},
+		{errType, ErrTypeMismatch, `jsonq: type mismatch at data.items[0].name: expected int, got string`},
+		{errFraction, ErrTypeMismatch, `jsonq: type mismatch at data.items[0].price: expected int, got number (fractional part)`},
+		{errOverflow, ErrTypeMismatch, `jsonq: type mismatch at data.big: expected int64, got number (out of range)`},
+		{errNull, ErrNotFound, `jsonq: not found at data.n (value is null)`},
+		{errElement, ErrTypeMismatch, `jsonq: type mismatch at data.list[1]: expected string, got object`},
+		{errDescend, ErrTypeMismatch, `jsonq: type mismatch at data.items[0].name.first: expected object, got string`},
+		{j.Delete("data.nope"), ErrNotFound, `jsonq: not found at data.nope: no field "nope"`},
+		{j.Set("secret.x", 1), ErrTypeMismatch, `jsonq: type mismatch at secret.x: expected object, got string`},
+		{j.Decode(&v, "data"), ErrTypeMismatch, `jsonq: type mismatch at data.items[0].price: expected int, got number (fractional part)`},
+	}
+	for _, tt := range tests {
+		if !errors.Is(tt.err, tt.is) {
+			t.Errorf("%v is not %v", tt.err, tt.is)
+			continue
+		}
+		if msg := tt.err.Error(); msg != tt.want {
+			t.Errorf("\ngot  %s\nwant %s", msg, tt.want)
+		}
+	}
+}
+
+// TestPathErrorNoLeak makes every kind of lookup fail on a value that holds
+// the secret and checks that the message never includes it.
+func TestPathErrorNoLeak(t *testing.T) {
+	j := mustQuery(t, secretDoc)
+	paths := []string{"secret.x", "secret[0]", "data.list[1].x.y", "data.items[0].name[2]"}
+	for _, path := range paths {
+		for _, get := range []func(...string) error{
+			func(s ...string) error { _, err := j.Int(s...); return err },
+			func(s ...string) error { _, err := j.Bool(s...); return err },
+			func(s ...string) error { _, err := j.Array(s...); return err },
+		} {
+			err := get(path)
+			if err == nil {
+				t.Fatalf("lookup of %s succeeded", path)
+			}
+			if strings.Contains(err.Error(), "hunter2") {
+				t.Errorf("error for %s leaks the value: %s", path, err)
+			}
+		}
+	}
+	for _, getter := range []func() error{
+		func() error { _, err := j.Int("secret"); return err },
+		func() error { _, err := j.Float("secret"); return err },
+		func() error { _, err := j.Bool("secret"); return err },
+		func() error { _, err := j.ArrayOfInts("data.list"); return err },
+	} {
+		if err := getter(); err == nil || strings.Contains(err.Error(), "hunter2") {
+			t.Errorf("conversion error = %v", err)
+		}
+	}
+}
+
+func TestPathErrorFields(t *testing.T) {
+	j := mustQuery(t, secretDoc)
+	_, err := j.String("secret.x")
+	var pe *PathError
+	if !errors.As(err, &pe) {
+		t.Fatalf("%v is not a *PathError", err)
+	}
+	if pe.Path.String() != "secret.x" || pe.Expected != "object" || pe.Actual != "string" {
+		t.Errorf("PathError = %+v", pe)
+	}
+	if seg, ok := pe.Segment(); !ok || seg.Key != "x" {
+		t.Errorf("Segment() = %+v, %v", seg, ok)
+	}
+	_, err = j.String("data.items[-4]")
+	if !errors.As(err, &pe) || pe.Index != -4 || pe.Length != 1 {
+		t.Errorf("out of range PathError = %+v", pe)
+	}
+	if seg, ok := pe.Segment(); !ok || !seg.IsIndex {
+		t.Errorf("Segment() = %+v, %v", seg, ok)
+	}
+	root := &PathError{Err: ErrNotFound}
+	if _, ok := root.Segment(); ok {
+		t.Error("Segment() of an empty path reported ok")
+	}
+	if msg := root.Error(); msg != "jsonq: not found at document root" {
+		t.Errorf("root error = %s", msg)
+	}
+}
@@ -0,0 +1,663 @@
+package jsonq
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Match is a single result of a JSONPath query: the value found and the
+// concrete path (keys and indexes only) that leads to it.
+type Match struct {
+	Path  Path
+	Value interface{}
+}
+
+// JSONPath is a compiled JSONPath query. It is safe for concurrent use.
+type JSONPath struct {
+	expr      string
+	selectors []selector
+}
+
+type selectorKind int
+
+const (
+	selectKey selectorKind = iota
+	selectIndex
+	selectWildcard
+	selectSlice
+	selectFilter
+	selectDescendants
+)
+
+// selector is one step of a compiled JSONPath.
+type selector struct {
+	kind   selectorKind
+	key    string
+	index  int
+	slice  [3]*int // start, end, step
+	filter filterExpr
+}
+
+var jsonPathCache parseCache
+
+/*
+CompileJSONPath compiles a JSONPath expression. The supported subset is:
+
+	$                 the document root (optional at the start)
+	.name ['name']    object member
+	[0] [-1]          array element, negative indexes count from the end
+	.* [*]            all members of an object or elements of an array
+	..name ..*        recursive descent: the selector is applied to the
+	                  current value and all of its descendants
+	[start:end:step]  array slice, every part is optional, e.g. [1:5] [-2:] [::2]
+	[?(filter)]       children for which the filter holds
+
+Filters compare relative paths (@.status, @['a.b'], @ itself), absolute
+paths ($.limit) and literals (numbers, 'strings', "strings", true, false,
+null) with == != < <= > >=, and combine them with && || ! and parentheses.
+A path on its own tests for existence:
+
+	data.items[?(@.status == 1 && @.openid)].openid
+	$..items[?(@.price < $.limit)]
+
+Compiled expressions are cached like parsed paths.
+*/
+func CompileJSONPath(expr string) (*JSONPath, error) {
+	if q, ok := jsonPathCache.load(expr); ok {
+		return q.(*JSONPath), nil
+	}
+	selectors, err := parseJSONPath(expr)
+	if err != nil {
+		return nil, err
+	}
+	q := &JSONPath{expr: expr, selectors: selectors}
+	jsonPathCache.store(expr, q)
+	return q, nil
+}
+
+// MustCompileJSONPath is like CompileJSONPath but panics if the expression is invalid.
+func MustCompileJSONPath(expr string) *JSONPath {
+	q, err := CompileJSONPath(expr)
+	if err != nil {
+		panic(err)
+	}
+	return q
+}
+
+// String returns the source expression.
+func (q *JSONPath) String() string {
+	return q.expr
+}
+
+// Find evaluates the query against a decoded json value and returns all
+// matches in document order (object members in key order). It returns an
+// empty result, not an error, if nothing matches.
+func (q *JSONPath) Find(doc interface{}) []Match {
+	matches := []Match{{Value: doc}}
+	for _, sel := range q.selectors {
+		var next []Match
+		for _, m := range matches {
+			next = sel.apply(m, doc, next)
+		}
+		matches = next
+	}
+	return matches
+}
+
+// Query evaluates a JSONPath expression (see CompileJSONPath) against the
+// document and returns all matches with their concrete paths.
+func (j *JSONQuery) Query(expr string) ([]Match, error) {
+	q, err := CompileJSONPath(expr)
+	if err != nil {
+		return nil, err
+	}
+	return q.Find(j.blob), nil
+}
+
+// QueryValues is like Query but returns only the matched values.
+func (j *JSONQuery) QueryValues(expr string) ([]interface{}, error) {
+	matches, err := j.Query(expr)
+	if err != nil {
+		return nil, err
+	}
+	values := make([]interface{}, len(matches))
+	for i, m := range matches {
+		values[i] = m.Value
+	}
+	return values, nil
+}
+
+// apply appends the results of applying the selector to m to out.
+func (s *selector) apply(m Match, root interface{}, out []Match) []Match {
+	switch s.kind {
+	case selectKey:
+		if obj, ok := m.Value.(map[string]interface{}); ok {
+			if v, ok := obj[s.key]; ok {
+				out = append(out, Match{m.Path.Append(Segment{Key: s.key}), v})
+			}
+		}
+	case selectIndex:
+		if arr, ok := m.Value.([]interface{}); ok {
+			index := s.index
+			if index < 0 {
+				index += len(arr)
+			}
+			if index >= 0 && index < len(arr) {
+				out = append(out, Match{m.Path.Append(Segment{Index: index, IsIndex: true}), arr[index]})
+			}
+		}
+	case selectWildcard:
+		out = appendChildren(m, out)
+	case selectSlice:
+		if arr, ok := m.Value.([]interface{}); ok {
+			start, end, step := s.sliceBounds(len(arr))
+			for i := start; (step > 0 && i < end) || (step < 0 && i > end); i += step {
+				out = append(out, Match{m.Path.Append(Segment{Index: i, IsIndex: true}), arr[i]})
+			}
+		}
+	case selectFilter:
+		for _, c := range appendChildren(m, nil) {
+			if s.filter.eval(c.Value, root) {
+				out = append(out, c)
+			}
+		}
+	case selectDescendants:
+		out = append(out, m)
+		for _, c := range appendChildren(m, nil) {
+			out = s.apply(c, root, out)
+		}
+	}
+	return out
+}
+
+// sliceBounds normalizes the slice parts for an array of length n, following
+// the semantics of Python slices.
+func (s *selector) sliceBounds(n int) (start, end, step int) {
+	step = 1
+	if s.slice[2] != nil {
+		step = *s.slice[2]
+	}
+	clamp := func(v, lo, hi int) int {
+		if v < 0 {
+			v += n
+		}
+		if v < lo {
+			return lo
+		}
+		if v > hi {
+			return hi
+		}
+		return v
+	}
+	if step > 0 {
+		start, end = 0, n
+		if s.slice[0] != nil {
+			start = clamp(*s.slice[0], 0, n)
+		}
+		if s.slice[1] != nil {
+			end = clamp(*s.slice[1], 0, n)
+		}
+	} else {
+		start, end = n-1, -1
+		if s.slice[0] != nil {
+			start = clamp(*s.slice[0], -1, n-1)
+		}
+		if s.slice[1] != nil {
+			end = clamp(*s.slice[1], -1, n-1)
+		}
+	}
+	return start, end, step
+}
+
+// appendChildren appends the elements of an array or the members of an
+// object (in key order) to out.
+func appendChildren(m Match, out []Match) []Match {
+	switch v := m.Value.(type) {
+	case []interface{}:
+		for i, c := range v {
+			out = append(out, Match{m.Path.Append(Segment{Index: i, IsIndex: true}), c})
+		}
+	case map[string]interface{}:
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			out = append(out, Match{m.Path.Append(Segment{Key: k}), v[k]})
+		}
+	}
+	return out
+}
+
+// parseJSONPath parses expr into selectors.
+func parseJSONPath(expr string) ([]selector, error) {
+	var selectors []selector
+	i := 0
+	if strings.HasPrefix(expr, "$") {
+		i = 1
+	}
+	for i < len(expr) {
+		switch {
+		case strings.HasPrefix(expr[i:], ".."):
+			i += 2
+			selectors = append(selectors, selector{kind: selectDescendants})
+			if i < len(expr) && expr[i] == '[' {
+				continue
+			}
+			sel, next, err := parseMember(expr, i)
+			if err != nil {
+				return nil, err
+			}
+			selectors, i = append(selectors, sel), next
+		case expr[i] == '.':
+			sel, next, err := parseMember(expr, i+1)
+			if err != nil {
+				return nil, err
+			}
+			selectors, i = append(selectors, sel), next
+		case expr[i] == '[':
+			sel, next, err := parseBracketSelector(expr, i)
+			if err != nil {
+				return nil, err
+			}
+			selectors, i = append(selectors, sel), next
+		case i == 0:
+			sel, next, err := parseMember(expr, i)
+			if err != nil {
+				return nil, err
+			}
+			selectors, i = append(selectors, sel), next
+		default:
+			return nil, fmt.Errorf("jsonq: invalid JSONPath %q: unexpected %q at offset %d", expr, expr[i], i)
+		}
+	}
+	return selectors, nil
+}
+
+// parseMember parses a member name or * following a dot.
+func parseMember(expr string, i int) (selector, int, error) {
+	start := i
+	for i < len(expr) && expr[i] != '.' && expr[i] != '[' && expr[i] != ']' {
+		i++
+	}
+	name := expr[start:i]
+	switch name {
+	case "":
+		return selector{}, 0, fmt.Errorf("jsonq: invalid JSONPath %q: empty member name at offset %d", expr, start)
+	case "*":
+		return selector{kind: selectWildcard}, i, nil
+	}
+	return selector{kind: selectKey, key: name}, i, nil
+}
+
+// parseBracketSelector parses a bracketed selector starting at expr[i] == '['.
+func parseBracketSelector(expr string, i int) (selector, int, error) {
+	start := i
+	i = skipSpaces(expr, i+1)
+	var sel selector
+	switch {
+	case i >= len(expr):
+		return selector{}, 0, fmt.Errorf("jsonq: invalid JSONPath %q: unclosed '[' at offset %d", expr, start)
+	case expr[i] == '*':
+		sel, i = selector{kind: selectWildcard}, i+1
+	case expr[i] == '"' || expr[i] == '\'':
+		key, next, err := parseQuoted(expr, i)
+		if err != nil {
+			return selector{}, 0, err
+		}
+		sel, i = selector{kind: selectKey, key: key}, next
+	case expr[i] == '?':
+		p := &filterParser{expr: expr, pos: i + 1}
+		f, err := p.parseOr()
+		if err != nil {
+			return selector{}, 0, err
+		}
+		sel, i = selector{kind: selectFilter, filter: f}, p.pos
+	default:
+		end := strings.IndexByte(expr[i:], ']')
+		if end < 0 {
+			return selector{}, 0, fmt.Errorf("jsonq: invalid JSONPath %q: unclosed '[' at offset %d", expr, start)
+		}
+		text := strings.TrimSpace(expr[i : i+end])
+		var err error
+		if strings.Contains(text, ":") {
+			sel, err = parseSlice(text)
+		} else {
+			sel.kind = selectIndex
+			sel.index, err = strconv.Atoi(text)
+		}
+		if err != nil {
+			return selector{}, 0, fmt.Errorf("jsonq: invalid JSONPath %q: invalid index %q at offset %d", expr, text, start)
+		}
+		i += end
+	}
+	i = skipSpaces(expr, i)
+	if i >= len(expr) || expr[i] != ']' {
+		return selector{}, 0, fmt.Errorf("jsonq: invalid JSONPath %q: missing ']' for '[' at offset %d", expr, start)
+	}
+	return sel, i + 1, nil
+}
+
+// parseSlice parses start:end:step.
+func parseSlice(text string) (selector, error) {
+	parts := strings.Split(text, ":")
+	if len(parts) > 3 {
+		return selector{}, fmt.Errorf("too many ':'")
+	}
+	sel := selector{kind: selectSlice}
+	for i, part := range parts {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		n, err := strconv.Atoi(part)
+		if err != nil {
+			return selector{}, err
+		}
+		sel.slice[i] = &n
+	}
+	if sel.slice[2] != nil && *sel.slice[2] == 0 {
+		return selector{}, fmt.Errorf("slice step cannot be zero")
+	}
+	return sel, nil
+}
+
+func skipSpaces(expr string, i int) int {
+	for i < len(expr) && (expr[i] == ' ' || expr[i] == '\t') {
+		i++
+	}
+	return i
+}
+
+// filterExpr is a compiled filter expression.
+type filterExpr interface {
+	eval(cur, root interface{}) bool
+}
+
+type filterOr struct{ left, right filterExpr }
+type filterAnd struct{ left, right filterExpr }
+type filterNot struct{ x filterExpr }
+type filterExists struct{ x filterOperand }
+type filterCompare struct {
+	op          string
+	left, right filterOperand
+}
+
+// filterOperand is a literal or a path relative to the current value (@) or the root ($).
+type filterOperand struct {
+	isPath bool
+	isRoot bool
+	path   Path
+	value  interface{}
+}
+
+func (f filterOr) eval(cur, root interface{}) bool {
+	return f.left.eval(cur, root) || f.right.eval(cur, root)
+}
+
+func (f filterAnd) eval(cur, root interface{}) bool {
+	return f.left.eval(cur, root) && f.right.eval(cur, root)
+}
+
+func (f filterNot) eval(cur, root interface{}) bool {
+	return !f.x.eval(cur, root)
+}
+
+func (f filterExists) eval(cur, root interface{}) bool {
+	_, ok := f.x.resolve(cur, root)
+	return ok
+}
+
+// eval compares the operands. A path that does not resolve makes every
+// comparison false; values of different types are only ever unequal.
+func (f filterCompare) eval(cur, root interface{}) bool {
+	a, ok := f.left.resolve(cur, root)
+	if !ok {
+		return false
+	}
+	b, ok := f.right.resolve(cur, root)
+	if !ok {
+		return false
+	}
+	if x, ok := filterNumber(a); ok {
+		if y, ok := filterNumber(b); ok {
+			c := x.Cmp(y)
+			return compareOrdered(f.op, c < 0, c == 0)
+		}
+	}
+	if x, ok := a.(string); ok {
+		if y, ok := b.(string); ok {
+			return compareOrdered(f.op, x < y, x == y)
+		}
+	}
+	switch f.op {
+	case "==":
+		return reflect.DeepEqual(a, b)
+	case "!=":
+		return !reflect.DeepEqual(a, b)
+	}
+	return false
+}
+
+func compareOrdered(op string, less, equal bool) bool {
+	switch op {
+	case "==":
+		return equal
+	case "!=":
+		return !equal
+	case "<":
+		return less
+	case "<=":
+		return less || equal
+	case ">":
+		return !less && !equal
+	case ">=":
+		return !less
+	}
+	return false
+}
+
+// filterNumber converts a numeric value (but not a numeric string) to an exact
+// rational so that large integers compare correctly.
+func filterNumber(v interface{}) (*big.Rat, bool) {
+	if _, ok := v.(string); ok {
+		return nil, false
+	}
+	return numberRat(v)
+}
+
+func (o filterOperand) resolve(cur, root interface{}) (interface{}, bool) {
+	if !o.isPath {
+		return o.value, true
+	}
+	val := cur
+	if o.isRoot {
+		val = root
+	}
+	for _, seg := range o.path {
+		next, err := step(val, seg)
+		if err != nil {
+			return nil, false
+		}
+		val = next
+	}
+	return val, true
+}
+
+// filterParser is a recursive descent parser for filter expressions:
+//
+//	or      = and { "||" and }
+//	and     = unary { "&&" unary }
+//	unary   = "!" unary | "(" or ")" | operand [ op operand ]
+type filterParser struct {
+	expr string
+	pos  int
+}
+
+func (p *filterParser) errorf(format string, args ...interface{}) error {
+	return fmt.Errorf("jsonq: invalid JSONPath %q: %s at offset %d", p.expr, fmt.Sprintf(format, args...), p.pos)
+}
+
+// consume skips spaces and consumes tok if it is next.
+func (p *filterParser) consume(tok string) bool {
+	p.pos = skipSpaces(p.expr, p.pos)
+	if strings.HasPrefix(p.expr[p.pos:], tok) {
+		p.pos += len(tok)
+		return true
+	}
+	return false
+}
+
+func (p *filterParser) parseOr() (filterExpr, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.consume("||") {
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = filterOr{left, right}
+	}
+	return left, nil
+}
+
+func (p *filterParser) parseAnd() (filterExpr, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for p.consume("&&") {
+		right, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		left = filterAnd{left, right}
+	}
+	return left, nil
+}
+
+func (p *filterParser) parseUnary() (filterExpr, error) {
+	if p.consume("!") {
+		if strings.HasPrefix(p.expr[p.pos:], "=") {
+			return nil, p.errorf("unexpected '!='")
+		}
+		x, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return filterNot{x}, nil
+	}
+	if p.consume("(") {
+		x, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if !p.consume(")") {
+			return nil, p.errorf("missing ')'")
+		}
+		return x, nil
+	}
+	left, err := p.parseOperand()
+	if err != nil {
+		return nil, err
+	}
+	for _, op := range []string{"==", "!=", "<=", ">=", "<", ">"} {
+		if p.consume(op) {
+			right, err := p.parseOperand()
+			if err != nil {
+				return nil, err
+			}
+			return filterCompare{op, left, right}, nil
+		}
+	}
+	if !left.isPath {
+		return nil, p.errorf("expected comparison after literal")
+	}
+	return filterExists{left}, nil
+}
+
+func (p *filterParser) parseOperand() (filterOperand, error) {
+	p.pos = skipSpaces(p.expr, p.pos)
+	if p.pos >= len(p.expr) {
+		return filterOperand{}, p.errorf("unexpected end of filter")
+	}
+	switch c := p.expr[p.pos]; {
+	case c == '@' || c == '$':
+		start := p.pos
+		p.pos++
+		if err := p.skipPath(); err != nil {
+			return filterOperand{}, err
+		}
+		path, err := parsePath("$" + p.expr[start+1:p.pos])
+		if err != nil {
+			return filterOperand{}, err
+		}
+		return filterOperand{isPath: true, isRoot: c == '$', path: path}, nil
+	case c == '"' || c == '\'':
+		s, next, err := parseQuoted(p.expr, p.pos)
+		if err != nil {
+			return filterOperand{}, err
+		}
+		p.pos = next
+		return filterOperand{value: s}, nil
+	case c == '-' || c >= '0' && c <= '9':
+		start := p.pos
+		for p.pos < len(p.expr) && strings.IndexByte("+-.eE0123456789", p.expr[p.pos]) >= 0 {
+			p.pos++
+		}
+		n := json.Number(p.expr[start:p.pos])
+		if _, ok := numberRat(n); !ok {
+			p.pos = start
+			return filterOperand{}, p.errorf("invalid number")
+		}
+		return filterOperand{value: n}, nil
+	}
+	for word, value := range map[string]interface{}{"true": true, "false": false, "null": nil} {
+		if strings.HasPrefix(p.expr[p.pos:], word) {
+			p.pos += len(word)
+			return filterOperand{value: value}, nil
+		}
+	}
+	return filterOperand{}, p.errorf("unexpected %q", p.expr[p.pos])
+}
+
+// skipPath advances over the member and index accessors of a filter path.
+func (p *filterParser) skipPath() error {
+	for p.pos < len(p.expr) {
+		switch p.expr[p.pos] {
+		case '.':
+			p.pos++
+			for p.pos < len(p.expr) && !strings.ContainsRune(" \t.[]()!=<>&|", rune(p.expr[p.pos])) {
+				p.pos++
+			}
+		case '[':
+			p.pos++
+			for p.pos < len(p.expr) && p.expr[p.pos] != ']' {
+				if c := p.expr[p.pos]; c == '"' || c == '\'' {
+					_, next, err := parseQuoted(p.expr, p.pos)
+					if err != nil {
+						return err
+					}
+					p.pos = next
+					continue
+				}
+				p.pos++
+			}
+			if p.pos >= len(p.expr) {
+				return p.errorf("unclosed '['")
+			}
+			p.pos++
+		default:
+			return nil
+		}
+	}
+	return nil
+}
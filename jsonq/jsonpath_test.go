@@ -0,0 +1,99 @@
+package jsonq
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+const jsonPathDoc = `{"limit":3,"data":{"items":[{"openid":"o1","status":1,"price":2},{"openid":"o2","status":0,"price":5},{"openid":"o3","status":1,"price":4,"tags":{"openid":"deep"}},{"openid":"o4","price":null},"scalar"],"openid":"top","n":[0,1,2,3,4,5,6]}}`
+
+// formatMatches renders matches as "path=value;" pairs in result order.
+func formatMatches(ms []Match) string {
+	var b strings.Builder
+	for _, m := range ms {
+		fmt.Fprintf(&b, "%s=%v;", m.Path, m.Value)
+	}
+	return b.String()
+}
+
+func TestQuery(t *testing.T) {
+	j := mustQuery(t, jsonPathDoc)
+	tests := []struct {
+		expr, want string
+	}{
+		{"data..openid", "data.openid=top;data.items[0].openid=o1;data.items[1].openid=o2;data.items[2].openid=o3;data.items[2].tags.openid=deep;data.items[3].openid=o4;"},
+		{"$.data.items[*].openid", "data.items[0].openid=o1;data.items[1].openid=o2;data.items[2].openid=o3;data.items[3].openid=o4;"},
+		{"data.items[0].*", "data.items[0].openid=o1;data.items[0].price=2;data.items[0].status=1;"},
+		{"data.n[1:5]", "data.n[1]=1;data.n[2]=2;data.n[3]=3;data.n[4]=4;"},
+		{"data.n[-2:]", "data.n[5]=5;data.n[6]=6;"},
+		{"data.n[::3]", "data.n[0]=0;data.n[3]=3;data.n[6]=6;"},
+		{"data.n[::-3]", "data.n[6]=6;data.n[3]=3;data.n[0]=0;"},
+		{"data.n[5:100]", "data.n[5]=5;data.n[6]=6;"},
+		{"data.n[-1]", "data.n[6]=6;"},
+		{"data.n[7]", ""},
+		{"nothing.here", ""},
+	}
+	for _, tt := range tests {
+		ms, err := j.Query(tt.expr)
+		if got := formatMatches(ms); err != nil || got != tt.want {
+			t.Errorf("Query(%q) = %s, %v\nwant %s", tt.expr, got, err, tt.want)
+		}
+	}
+	for _, bad := range []string{"a[", "a[?(@.x ==)]", "a[1:2:0]", "a..", "a[?(1)]", "a[?(@.x]"} {
+		if _, err := j.Query(bad); err == nil {
+			t.Errorf("Query(%q) accepted", bad)
+		}
+	}
+}
+
+// TestFilterMissingKeys checks that a member missing from an element (or an
+// element that is not an object at all) never satisfies a comparison, not
+// even a negated one, while an explicit null is present for existence tests.
+func TestFilterMissingKeys(t *testing.T) {
+	j := mustQuery(t, jsonPathDoc)
+	tests := []struct {
+		expr, want string
+	}{
+		{"data.items[?(@.status == 1)].openid", "data.items[0].openid=o1;data.items[2].openid=o3;"},
+		{"data.items[?(@.status != 1)].openid", "data.items[1].openid=o2;"},
+		{"data.items[?(@.status < 5)].openid", "data.items[0].openid=o1;data.items[1].openid=o2;data.items[2].openid=o3;"},
+		{"data.items[?(@.price < $.limit && @.status==1)].openid", "data.items[0].openid=o1;"},
+		{"data.items[?(@.price == null)].openid", "data.items[3].openid=o4;"},
+		{"data.items[?(@.price)].openid", "data.items[0].openid=o1;data.items[1].openid=o2;data.items[2].openid=o3;data.items[3].openid=o4;"},
+		{"data.items[?(!@.tags)].openid", "data.items[0].openid=o1;data.items[1].openid=o2;data.items[3].openid=o4;"},
+		{"data.items[?(!@.tags)]", "data.items[0]=map[openid:o1 price:2 status:1];data.items[1]=map[openid:o2 price:5 status:0];data.items[3]=map[openid:o4 price:<nil>];data.items[4]=scalar;"},
+		{"data.items[?(@.openid == 'o2' || @.price >= 4)].openid", "data.items[1].openid=o2;data.items[2].openid=o3;"},
+		{"data.items[?(@.missing.deeper == 1)]", ""},
+		{"data.items[?(@.price > $.nolimit)]", ""},
+		{"$..[?(@ > 5)]", "data.n[6]=6;"},
+	}
+	for _, tt := range tests {
+		ms, err := j.Query(tt.expr)
+		if got := formatMatches(ms); err != nil || got != tt.want {
+			t.Errorf("Query(%q) = %s, %v\nwant %s", tt.expr, got, err, tt.want)
+		}
+	}
+}
+
+func TestCompileJSONPath(t *testing.T) {
+	q := MustCompileJSONPath("$..price")
+	if q.String() != "$..price" {
+		t.Errorf("String() = %q", q.String())
+	}
+	j := mustQuery(t, jsonPathDoc)
+	values, err := j.QueryValues("data.items[?(@.price > 2)].price")
+	if err != nil || fmt.Sprint(values) != "[5 4]" {
+		t.Errorf("QueryValues = %v, %v", values, err)
+	}
+	m, _ := j.Object()
+	if got := len(q.Find(m)); got != 4 {
+		t.Errorf("Find matched %d prices, want 4", got)
+	}
+	defer func() {
+		if recover() == nil {
+			t.Error("MustCompileJSONPath did not panic")
+		}
+	}()
+	MustCompileJSONPath("a[")
+}
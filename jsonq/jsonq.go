@@ -1,14 +1,29 @@
 package jsonq
 
 import (
+	"bytes"
+	"encoding/json"
 	"fmt"
+	"io"
 	"strconv"
+	"strings"
+	"time"
 )
 
 // JSONQuery is an object that enables querying of a Go map with a simple
 // positional query language.
+//
+// Every getter accepts either a single path expression (see ParsePath):
+//
+//	jq.String("data.items[0].name")
+//
+// or the path as variadic segments, where numeric segments index arrays:
+//
+//	jq.String("data", "items", "0", "name")
+//
+// The document may be any json value: an object, an array or a scalar.
 type JSONQuery struct {
-	blob map[string]interface{}
+	blob interface{}
 }
 
 // stringFromInterface converts an interface{} to a string and returns an error if types don't match.
@@ -17,7 +32,7 @@ func stringFromInterface(val interface{}) (string, error) {
 	case string:
 		return val.(string), nil
 	}
-	return "", fmt.Errorf("Expected string value for String, got \"%v\"", val)
+	return "", mismatch("string", val)
 }
 
 // getstringFromInterface converts an interface{} to a string and returns an error if types don't match.
@@ -35,7 +50,7 @@ func boolFromInterface(val interface{}) (bool, error) {
 	case bool:
 		return val.(bool), nil
 	}
-	return false, fmt.Errorf("Expected boolean value for Bool, got \"%v\"", val)
+	return false, mismatch("bool", val)
 }
 
 // floatFromInterface converts an interface{} to a float64 and returns an error if types don't match.
@@ -45,29 +60,34 @@ func floatFromInterface(val interface{}) (float64, error) {
 		return val.(float64), nil
 	case int:
 		return float64(val.(int)), nil
+	case int64:
+		return float64(val.(int64)), nil
+	case json.Number:
+		fval, err := val.(json.Number).Float64()
+		if err == nil {
+			return fval, nil
+		}
 	case string:
 		fval, err := strconv.ParseFloat(val.(string), 64)
 		if err == nil {
 			return fval, nil
 		}
 	}
-	return 0.0, fmt.Errorf("Expected numeric value for Float, got \"%v\"", val)
+	return 0.0, mismatch("number", val)
 }
 
-// intFromInterface converts an interface{} to an int and returns an error if types don't match.
+// intFromInterface converts an interface{} holding a whole number to an int and
+// returns an error if types don't match, the value has a fractional part or it
+// does not fit.
 func intFromInterface(val interface{}) (int, error) {
-	switch val.(type) {
-	case float64:
-		return int(val.(float64)), nil
-	case string:
-		ival, err := strconv.ParseFloat(val.(string), 64)
-		if err == nil {
-			return int(ival), nil
-		}
-	case int:
-		return val.(int), nil
+	i, err := exactInt64(val, "int")
+	if err != nil {
+		return 0, err
+	}
+	if int64(int(i)) != i {
+		return 0, &PathError{Err: ErrTypeMismatch, Expected: "int", Actual: "number", Reason: "out of range"}
 	}
-	return 0, fmt.Errorf("Expected numeric value for Int, got \"%v\"", val)
+	return int(i), nil
 }
 
 // GetintFromInterface converts an interface{} to an int and returns an error if types don't match.
@@ -82,6 +102,11 @@ func GetintFromInterface(val interface{}) int {
 		}
 	case int:
 		return val.(int)
+	case json.Number:
+		ival, err := val.(json.Number).Float64()
+		if err == nil {
+			return int(ival)
+		}
 	}
 	return 0
 }
@@ -92,7 +117,7 @@ func objectFromInterface(val interface{}) (map[string]interface{}, error) {
 	case map[string]interface{}:
 		return val.(map[string]interface{}), nil
 	}
-	return map[string]interface{}{}, fmt.Errorf("Expected json object for Object, got \"%v\"", val)
+	return map[string]interface{}{}, mismatch("object", val)
 }
 
 // arrayFromInterface converts an interface{} to an []interface{} and returns an error if types don't match.
@@ -101,64 +126,169 @@ func arrayFromInterface(val interface{}) ([]interface{}, error) {
 	case []interface{}:
 		return val.([]interface{}), nil
 	}
-	return []interface{}{}, fmt.Errorf("Expected json array for Array, got \"%v\"", val)
+	return []interface{}{}, mismatch("array", val)
+}
+
+// durationFromInterface converts a duration string such as "1m30s" or a number
+// of seconds to a time.Duration and returns an error if types don't match.
+func durationFromInterface(val interface{}) (time.Duration, error) {
+	if s, ok := val.(string); ok {
+		if d, err := time.ParseDuration(strings.TrimSpace(s)); err == nil {
+			return d, nil
+		}
+	} else if f, ok := numberValue(val); ok {
+		return time.Duration(f * float64(time.Second)), nil
+	}
+	return 0, mismatch("duration", val)
+}
+
+// NewQuery creates a new JsonQuery obj from a decoded json value: an object,
+// an array, a string, a number (float64 or json.Number), a bool or nil.
+// Other types return an error.
+func NewQuery(data interface{}) (*JSONQuery, error) {
+	switch data.(type) {
+	case map[string]interface{}, []interface{}, string, float64, json.Number, bool, nil:
+		return &JSONQuery{blob: data}, nil
+	}
+	return nil, fmt.Errorf("jsonq: NewQuery expects a decoded json value, got %T", data)
 }
 
-// NewQuery creates a new JsonQuery obj from an interface{}.
-func NewQuery(data interface{}) *JSONQuery {
-	j := new(JSONQuery)
-	j.blob = data.(map[string]interface{})
-	return j
+// FromBytes decodes a json document and wraps it in a JSONQuery.
+func FromBytes(data []byte) (*JSONQuery, error) {
+	return FromReader(bytes.NewReader(data))
+}
+
+// FromString decodes a json document and wraps it in a JSONQuery.
+func FromString(data string) (*JSONQuery, error) {
+	return FromReader(strings.NewReader(data))
+}
+
+// FromReader decodes a single json document from r and wraps it in a
+// JSONQuery. Anything but whitespace after the document is an error.
+// Numbers are decoded as json.Number so that large integers such as ids and
+// millisecond timestamps keep full precision; Interface, Object and Array
+// return them as json.Number rather than float64.
+func FromReader(r io.Reader) (*JSONQuery, error) {
+	dec := json.NewDecoder(r)
+	dec.UseNumber()
+	var data interface{}
+	if err := dec.Decode(&data); err != nil {
+		if err == io.EOF {
+			return nil, fmt.Errorf("jsonq: empty json document")
+		}
+		return nil, fmt.Errorf("jsonq: invalid json document: %s", err)
+	}
+	if _, err := dec.Token(); err != io.EOF {
+		return nil, fmt.Errorf("jsonq: invalid json document: unexpected data after top-level value")
+	}
+	return &JSONQuery{blob: data}, nil
 }
 
 // Bool extracts a bool the JsonQuery
 func (j *JSONQuery) Bool(s ...string) (bool, error) {
-	val, err := rquery(j.blob, s...)
+	val, p, err := j.lookup(s...)
 	if err != nil {
 		return false, err
 	}
-	return boolFromInterface(val)
+	v, err := boolFromInterface(val)
+	return v, atPath(err, p)
 }
 
 // Float extracts a float from the JsonQuery
 func (j *JSONQuery) Float(s ...string) (float64, error) {
-	val, err := rquery(j.blob, s...)
+	val, p, err := j.lookup(s...)
 	if err != nil {
 		return 0.0, err
 	}
-	return floatFromInterface(val)
+	v, err := floatFromInterface(val)
+	return v, atPath(err, p)
 }
 
-// Int extracts an int from the JsonQuery
+// Int extracts an int from the JsonQuery. Fractional values and values out of
+// range are errors; use GetInt to truncate.
 func (j *JSONQuery) Int(s ...string) (int, error) {
-	val, err := rquery(j.blob, s...)
+	val, p, err := j.lookup(s...)
 	if err != nil {
 		return 0, err
 	}
-	return intFromInterface(val)
+	v, err := intFromInterface(val)
+	return v, atPath(err, p)
 }
 
 //GetInt extracts an int from the JsonQuery
 func (j *JSONQuery) GetInt(s ...string) int {
-	val, err := rquery(j.blob, s...)
+	val, _, err := j.lookup(s...)
 	if err != nil {
 		return 0
 	}
 	return GetintFromInterface(val)
 }
 
+// GetBool extracts a bool from the JsonQuery, false if missing or not a bool
+func (j *JSONQuery) GetBool(s ...string) bool {
+	val, err := j.Bool(s...)
+	if err != nil {
+		return false
+	}
+	return val
+}
+
+// GetFloat extracts a float from the JsonQuery, 0 if missing or not numeric
+func (j *JSONQuery) GetFloat(s ...string) float64 {
+	val, err := j.Float(s...)
+	if err != nil {
+		return 0.0
+	}
+	return val
+}
+
+// Int64 extracts an int64 from the JsonQuery. Fractional values and values out
+// of range are errors rather than truncated; documents decoded by FromBytes,
+// FromReader and FromString keep full precision above 2^53.
+func (j *JSONQuery) Int64(s ...string) (int64, error) {
+	val, p, err := j.lookup(s...)
+	if err != nil {
+		return 0, err
+	}
+	v, err := exactInt64(val, "int64")
+	return v, atPath(err, p)
+}
+
+// Uint64 extracts a uint64 from the JsonQuery. Negative, fractional and out of
+// range values are errors.
+func (j *JSONQuery) Uint64(s ...string) (uint64, error) {
+	val, p, err := j.lookup(s...)
+	if err != nil {
+		return 0, err
+	}
+	v, err := exactUint64(val, "uint64")
+	return v, atPath(err, p)
+}
+
+// Duration extracts a time.Duration from the JsonQuery. Strings are parsed
+// with time.ParseDuration ("1m30s"), numbers are seconds.
+func (j *JSONQuery) Duration(s ...string) (time.Duration, error) {
+	val, p, err := j.lookup(s...)
+	if err != nil {
+		return 0, err
+	}
+	v, err := durationFromInterface(val)
+	return v, atPath(err, p)
+}
+
 // String extracts a string from the JsonQuery
 func (j *JSONQuery) String(s ...string) (string, error) {
-	val, err := rquery(j.blob, s...)
+	val, p, err := j.lookup(s...)
 	if err != nil {
 		return "", err
 	}
-	return stringFromInterface(val)
+	v, err := stringFromInterface(val)
+	return v, atPath(err, p)
 }
 
 // GetString extracts a string from the JsonQuery
 func (j *JSONQuery) GetString(s ...string) string {
-	val, err := rquery(j.blob, s...)
+	val, _, err := j.lookup(s...)
 	if err != nil {
 		return ""
 	}
@@ -167,25 +297,27 @@ func (j *JSONQuery) GetString(s ...string) string {
 
 // Object extracts a json object from the JsonQuery
 func (j *JSONQuery) Object(s ...string) (map[string]interface{}, error) {
-	val, err := rquery(j.blob, s...)
+	val, p, err := j.lookup(s...)
 	if err != nil {
 		return map[string]interface{}{}, err
 	}
-	return objectFromInterface(val)
+	v, err := objectFromInterface(val)
+	return v, atPath(err, p)
 }
 
 // Array extracts a []interface{} from the JsonQuery
 func (j *JSONQuery) Array(s ...string) ([]interface{}, error) {
-	val, err := rquery(j.blob, s...)
+	val, p, err := j.lookup(s...)
 	if err != nil {
 		return []interface{}{}, err
 	}
-	return arrayFromInterface(val)
+	v, err := arrayFromInterface(val)
+	return v, atPath(err, p)
 }
 
 // Interface extracts an interface{} from the JsonQuery
 func (j *JSONQuery) Interface(s ...string) (interface{}, error) {
-	val, err := rquery(j.blob, s...)
+	val, _, err := j.lookup(s...)
 	if err != nil {
 		return nil, err
 	}
@@ -194,7 +326,7 @@ func (j *JSONQuery) Interface(s ...string) (interface{}, error) {
 
 // ArrayOfStrings extracts an array of strings from some json
 func (j *JSONQuery) ArrayOfStrings(s ...string) ([]string, error) {
-	array, err := j.Array(s...)
+	array, p, err := j.array(s...)
 	if err != nil {
 		return []string{}, err
 	}
@@ -202,7 +334,7 @@ func (j *JSONQuery) ArrayOfStrings(s ...string) ([]string, error) {
 	for index, val := range array {
 		toReturn[index], err = stringFromInterface(val)
 		if err != nil {
-			return toReturn, err
+			return toReturn, atPath(err, p.Append(Segment{Index: index, IsIndex: true}))
 		}
 	}
 	return toReturn, nil
@@ -210,7 +342,7 @@ func (j *JSONQuery) ArrayOfStrings(s ...string) ([]string, error) {
 
 // ArrayOfInts extracts an array of ints from some json
 func (j *JSONQuery) ArrayOfInts(s ...string) ([]int, error) {
-	array, err := j.Array(s...)
+	array, p, err := j.array(s...)
 	if err != nil {
 		return []int{}, err
 	}
@@ -218,7 +350,7 @@ func (j *JSONQuery) ArrayOfInts(s ...string) ([]int, error) {
 	for index, val := range array {
 		toReturn[index], err = intFromInterface(val)
 		if err != nil {
-			return toReturn, err
+			return toReturn, atPath(err, p.Append(Segment{Index: index, IsIndex: true}))
 		}
 	}
 	return toReturn, nil
@@ -226,7 +358,7 @@ func (j *JSONQuery) ArrayOfInts(s ...string) ([]int, error) {
 
 // ArrayOfFloats extracts an array of float64s from some json
 func (j *JSONQuery) ArrayOfFloats(s ...string) ([]float64, error) {
-	array, err := j.Array(s...)
+	array, p, err := j.array(s...)
 	if err != nil {
 		return []float64{}, err
 	}
@@ -234,7 +366,7 @@ func (j *JSONQuery) ArrayOfFloats(s ...string) ([]float64, error) {
 	for index, val := range array {
 		toReturn[index], err = floatFromInterface(val)
 		if err != nil {
-			return toReturn, err
+			return toReturn, atPath(err, p.Append(Segment{Index: index, IsIndex: true}))
 		}
 	}
 	return toReturn, nil
@@ -242,7 +374,7 @@ func (j *JSONQuery) ArrayOfFloats(s ...string) ([]float64, error) {
 
 // ArrayOfBools extracts an array of bools from some json
 func (j *JSONQuery) ArrayOfBools(s ...string) ([]bool, error) {
-	array, err := j.Array(s...)
+	array, p, err := j.array(s...)
 	if err != nil {
 		return []bool{}, err
 	}
@@ -250,7 +382,7 @@ func (j *JSONQuery) ArrayOfBools(s ...string) ([]bool, error) {
 	for index, val := range array {
 		toReturn[index], err = boolFromInterface(val)
 		if err != nil {
-			return toReturn, err
+			return toReturn, atPath(err, p.Append(Segment{Index: index, IsIndex: true}))
 		}
 	}
 	return toReturn, nil
@@ -258,7 +390,7 @@ func (j *JSONQuery) ArrayOfBools(s ...string) ([]bool, error) {
 
 // ArrayOfObjects extracts an array of map[string]interface{} (objects) from some json
 func (j *JSONQuery) ArrayOfObjects(s ...string) ([]map[string]interface{}, error) {
-	array, err := j.Array(s...)
+	array, p, err := j.array(s...)
 	if err != nil {
 		return []map[string]interface{}{}, err
 	}
@@ -266,7 +398,7 @@ func (j *JSONQuery) ArrayOfObjects(s ...string) ([]map[string]interface{}, error
 	for index, val := range array {
 		toReturn[index], err = objectFromInterface(val)
 		if err != nil {
-			return toReturn, err
+			return toReturn, atPath(err, p.Append(Segment{Index: index, IsIndex: true}))
 		}
 	}
 	return toReturn, nil
@@ -274,7 +406,7 @@ func (j *JSONQuery) ArrayOfObjects(s ...string) ([]map[string]interface{}, error
 
 // ArrayOfArrays extracts an array of []interface{} (arrays) from some json
 func (j *JSONQuery) ArrayOfArrays(s ...string) ([][]interface{}, error) {
-	array, err := j.Array(s...)
+	array, p, err := j.array(s...)
 	if err != nil {
 		return [][]interface{}{}, err
 	}
@@ -282,7 +414,7 @@ func (j *JSONQuery) ArrayOfArrays(s ...string) ([][]interface{}, error) {
 	for index, val := range array {
 		toReturn[index], err = arrayFromInterface(val)
 		if err != nil {
-			return toReturn, err
+			return toReturn, atPath(err, p.Append(Segment{Index: index, IsIndex: true}))
 		}
 	}
 	return toReturn, nil
@@ -293,55 +425,20 @@ func (j *JSONQuery) Matrix2D(s ...string) ([][]interface{}, error) {
 	return j.ArrayOfArrays(s...)
 }
 
-// Recursively query a decoded json blob
-func rquery(blob interface{}, s ...string) (interface{}, error) {
-	var (
-		val interface{}
-		err error
-	)
-	val = blob
-	for _, q := range s {
-		val, err = query(val, q)
-		if err != nil {
-			return nil, err
-		}
-	}
-	switch val.(type) {
-	case nil:
-		return nil, fmt.Errorf("Nil value found at %s", s[len(s)-1])
-	}
-	return val, nil
+// lookup resolves getter arguments (a path expression or variadic segments)
+// against the document and returns the value and its path.
+func (j *JSONQuery) lookup(s ...string) (interface{}, Path, error) {
+	p := toPath(j.blob, s)
+	val, err := p.get(j.blob)
+	return val, p, err
 }
 
-// query a json blob for a single field or index.  If query is a string, then
-// the blob is treated as a json object (map[string]interface{}).  If query is
-// an integer, the blob is treated as a json array ([]interface{}).  Any kind
-// of key or index error will result in a nil return value with an error set.
-func query(blob interface{}, query string) (interface{}, error) {
-	index, err := strconv.Atoi(query)
-	// if it's an integer, then we treat the current interface as an array
-	if err == nil {
-		switch blob.(type) {
-		case []interface{}:
-		default:
-			return nil, fmt.Errorf("Array index on non-array %v", blob)
-		}
-		if len(blob.([]interface{})) > index {
-			return blob.([]interface{})[index], nil
-		}
-		return nil, fmt.Errorf("Array index %d on array %v out of bounds", index, blob)
-	}
-
-	// blob is likely an object, but verify first
-	switch blob.(type) {
-	case map[string]interface{}:
-	default:
-		return nil, fmt.Errorf("Object lookup \"%s\" on non-object %v", query, blob)
-	}
-
-	val, ok := blob.(map[string]interface{})[query]
-	if !ok {
-		return nil, fmt.Errorf("Object %v does not contain field %s", blob, query)
+// array looks up an array for the ArrayOf getters.
+func (j *JSONQuery) array(s ...string) ([]interface{}, Path, error) {
+	val, p, err := j.lookup(s...)
+	if err != nil {
+		return nil, p, err
 	}
-	return val, nil
+	array, err := arrayFromInterface(val)
+	return array, p, atPath(err, p)
 }
@@ -0,0 +1,96 @@
+package jsonq
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestTopLevelArray(t *testing.T) {
+	j := mustQuery(t, `[{"a":1},{"a":2}]`)
+	if n, err := j.Int("[1].a"); n != 2 || err != nil {
+		t.Errorf("[1].a = %d, %v", n, err)
+	}
+	if n, err := j.Int("0", "a"); n != 1 || err != nil {
+		t.Errorf("variadic index on a root array = %d, %v", n, err)
+	}
+	if objs, err := j.ArrayOfObjects(); len(objs) != 2 || err != nil {
+		t.Errorf("ArrayOfObjects() = %v, %v", objs, err)
+	}
+	if _, err := j.Object(); err == nil {
+		t.Error("Object() of an array succeeded")
+	}
+	if err := j.Delete("[0]"); err != nil {
+		t.Fatal(err)
+	}
+	// growing the root array replaces the document's slice
+	if err := j.Set("[3]", "x"); err != nil {
+		t.Fatal(err)
+	}
+	if b, _ := j.MarshalJSON(); string(b) != `[{"a":2},null,null,"x"]` {
+		t.Errorf("document = %s", b)
+	}
+}
+
+func TestTopLevelScalar(t *testing.T) {
+	tests := []struct {
+		doc  string
+		want string
+	}{
+		{` "str" `, "str"},
+		{`12`, ""},
+		{`true`, ""},
+		{`null`, ""},
+	}
+	for _, tt := range tests {
+		j, err := FromBytes([]byte(tt.doc))
+		if err != nil {
+			t.Fatalf("FromBytes(%s): %v", tt.doc, err)
+		}
+		if got := j.GetString(); got != tt.want {
+			t.Errorf("GetString() of %s = %q, want %q", tt.doc, got, tt.want)
+		}
+		// key lookups on a scalar fail instead of panicking
+		if _, err := j.String("a.b"); err == nil {
+			t.Errorf("String(a.b) of %s succeeded", tt.doc)
+		}
+	}
+	j := mustQuery(t, `12`)
+	if n, err := j.Int(); n != 12 || err != nil {
+		t.Errorf("Int() = %d, %v", n, err)
+	}
+}
+
+func TestFromReaderInvalid(t *testing.T) {
+	for _, bad := range []string{"", "  ", "{", `{"a":1} x`, `1 2`, `[1,]`} {
+		if _, err := FromReader(strings.NewReader(bad)); err == nil {
+			t.Errorf("FromReader(%q) accepted", bad)
+		}
+	}
+	if _, err := FromString(`{"a":1}` + "\n\t "); err != nil {
+		t.Errorf("trailing whitespace rejected: %v", err)
+	}
+}
+
+func TestNewQuery(t *testing.T) {
+	for _, bad := range []interface{}{struct{}{}, map[string]string{}, []string{"a"}, 1} {
+		if _, err := NewQuery(bad); err == nil {
+			t.Errorf("NewQuery(%T) accepted", bad)
+		}
+	}
+	for _, v := range []interface{}{map[string]interface{}{}, []interface{}{1.0}, "s", 1.5, true, nil} {
+		if _, err := NewQuery(v); err != nil {
+			t.Errorf("NewQuery(%#v): %v", v, err)
+		}
+	}
+	nm, _ := NewQuery(map[string]interface{}(nil))
+	if err := nm.Set("k", 1); err != nil || nm.GetInt("k") != 1 {
+		t.Errorf("Set on a nil map: %v", err)
+	}
+	var zero JSONQuery
+	if err := zero.Set("a.b[1]", 1); err != nil {
+		t.Fatal(err)
+	}
+	if b, _ := zero.MarshalJSON(); string(b) != `{"a":{"b":[null,1]}}` {
+		t.Errorf("zero value document = %s", b)
+	}
+}
@@ -0,0 +1,276 @@
+package jsonq
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+)
+
+// ArrayMergeStrategy controls how Merge combines arrays present in both documents.
+type ArrayMergeStrategy int
+
+const (
+	// ArrayReplace replaces the array with the one from the other document.
+	ArrayReplace ArrayMergeStrategy = iota
+	// ArrayAppend appends the elements of the other document's array.
+	ArrayAppend
+	// ArrayMergeByIndex merges elements at the same index, keeping extra
+	// elements of either array.
+	ArrayMergeByIndex
+)
+
+// MergeOptions configures Merge. A nil *MergeOptions uses the defaults.
+type MergeOptions struct {
+	Arrays ArrayMergeStrategy // default ArrayReplace
+}
+
+// Set stores value at path, creating missing intermediate objects and arrays:
+// a missing container becomes an array if the next segment is an index and
+// an object otherwise. Arrays are padded with nulls when index is past the
+// end. Values other than decoded json types (structs, typed slices and maps,
+// integers) are converted through encoding/json so the getters can read them.
+//
+// The path is interpreted like a getter argument, so Set("a.b", v) sets key b
+// of object a unless the document has a literal top-level key "a.b". An empty
+// path replaces the whole document. JSONQuery is not safe for concurrent
+// mutation.
+func (j *JSONQuery) Set(path string, value interface{}) error {
+	v, err := normalizeValue(value)
+	if err != nil {
+		return err
+	}
+	p := toPath(j.blob, []string{path})
+	if len(p) == 0 {
+		j.blob = v
+		return nil
+	}
+	blob, err := setIn(j.blob, p, 0, v)
+	if err != nil {
+		return err
+	}
+	j.blob = blob
+	return nil
+}
+
+// Delete removes the object member or array element at path. Later array
+// elements move down by one.
+func (j *JSONQuery) Delete(path string) error {
+	p := toPath(j.blob, []string{path})
+	if len(p) == 0 {
+		return fmt.Errorf("jsonq: Delete requires a non-empty path")
+	}
+	if _, err := p.resolve(j.blob); err != nil {
+		return err
+	}
+	parent, _ := p[:len(p)-1].resolve(j.blob)
+	last := p[len(p)-1]
+	switch c := parent.(type) {
+	case map[string]interface{}:
+		delete(c, last.Key)
+	case []interface{}:
+		index := last.Index
+		if index < 0 {
+			index += len(c)
+		}
+		arr := append(c[:index:index], c[index+1:]...)
+		if len(p) == 1 {
+			j.blob = arr
+			return nil
+		}
+		blob, err := setIn(j.blob, p[:len(p)-1], 0, arr)
+		if err != nil {
+			return err
+		}
+		j.blob = blob
+	}
+	return nil
+}
+
+// Append appends value to the array at path, creating the array (and any
+// missing intermediate containers) if the path does not exist.
+func (j *JSONQuery) Append(path string, value interface{}) error {
+	v, err := normalizeValue(value)
+	if err != nil {
+		return err
+	}
+	p := toPath(j.blob, []string{path})
+	if len(p) == 0 {
+		return fmt.Errorf("jsonq: Append requires a non-empty path")
+	}
+	cur, err := p.resolve(j.blob)
+	if err != nil {
+		cur = nil
+	}
+	var arr []interface{}
+	if cur != nil {
+		var ok bool
+		if arr, ok = cur.([]interface{}); !ok {
+			return atPath(mismatch("array", cur), p)
+		}
+	}
+	blob, err := setIn(j.blob, p, 0, append(arr, v))
+	if err != nil {
+		return err
+	}
+	j.blob = blob
+	return nil
+}
+
+// Merge deep-merges other into the document: objects are merged member by
+// member, arrays according to opts.Arrays, and any other value in other
+// (including null) replaces the existing one. Merged values are copied, so
+// later changes to either document do not affect the other.
+func (j *JSONQuery) Merge(other *JSONQuery, opts *MergeOptions) {
+	var o MergeOptions
+	if opts != nil {
+		o = *opts
+	}
+	if other == nil {
+		return
+	}
+	j.blob = mergeValue(j.blob, other.blob, o.Arrays)
+}
+
+// MarshalJSON encodes the (possibly modified) document.
+func (j *JSONQuery) MarshalJSON() ([]byte, error) {
+	return json.Marshal(j.blob)
+}
+
+// setIn stores value at p[i:] inside container and returns the container,
+// which is new if container was nil or an array had to grow.
+func setIn(container interface{}, p Path, i int, value interface{}) (interface{}, error) {
+	seg := p[i]
+	if container == nil {
+		if seg.IsIndex && seg.Key == "" {
+			container = []interface{}{}
+		} else {
+			container = map[string]interface{}{}
+		}
+	}
+	switch c := container.(type) {
+	case map[string]interface{}:
+		if seg.IsIndex && seg.Key == "" {
+			return nil, atPath(mismatch("array", c), p[:i+1])
+		}
+		if c == nil {
+			c = map[string]interface{}{}
+		}
+		if i == len(p)-1 {
+			c[seg.Key] = value
+			return c, nil
+		}
+		child, err := setIn(c[seg.Key], p, i+1, value)
+		if err != nil {
+			return nil, err
+		}
+		c[seg.Key] = child
+		return c, nil
+	case []interface{}:
+		if !seg.IsIndex {
+			return nil, atPath(mismatch("object", c), p[:i+1])
+		}
+		index := seg.Index
+		if index < 0 {
+			index += len(c)
+			if index < 0 {
+				return nil, atPath(outOfRange(seg.Index, len(c)), p[:i+1])
+			}
+		}
+		for len(c) <= index {
+			c = append(c, nil)
+		}
+		if i == len(p)-1 {
+			c[index] = value
+			return c, nil
+		}
+		child, err := setIn(c[index], p, i+1, value)
+		if err != nil {
+			return nil, err
+		}
+		c[index] = child
+		return c, nil
+	}
+	expected := "object"
+	if seg.IsIndex && seg.Key == "" {
+		expected = "array"
+	}
+	return nil, atPath(mismatch(expected, container), p[:i+1])
+}
+
+// mergeValue merges src into dst and returns the result.
+func mergeValue(dst, src interface{}, arrays ArrayMergeStrategy) interface{} {
+	switch s := src.(type) {
+	case map[string]interface{}:
+		d, ok := dst.(map[string]interface{})
+		if !ok || d == nil {
+			return copyValue(s)
+		}
+		for k, v := range s {
+			d[k] = mergeValue(d[k], v, arrays)
+		}
+		return d
+	case []interface{}:
+		d, ok := dst.([]interface{})
+		if !ok {
+			return copyValue(s)
+		}
+		switch arrays {
+		case ArrayAppend:
+			return append(d, copyValue(s).([]interface{})...)
+		case ArrayMergeByIndex:
+			for i, v := range s {
+				if i < len(d) {
+					d[i] = mergeValue(d[i], v, arrays)
+				} else {
+					d = append(d, copyValue(v))
+				}
+			}
+			return d
+		}
+		return copyValue(s)
+	}
+	return src
+}
+
+// copyValue deep-copies the objects and arrays of a decoded json value.
+func copyValue(v interface{}) interface{} {
+	switch c := v.(type) {
+	case map[string]interface{}:
+		m := make(map[string]interface{}, len(c))
+		for k, e := range c {
+			m[k] = copyValue(e)
+		}
+		return m
+	case []interface{}:
+		a := make([]interface{}, len(c))
+		for i, e := range c {
+			a[i] = copyValue(e)
+		}
+		return a
+	}
+	return v
+}
+
+// normalizeValue converts value to the types produced by json decoding, with
+// numbers as json.Number like FromBytes.
+func normalizeValue(value interface{}) (interface{}, error) {
+	switch v := value.(type) {
+	case nil, bool, float64, string, json.Number:
+		return v, nil
+	case map[string]interface{}, []interface{}:
+		return copyValue(v), nil
+	case *JSONQuery:
+		return copyValue(v.blob), nil
+	}
+	data, err := json.Marshal(value)
+	if err != nil {
+		return nil, fmt.Errorf("jsonq: cannot convert %T to a json value: %s", value, err)
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var v interface{}
+	if err := dec.Decode(&v); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
@@ -0,0 +1,100 @@
+package jsonq
+
+import (
+	"testing"
+)
+
+func TestSet(t *testing.T) {
+	j := mustQuery(t, `{"a":{"list":[1,2,3]},"n":null,"holes":[null],"s":"str"}`)
+	tests := []struct {
+		path  string
+		value interface{}
+	}{
+		{"b.c[2].d", "v"},           // creates objects and a null-padded array
+		{"a.list[-1]", 30},          // negative index replaces the last element
+		{"n.x.y", true},             // a null intermediate becomes an object
+		{"holes[0][1]", "deep"},     // a null array element becomes an array
+		{"obj", struct{ N int }{5}}, // structs are converted through encoding/json
+	}
+	for _, tt := range tests {
+		if err := j.Set(tt.path, tt.value); err != nil {
+			t.Fatalf("Set(%q): %v", tt.path, err)
+		}
+	}
+	b, _ := j.MarshalJSON()
+	want := `{"a":{"list":[1,2,30]},"b":{"c":[null,null,{"d":"v"}]},"holes":[[null,"deep"]],"n":{"x":{"y":true}},"obj":{"N":5},"s":"str"}`
+	if string(b) != want {
+		t.Errorf("document = %s\nwant       %s", b, want)
+	}
+	if n, err := j.Int("obj.N"); n != 5 || err != nil {
+		t.Errorf("converted struct not readable: %d, %v", n, err)
+	}
+	for _, bad := range []string{"a.list.k", "s.x", "a[0]", "a.list[-9]"} {
+		if err := j.Set(bad, 1); err == nil {
+			t.Errorf("Set(%q) succeeded", bad)
+		}
+	}
+}
+
+func TestDeleteAppend(t *testing.T) {
+	j := mustQuery(t, `{"list":[1,2,3],"x":1,"n":null}`)
+	if err := j.Delete("list[0]"); err != nil {
+		t.Fatal(err)
+	}
+	if err := j.Delete("list[-1]"); err != nil {
+		t.Fatal(err)
+	}
+	if err := j.Delete("x"); err != nil {
+		t.Fatal(err)
+	}
+	for _, bad := range []string{"nope", "list[5]", "list.k", ""} {
+		if err := j.Delete(bad); err == nil {
+			t.Errorf("Delete(%q) succeeded", bad)
+		}
+	}
+	if err := j.Append("list", 4); err != nil {
+		t.Fatal(err)
+	}
+	if err := j.Append("n", "null becomes an array"); err != nil {
+		t.Fatal(err)
+	}
+	if err := j.Append("new.arr", []string{"q"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := j.Append("new", 1); err == nil {
+		t.Error("Append to an object succeeded")
+	}
+	b, _ := j.MarshalJSON()
+	if want := `{"list":[2,4],"n":["null becomes an array"],"new":{"arr":[["q"]]}}`; string(b) != want {
+		t.Errorf("document = %s\nwant       %s", b, want)
+	}
+}
+
+func TestMerge(t *testing.T) {
+	tests := []struct {
+		arrays ArrayMergeStrategy
+		want   string
+	}{
+		{ArrayReplace, `{"a":{"list":[9],"x":1,"y":true},"b":null}`},
+		{ArrayAppend, `{"a":{"list":[1,2,9],"x":1,"y":true},"b":null}`},
+		{ArrayMergeByIndex, `{"a":{"list":[9,2],"x":1,"y":true},"b":null}`},
+	}
+	for _, tt := range tests {
+		dst := mustQuery(t, `{"a":{"list":[1,2],"x":1},"b":2}`)
+		src := mustQuery(t, `{"a":{"list":[9],"y":true},"b":null}`)
+		dst.Merge(src, &MergeOptions{Arrays: tt.arrays})
+		if b, _ := dst.MarshalJSON(); string(b) != tt.want {
+			t.Errorf("strategy %d: %s, want %s", tt.arrays, b, tt.want)
+		}
+		// merged values are copies
+		src.Set("a.y", false)
+		if v, _ := dst.Bool("a.y"); !v {
+			t.Errorf("strategy %d: merged value shares memory with source", tt.arrays)
+		}
+	}
+	dst := mustQuery(t, `{"k":1}`)
+	dst.Merge(nil, nil)
+	if b, _ := dst.MarshalJSON(); string(b) != `{"k":1}` {
+		t.Errorf("Merge(nil) changed the document: %s", b)
+	}
+}
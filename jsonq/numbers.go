@@ -0,0 +1,165 @@
+package jsonq
+
+import (
+	"encoding/json"
+	"math"
+	"math/big"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// numberValue converts the numeric types a decoded document may contain to float64.
+func numberValue(v interface{}) (float64, bool) {
+	switch n := v.(type) {
+	case float64:
+		return n, true
+	case int:
+		return float64(n), true
+	case int64:
+		return float64(n), true
+	case json.Number:
+		f, err := n.Float64()
+		return f, err == nil
+	}
+	return 0, false
+}
+
+// numberRat converts a number (float64, int, int64, json.Number or a numeric
+// string) to an exact rational value.
+func numberRat(val interface{}) (*big.Rat, bool) {
+	switch v := val.(type) {
+	case float64:
+		if math.IsNaN(v) || math.IsInf(v, 0) {
+			return nil, false
+		}
+		return new(big.Rat).SetFloat64(v), true
+	case int:
+		return new(big.Rat).SetInt64(int64(v)), true
+	case int64:
+		return new(big.Rat).SetInt64(v), true
+	case json.Number:
+		return parseRat(string(v))
+	case string:
+		return parseRat(strings.TrimSpace(v))
+	}
+	return nil, false
+}
+
+// numberRegexp matches decimal numbers with an optional exponent.
+var numberRegexp = regexp.MustCompile(`^[+-]?(\d+\.?\d*|\.\d+)([eE][+-]?\d+)?$`)
+
+// parseRat parses a decimal number exactly.
+func parseRat(s string) (*big.Rat, bool) {
+	if !numberRegexp.MatchString(s) {
+		return nil, false
+	}
+	return new(big.Rat).SetString(s)
+}
+
+// exactInteger converts val to a big.Int, failing if it is not a number or
+// has a fractional part. name is the expected type reported in errors.
+func exactInteger(val interface{}, name string) (*big.Int, error) {
+	r, ok := numberRat(val)
+	if !ok {
+		return nil, mismatch(name, val)
+	}
+	if !r.IsInt() {
+		return nil, &PathError{Err: ErrTypeMismatch, Expected: name, Actual: "number", Reason: "fractional part"}
+	}
+	return r.Num(), nil
+}
+
+// exactInt64 converts val to an int64 without loss of precision.
+func exactInt64(val interface{}, name string) (int64, error) {
+	if i, ok := val.(int64); ok {
+		return i, nil
+	}
+	if n, ok := val.(json.Number); ok {
+		if i, err := strconv.ParseInt(string(n), 10, 64); err == nil {
+			return i, nil
+		}
+	}
+	i, err := exactInteger(val, name)
+	if err != nil {
+		return 0, err
+	}
+	if !i.IsInt64() {
+		return 0, &PathError{Err: ErrTypeMismatch, Expected: name, Actual: "number", Reason: "out of range"}
+	}
+	return i.Int64(), nil
+}
+
+// exactUint64 converts val to a uint64 without loss of precision.
+func exactUint64(val interface{}, name string) (uint64, error) {
+	i, err := exactInteger(val, name)
+	if err != nil {
+		return 0, err
+	}
+	if i.Sign() < 0 {
+		return 0, &PathError{Err: ErrTypeMismatch, Expected: name, Actual: "number", Reason: "negative"}
+	}
+	if !i.IsUint64() {
+		return 0, &PathError{Err: ErrTypeMismatch, Expected: name, Actual: "number", Reason: "out of range"}
+	}
+	return i.Uint64(), nil
+}
+
+// decimalString formats a number as an exact decimal string without exponent.
+func decimalString(val interface{}) (string, error) {
+	switch v := val.(type) {
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64), nil
+	case int:
+		return strconv.Itoa(v), nil
+	case int64:
+		return strconv.FormatInt(v, 10), nil
+	}
+	r, ok := numberRat(val)
+	if !ok {
+		return "", mismatch("number", val)
+	}
+	if r.IsInt() {
+		return r.Num().String(), nil
+	}
+	// the denominator of a decimal is 2^a * 5^b, which max(a, b) digits represent exactly
+	d := new(big.Int).Set(r.Denom())
+	twos := int(d.TrailingZeroBits())
+	d.Rsh(d, uint(twos))
+	fives, five, m := 0, big.NewInt(5), new(big.Int)
+	for {
+		q, _ := new(big.Int).QuoRem(d, five, m)
+		if m.Sign() != 0 {
+			break
+		}
+		d, fives = q, fives+1
+	}
+	digits := twos
+	if fives > digits {
+		digits = fives
+	}
+	return r.FloatString(digits), nil
+}
+
+// BigInt extracts an arbitrarily large integer from the JsonQuery. Fractional
+// values are errors.
+func (j *JSONQuery) BigInt(s ...string) (*big.Int, error) {
+	val, p, err := j.lookup(s...)
+	if err != nil {
+		return nil, err
+	}
+	v, err := exactInteger(val, "integer")
+	return v, atPath(err, p)
+}
+
+// Decimal extracts a number from the JsonQuery as an exact decimal string
+// without exponent (1.5e3 becomes "1500"), for amounts that must not be
+// rounded through float64. Numeric strings are accepted as well.
+func (j *JSONQuery) Decimal(s ...string) (string, error) {
+	val, p, err := j.lookup(s...)
+	if err != nil {
+		return "", err
+	}
+	v, err := decimalString(val)
+	return v, atPath(err, p)
+}
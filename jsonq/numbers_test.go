@@ -0,0 +1,103 @@
+package jsonq
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+const numbersDoc = `{"msgid":1234567890123456789,"ts":1700000000123,"u":18446744073709551615,"huge":123456789012345678901234567890,"f":1.5,"e":1.5e3,"small":1.25e-7,"s":"9007199254740993","whole":2.0,"items":[{"id":9007199254740993},{"id":9007199254740992}]}`
+
+func TestIntegerPrecision(t *testing.T) {
+	j := mustQuery(t, numbersDoc)
+	tests := []struct {
+		path string
+		want int64
+	}{
+		{"msgid", 1234567890123456789},
+		{"ts", 1700000000123},
+		{"e", 1500},
+		{"whole", 2},
+		{"s", 9007199254740993}, // numeric strings keep every digit too
+	}
+	for _, tt := range tests {
+		if v, err := j.Int64(tt.path); err != nil || v != tt.want {
+			t.Errorf("Int64(%q) = %d, %v; want %d", tt.path, v, err, tt.want)
+		}
+	}
+	if v, err := j.Uint64("u"); err != nil || v != 18446744073709551615 {
+		t.Errorf("Uint64(u) = %d, %v", v, err)
+	}
+	if _, err := j.Int64("u"); !errors.Is(err, ErrTypeMismatch) || !strings.Contains(err.Error(), "out of range") {
+		t.Errorf("Int64 overflow: %v", err)
+	}
+	if _, err := j.Uint64("f"); err == nil {
+		t.Error("Uint64 of a fraction succeeded")
+	}
+}
+
+// TestFractionalNumbers checks that Int rejects json.Number values with a
+// fractional part, whatever their notation, instead of truncating them.
+func TestFractionalNumbers(t *testing.T) {
+	j := mustQuery(t, `{"f":1.5,"neg":-0.5,"exp":25e-1,"tiny":1e-9,"str":"7.25","whole":3.0,"big":1.5e1}`)
+	for _, path := range []string{"f", "neg", "exp", "tiny", "str"} {
+		if v, err := j.Int(path); !errors.Is(err, ErrTypeMismatch) || !strings.Contains(err.Error(), "fractional part") {
+			t.Errorf("Int(%q) = %d, %v; want a fractional part error", path, v, err)
+		}
+		if _, err := j.Int64(path); err == nil {
+			t.Errorf("Int64(%q) accepted a fraction", path)
+		}
+	}
+	for path, want := range map[string]int{"whole": 3, "big": 15} {
+		if v, err := j.Int(path); err != nil || v != want {
+			t.Errorf("Int(%q) = %d, %v; want %d", path, v, err, want)
+		}
+	}
+	// the lenient getters still truncate
+	if v := j.GetInt("f"); v != 1 {
+		t.Errorf("GetInt(f) = %d, want 1", v)
+	}
+	if v, err := j.Float("exp"); err != nil || v != 2.5 {
+		t.Errorf("Float(exp) = %v, %v", v, err)
+	}
+}
+
+func TestBigNumbers(t *testing.T) {
+	j := mustQuery(t, numbersDoc)
+	if v, err := j.BigInt("huge"); err != nil || v.String() != "123456789012345678901234567890" {
+		t.Errorf("BigInt(huge) = %v, %v", v, err)
+	}
+	if _, err := j.BigInt("f"); err == nil {
+		t.Error("BigInt of a fraction succeeded")
+	}
+	tests := map[string]string{
+		"e":     "1500",
+		"small": "0.000000125",
+		"f":     "1.5",
+		"huge":  "123456789012345678901234567890",
+		"s":     "9007199254740993",
+	}
+	for path, want := range tests {
+		if v, err := j.Decimal(path); err != nil || v != want {
+			t.Errorf("Decimal(%q) = %q, %v; want %q", path, v, err, want)
+		}
+	}
+}
+
+func TestNumberRoundTrip(t *testing.T) {
+	j := mustQuery(t, numbersDoc)
+	// 2^53+1 must not compare equal to 2^53 after a float64 conversion
+	if ms, err := j.Query("items[?(@.id == 9007199254740993)]"); err != nil || len(ms) != 1 {
+		t.Errorf("filter matched %d items, %v; want 1", len(ms), err)
+	}
+	if err := j.Set("n", int64(9007199254740993)); err != nil {
+		t.Fatal(err)
+	}
+	if v, err := j.Int64("n"); err != nil || v != 9007199254740993 {
+		t.Errorf("Int64(n) = %d, %v", v, err)
+	}
+	b, _ := j.MarshalJSON()
+	if !strings.Contains(string(b), `"msgid":1234567890123456789`) {
+		t.Errorf("MarshalJSON lost precision: %s", b)
+	}
+}
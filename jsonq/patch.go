@@ -0,0 +1,519 @@
+package jsonq
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// PatchOperation is one operation of an RFC 6902 JSON Patch. Path and From
+// are JSON Pointers (RFC 6901) such as "/data/items/0/name".
+type PatchOperation struct {
+	Op    string      // add, remove, replace, move, copy or test
+	Path  string      // target location
+	From  string      // source location for move and copy
+	Value interface{} // value for add, replace and test
+}
+
+// Patch is an RFC 6902 JSON Patch.
+type Patch []PatchOperation
+
+// MarshalJSON encodes the operation with only the members its op uses.
+func (op PatchOperation) MarshalJSON() ([]byte, error) {
+	var b bytes.Buffer
+	fmt.Fprintf(&b, `{"op":%s,"path":%s`, jsonString(op.Op), jsonString(op.Path))
+	switch op.Op {
+	case "move", "copy":
+		fmt.Fprintf(&b, `,"from":%s`, jsonString(op.From))
+	case "add", "replace", "test":
+		value, err := json.Marshal(op.Value)
+		if err != nil {
+			return nil, err
+		}
+		b.WriteString(`,"value":`)
+		b.Write(value)
+	}
+	b.WriteByte('}')
+	return b.Bytes(), nil
+}
+
+// UnmarshalJSON decodes an operation, keeping numbers as json.Number.
+func (op *PatchOperation) UnmarshalJSON(data []byte) error {
+	var raw struct {
+		Op    string          `json:"op"`
+		Path  *string         `json:"path"`
+		From  *string         `json:"from"`
+		Value json.RawMessage `json:"value"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	if raw.Path == nil {
+		return fmt.Errorf("jsonq: patch operation %q has no path", raw.Op)
+	}
+	*op = PatchOperation{Op: raw.Op, Path: *raw.Path}
+	switch raw.Op {
+	case "add", "replace", "test":
+		if raw.Value == nil {
+			return fmt.Errorf("jsonq: patch operation %q at %s has no value", raw.Op, op.Path)
+		}
+		dec := json.NewDecoder(bytes.NewReader(raw.Value))
+		dec.UseNumber()
+		if err := dec.Decode(&op.Value); err != nil {
+			return err
+		}
+	case "move", "copy":
+		if raw.From == nil {
+			return fmt.Errorf("jsonq: patch operation %q at %s has no from", raw.Op, op.Path)
+		}
+		op.From = *raw.From
+	case "remove":
+	default:
+		return fmt.Errorf("jsonq: unknown patch operation %q", raw.Op)
+	}
+	return nil
+}
+
+// String encodes the patch as json.
+func (p Patch) String() string {
+	b, _ := json.Marshal(p)
+	return string(b)
+}
+
+// ParsePatch decodes an RFC 6902 JSON Patch document.
+func ParsePatch(data []byte) (Patch, error) {
+	var p Patch
+	if err := json.Unmarshal(data, &p); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// Diff returns a JSON Patch that turns a into b. Objects are compared member
+// by member; arrays element by element, with elements added or removed at
+// the end, so an insertion in the middle shows up as replacements.
+func Diff(a, b *JSONQuery) Patch {
+	var patch Patch
+	diffValues(a.blob, b.blob, nil, true, func(kind byte, p Path, old, new interface{}) {
+		switch kind {
+		case '+':
+			patch = append(patch, PatchOperation{Op: "add", Path: jsonPointer(p), Value: copyValue(new)})
+		case '-':
+			patch = append(patch, PatchOperation{Op: "remove", Path: jsonPointer(p)})
+		case '~':
+			patch = append(patch, PatchOperation{Op: "replace", Path: jsonPointer(p), Value: copyValue(new)})
+		}
+	})
+	return patch
+}
+
+// DiffText returns a human-readable diff from a to b, one change per line:
+//
+//	~ server.port: 8080 -> 9090
+//	+ server.mode: "debug"
+//	- tags[2]: "beta"
+//
+// It returns "" if the documents are equal.
+func DiffText(a, b *JSONQuery) string {
+	var out strings.Builder
+	diffValues(a.blob, b.blob, nil, false, func(kind byte, p Path, old, new interface{}) {
+		switch kind {
+		case '+':
+			fmt.Fprintf(&out, "+ %s: %s\n", pathName(p), compactJSON(new))
+		case '-':
+			fmt.Fprintf(&out, "- %s: %s\n", pathName(p), compactJSON(old))
+		case '~':
+			fmt.Fprintf(&out, "~ %s: %s -> %s\n", pathName(p), compactJSON(old), compactJSON(new))
+		}
+	})
+	return out.String()
+}
+
+// diffValues reports the differences between a and b at p. Removed array
+// elements are reported from the end when patchOrder is set, so that the
+// indexes of earlier removals stay valid.
+func diffValues(a, b interface{}, p Path, patchOrder bool, emit func(kind byte, p Path, old, new interface{})) {
+	switch x := a.(type) {
+	case map[string]interface{}:
+		y, ok := b.(map[string]interface{})
+		if !ok {
+			break
+		}
+		keys := make([]string, 0, len(x)+len(y))
+		for k := range x {
+			keys = append(keys, k)
+		}
+		for k := range y {
+			if _, ok := x[k]; !ok {
+				keys = append(keys, k)
+			}
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			xv, inA := x[k]
+			yv, inB := y[k]
+			kp := p.Append(Segment{Key: k})
+			switch {
+			case !inB:
+				emit('-', kp, xv, nil)
+			case !inA:
+				emit('+', kp, nil, yv)
+			default:
+				diffValues(xv, yv, kp, patchOrder, emit)
+			}
+		}
+		return
+	case []interface{}:
+		y, ok := b.([]interface{})
+		if !ok {
+			break
+		}
+		n := len(x)
+		if len(y) < n {
+			n = len(y)
+		}
+		for i := 0; i < n; i++ {
+			diffValues(x[i], y[i], p.Append(Segment{Index: i, IsIndex: true}), patchOrder, emit)
+		}
+		for i := n; i < len(y); i++ {
+			emit('+', p.Append(Segment{Index: i, IsIndex: true}), nil, y[i])
+		}
+		for k := n; k < len(x); k++ {
+			i := k
+			if patchOrder {
+				i = len(x) - 1 - (k - n)
+			}
+			emit('-', p.Append(Segment{Index: i, IsIndex: true}), x[i], nil)
+		}
+		return
+	}
+	if !jsonEqual(a, b) {
+		emit('~', p, a, b)
+	}
+}
+
+// ApplyPatch applies an RFC 6902 JSON Patch. The patch is applied to a copy
+// of the document, so if any operation fails (including a failed test) the
+// document is left unchanged and the error names the operation. The error
+// wraps a *PathError; a failed test matches ErrTestFailed with errors.Is.
+func (j *JSONQuery) ApplyPatch(patch Patch) error {
+	doc := copyValue(j.blob)
+	for i, op := range patch {
+		var err error
+		if doc, err = applyOperation(doc, op); err != nil {
+			return fmt.Errorf("jsonq: patch operation %d (%s %s): %w", i, op.Op, op.Path, err)
+		}
+	}
+	j.blob = doc
+	return nil
+}
+
+// ApplyMergePatch applies an RFC 7386 JSON Merge Patch: members of patch
+// replace those of the document, null members delete them, nested objects are
+// merged recursively, and a patch that is not an object replaces the whole
+// document.
+func (j *JSONQuery) ApplyMergePatch(patch *JSONQuery) {
+	j.blob = mergePatch(j.blob, patch.blob)
+}
+
+func mergePatch(target, patch interface{}) interface{} {
+	pm, ok := patch.(map[string]interface{})
+	if !ok {
+		return copyValue(patch)
+	}
+	tm, ok := target.(map[string]interface{})
+	if !ok || tm == nil {
+		tm = map[string]interface{}{}
+	}
+	for k, v := range pm {
+		if v == nil {
+			delete(tm, k)
+		} else {
+			tm[k] = mergePatch(tm[k], v)
+		}
+	}
+	return tm
+}
+
+// applyOperation applies one operation to doc and returns the new document.
+// Errors are *PathError values whose path is the operation's pointer.
+func applyOperation(doc interface{}, op PatchOperation) (interface{}, error) {
+	tokens, err := parsePointer(op.Path)
+	if err != nil {
+		return nil, err
+	}
+	switch op.Op {
+	case "add", "replace":
+		value, err := normalizeValue(op.Value)
+		if err != nil {
+			return nil, err
+		}
+		if op.Op == "replace" {
+			if _, _, err := pointerGet(doc, tokens); err != nil {
+				return nil, err
+			}
+			if doc, err = pointerRemove(doc, tokens); err != nil {
+				return nil, err
+			}
+		}
+		return pointerAdd(doc, tokens, value)
+	case "remove":
+		return pointerRemove(doc, tokens)
+	case "move", "copy":
+		from, err := parsePointer(op.From)
+		if err != nil {
+			return nil, err
+		}
+		value, fromPath, err := pointerGet(doc, from)
+		if err != nil {
+			return nil, err
+		}
+		if op.Op == "copy" {
+			return pointerAdd(doc, tokens, copyValue(value))
+		}
+		if op.Path == op.From {
+			return doc, nil
+		}
+		if strings.HasPrefix(op.Path, op.From+"/") {
+			return nil, &PathError{Err: ErrInvalidPatch, Path: fromPath, Reason: "cannot move a value into itself"}
+		}
+		if doc, err = pointerRemove(doc, from); err != nil {
+			return nil, err
+		}
+		return pointerAdd(doc, tokens, value)
+	case "test":
+		value, p, err := pointerGet(doc, tokens)
+		if err != nil {
+			return nil, err
+		}
+		want, err := normalizeValue(op.Value)
+		if err != nil {
+			return nil, err
+		}
+		if !jsonEqual(value, want) {
+			return nil, &PathError{Err: ErrTestFailed, Path: p}
+		}
+		return doc, nil
+	}
+	return nil, &PathError{Err: ErrInvalidPatch, Path: pointerPath(doc, tokens), Reason: fmt.Sprintf("unknown operation %q", op.Op)}
+}
+
+// parsePointer splits an RFC 6901 JSON Pointer into unescaped tokens.
+func parsePointer(ptr string) ([]string, error) {
+	if ptr == "" {
+		return nil, nil
+	}
+	if ptr[0] != '/' {
+		return nil, &PathError{Err: ErrInvalidPatch, Reason: fmt.Sprintf("invalid JSON Pointer %q", ptr)}
+	}
+	tokens := strings.Split(ptr[1:], "/")
+	for i, t := range tokens {
+		tokens[i] = strings.NewReplacer("~1", "/", "~0", "~").Replace(t)
+	}
+	return tokens, nil
+}
+
+// jsonPointer formats a concrete path as an RFC 6901 JSON Pointer.
+func jsonPointer(p Path) string {
+	var b strings.Builder
+	for _, seg := range p {
+		b.WriteByte('/')
+		if seg.IsIndex {
+			b.WriteString(strconv.Itoa(seg.Index))
+		} else {
+			b.WriteString(strings.NewReplacer("~", "~0", "/", "~1").Replace(seg.Key))
+		}
+	}
+	return b.String()
+}
+
+// pointerSegment is the path segment token names in container: an index
+// segment for an array index (or "-", past the end), a key segment otherwise.
+func pointerSegment(container interface{}, token string) Segment {
+	if c, ok := container.([]interface{}); ok {
+		if token == "-" {
+			return Segment{Index: len(c), IsIndex: true}
+		}
+		if i, err := strconv.Atoi(token); err == nil && i >= 0 && strconv.Itoa(i) == token {
+			return Segment{Index: i, IsIndex: true}
+		}
+	}
+	return Segment{Key: token}
+}
+
+// pointerPath converts tokens to a Path, resolving them against doc as far
+// as they exist so that array indexes become index segments.
+func pointerPath(doc interface{}, tokens []string) Path {
+	p := make(Path, 0, len(tokens))
+	for _, t := range tokens {
+		seg := pointerSegment(doc, t)
+		p = append(p, seg)
+		switch c := doc.(type) {
+		case map[string]interface{}:
+			doc = c[t]
+		case []interface{}:
+			doc = nil
+			if seg.IsIndex && seg.Index < len(c) {
+				doc = c[seg.Index]
+			}
+		default:
+			doc = nil
+		}
+	}
+	return p
+}
+
+// pointerIndex parses an array index token; "-" (past the end) is only
+// valid when adding. The error has no path; callers set it with atPath.
+func pointerIndex(token string, length int, adding bool) (int, error) {
+	if token == "-" {
+		if adding {
+			return length, nil
+		}
+		return 0, outOfRange(length, length)
+	}
+	if token == "" || (len(token) > 1 && token[0] == '0') || strings.TrimLeft(token, "0123456789") != "" {
+		return 0, &PathError{Err: ErrInvalidPatch, Reason: "invalid array index"}
+	}
+	i, err := strconv.Atoi(token)
+	if err != nil {
+		return 0, &PathError{Err: ErrInvalidPatch, Reason: "invalid array index"}
+	}
+	max := length - 1
+	if adding {
+		max = length
+	}
+	if i > max {
+		return 0, outOfRange(i, length)
+	}
+	return i, nil
+}
+
+// pointerGet returns the value at tokens and its path.
+func pointerGet(doc interface{}, tokens []string) (interface{}, Path, error) {
+	var p Path
+	for _, t := range tokens {
+		seg := pointerSegment(doc, t)
+		switch c := doc.(type) {
+		case map[string]interface{}:
+			v, ok := c[t]
+			if !ok {
+				return nil, nil, atPath(notFound(""), p.Append(seg))
+			}
+			doc = v
+		case []interface{}:
+			index, err := pointerIndex(t, len(c), false)
+			if err != nil {
+				return nil, nil, atPath(err, p.Append(seg))
+			}
+			doc = c[index]
+		default:
+			return nil, nil, atPath(mismatch("object or array", doc), p.Append(seg))
+		}
+		p = p.Append(seg)
+	}
+	return doc, p, nil
+}
+
+// pointerUpdate calls fn with the container holding the last token and its
+// path, and stores the container fn returns (arrays may be reallocated).
+func pointerUpdate(doc interface{}, tokens []string, p Path, fn func(container interface{}, token string, p Path) (interface{}, error)) (interface{}, error) {
+	if len(tokens) == 1 {
+		return fn(doc, tokens[0], p)
+	}
+	seg := pointerSegment(doc, tokens[0])
+	switch c := doc.(type) {
+	case map[string]interface{}:
+		child, ok := c[tokens[0]]
+		if !ok {
+			return nil, atPath(notFound(""), p.Append(seg))
+		}
+		child, err := pointerUpdate(child, tokens[1:], p.Append(seg), fn)
+		if err != nil {
+			return nil, err
+		}
+		c[tokens[0]] = child
+		return c, nil
+	case []interface{}:
+		index, err := pointerIndex(tokens[0], len(c), false)
+		if err != nil {
+			return nil, atPath(err, p.Append(seg))
+		}
+		child, err := pointerUpdate(c[index], tokens[1:], p.Append(seg), fn)
+		if err != nil {
+			return nil, err
+		}
+		c[index] = child
+		return c, nil
+	}
+	return nil, atPath(mismatch("object or array", doc), p.Append(seg))
+}
+
+// pointerAdd adds value at tokens: sets an object member, inserts into an
+// array, or replaces the document for the empty pointer.
+func pointerAdd(doc interface{}, tokens []string, value interface{}) (interface{}, error) {
+	if len(tokens) == 0 {
+		return value, nil
+	}
+	return pointerUpdate(doc, tokens, nil, func(container interface{}, token string, p Path) (interface{}, error) {
+		switch c := container.(type) {
+		case map[string]interface{}:
+			c[token] = value
+			return c, nil
+		case []interface{}:
+			index, err := pointerIndex(token, len(c), true)
+			if err != nil {
+				return nil, atPath(err, p.Append(pointerSegment(c, token)))
+			}
+			c = append(c, nil)
+			copy(c[index+1:], c[index:])
+			c[index] = value
+			return c, nil
+		}
+		return nil, atPath(mismatch("object or array", container), p.Append(Segment{Key: token}))
+	})
+}
+
+// pointerRemove removes the value at tokens.
+func pointerRemove(doc interface{}, tokens []string) (interface{}, error) {
+	if len(tokens) == 0 {
+		return nil, nil
+	}
+	return pointerUpdate(doc, tokens, nil, func(container interface{}, token string, p Path) (interface{}, error) {
+		switch c := container.(type) {
+		case map[string]interface{}:
+			if _, ok := c[token]; !ok {
+				return nil, atPath(notFound(""), p.Append(Segment{Key: token}))
+			}
+			delete(c, token)
+			return c, nil
+		case []interface{}:
+			index, err := pointerIndex(token, len(c), false)
+			if err != nil {
+				return nil, atPath(err, p.Append(pointerSegment(c, token)))
+			}
+			return append(c[:index], c[index+1:]...), nil
+		}
+		return nil, atPath(mismatch("object or array", container), p.Append(Segment{Key: token}))
+	})
+}
+
+// jsonString encodes s as a json string.
+func jsonString(s string) string {
+	b, _ := json.Marshal(s)
+	return string(b)
+}
+
+// compactJSON encodes v for display.
+func compactJSON(v interface{}) string {
+	var b bytes.Buffer
+	enc := json.NewEncoder(&b)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(v); err != nil {
+		return fmt.Sprint(v)
+	}
+	return strings.TrimSuffix(b.String(), "\n")
+}
@@ -0,0 +1,137 @@
+package jsonq
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestDiff(t *testing.T) {
+	a := mustQuery(t, `{"server":{"port":8080,"host":"x"},"tags":["a","b","c"],"a/b":{"~k":1},"big":12345678901234567890}`)
+	b := mustQuery(t, `{"server":{"port":9090,"mode":"debug"},"tags":["a"],"a/b":{"~k":2},"big":12345678901234567891,"n":null}`)
+	p := Diff(a, b)
+	// pointers escape ~ and /, removals run from the end of an array, and
+	// numbers beyond float64 precision still differ
+	want := `[{"op":"replace","path":"/a~1b/~0k","value":2},{"op":"replace","path":"/big","value":12345678901234567891},` +
+		`{"op":"add","path":"/n","value":null},{"op":"remove","path":"/server/host"},{"op":"add","path":"/server/mode","value":"debug"},` +
+		`{"op":"replace","path":"/server/port","value":9090},{"op":"remove","path":"/tags/2"},{"op":"remove","path":"/tags/1"}]`
+	if p.String() != want {
+		t.Errorf("Diff =\n%s\nwant\n%s", p, want)
+	}
+	text := DiffText(a, b)
+	for _, line := range []string{`~ big: 12345678901234567890 -> 12345678901234567891`, `- server.host: "x"`, `+ n: null`, `- tags[2]: "c"`} {
+		if !strings.Contains(text, line+"\n") {
+			t.Errorf("DiffText is missing %q:\n%s", line, text)
+		}
+	}
+	parsed, err := ParsePatch([]byte(p.String()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := a.ApplyPatch(parsed); err != nil {
+		t.Fatal(err)
+	}
+	if d := DiffText(a, b); d != "" {
+		t.Errorf("documents differ after applying the diff:\n%s", d)
+	}
+	if len(Diff(b, b)) != 0 || DiffText(b, b) != "" {
+		t.Error("a document differs from itself")
+	}
+}
+
+func TestApplyPatch(t *testing.T) {
+	// the operations from RFC 6902 appendix A
+	c := mustQuery(t, `{"foo":["bar","baz"],"x":{"y":1}}`)
+	p, err := ParsePatch([]byte(`[{"op":"add","path":"/foo/1","value":"qux"},{"op":"add","path":"/foo/-","value":null},` +
+		`{"op":"move","from":"/x/y","path":"/z"},{"op":"copy","from":"/foo/0","path":"/x/c"},` +
+		`{"op":"test","path":"/foo/1","value":"qux"},{"op":"remove","path":"/foo/0"}]`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.ApplyPatch(p); err != nil {
+		t.Fatal(err)
+	}
+	if b, _ := c.MarshalJSON(); string(b) != `{"foo":["qux","baz",null],"x":{"c":"bar"},"z":1}` {
+		t.Fatalf("patched document = %s", b)
+	}
+}
+
+// TestReplaceArrayIndex covers replace at both ends of an array: the last
+// index is replaced in place, and the index past the end is an error.
+func TestReplaceArrayIndex(t *testing.T) {
+	j := mustQuery(t, `{"list":[1,2,3]}`)
+	if err := j.ApplyPatch(Patch{{Op: "replace", Path: "/list/2", Value: 30}, {Op: "replace", Path: "/list/0", Value: 10}}); err != nil {
+		t.Fatal(err)
+	}
+	if b, _ := j.MarshalJSON(); string(b) != `{"list":[10,2,30]}` {
+		t.Errorf("document = %s", b)
+	}
+	for _, tt := range []struct {
+		path string
+		err  error
+	}{
+		{"/list/3", ErrIndexOutOfRange},
+		{"/list/-", ErrIndexOutOfRange},
+		{"/list/01", ErrInvalidPatch},
+	} {
+		if err := j.ApplyPatch(Patch{{Op: "replace", Path: tt.path, Value: 0}}); !errors.Is(err, tt.err) {
+			t.Errorf("replace %s = %v, want %v", tt.path, err, tt.err)
+		}
+	}
+	one := mustQuery(t, `[1]`)
+	if err := one.ApplyPatch(Patch{{Op: "replace", Path: "/0", Value: "x"}}); err != nil {
+		t.Fatal(err)
+	}
+	if b, _ := one.MarshalJSON(); string(b) != `["x"]` {
+		t.Errorf("single element array = %s", b)
+	}
+}
+
+func TestApplyPatchAtomic(t *testing.T) {
+	c := mustQuery(t, `{"foo":["qux","baz"],"x":{"c":"bar"},"z":1}`)
+	before, _ := c.MarshalJSON()
+	tests := []struct {
+		patch Patch
+		err   error
+		msg   string
+	}{
+		{Patch{{Op: "remove", Path: "/z"}, {Op: "test", Path: "/foo/0", Value: "nope"}}, ErrTestFailed, "jsonq: patch test failed at foo[0]"},
+		{Patch{{Op: "add", Path: "/foo/9", Value: 1}}, ErrIndexOutOfRange, "jsonq: index out of range at foo[9]: index 9 with length 2"},
+		{Patch{{Op: "move", From: "/x", Path: "/x/c/d"}}, ErrInvalidPatch, "jsonq: invalid patch operation at x (cannot move a value into itself)"},
+		{Patch{{Op: "remove", Path: "/missing"}}, ErrNotFound, `jsonq: not found at missing: no field "missing"`},
+		{Patch{{Op: "add", Path: "/z/a", Value: 1}}, ErrTypeMismatch, "jsonq: type mismatch at z.a: expected object or array, got number"},
+		{Patch{{Op: "bogus", Path: "/foo/1"}}, ErrInvalidPatch, `jsonq: invalid patch operation at foo[1] (unknown operation "bogus")`},
+		{Patch{{Op: "remove", Path: "foo"}}, ErrInvalidPatch, `jsonq: invalid patch operation at document root (invalid JSON Pointer "foo")`},
+	}
+	for _, tt := range tests {
+		err := c.ApplyPatch(tt.patch)
+		var pe *PathError
+		if !errors.Is(err, tt.err) || !errors.As(err, &pe) || pe.Error() != tt.msg {
+			t.Errorf("ApplyPatch(%s) = %v, want %q", tt.patch, err, tt.msg)
+		}
+		// a failed patch leaves the document unchanged
+		if now, _ := c.MarshalJSON(); string(now) != string(before) {
+			t.Errorf("ApplyPatch(%s) is not atomic: %s", tt.patch, now)
+		}
+	}
+	for _, bad := range []string{`[{"op":"add","path":"/a"}]`, `[{"op":"bogus","path":"/a"}]`, `[{"op":"move","path":"/a"}]`, `{}`} {
+		if _, err := ParsePatch([]byte(bad)); err == nil {
+			t.Errorf("ParsePatch(%s) accepted", bad)
+		}
+	}
+}
+
+func TestApplyMergePatch(t *testing.T) {
+	// the example from RFC 7386
+	m := mustQuery(t, `{"title":"Goodbye!","author":{"givenName":"John","familyName":"Doe"},"tags":["example","sample"],"content":"This will be unchanged"}`)
+	m.ApplyMergePatch(mustQuery(t, `{"title":"Hello!","phoneNumber":"+01-123-456-7890","author":{"familyName":null},"tags":["example"]}`))
+	want := `{"author":{"givenName":"John"},"content":"This will be unchanged","phoneNumber":"+01-123-456-7890","tags":["example"],"title":"Hello!"}`
+	if b, _ := m.MarshalJSON(); string(b) != want {
+		t.Errorf("merged = %s\nwant     %s", b, want)
+	}
+	// a patch that is not an object replaces the document
+	m.ApplyMergePatch(mustQuery(t, `[1]`))
+	if b, _ := m.MarshalJSON(); string(b) != `[1]` {
+		t.Errorf("array merge patch = %s", b)
+	}
+}
@@ -0,0 +1,291 @@
+package jsonq
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Segment is a single step of a Path: either an object key or an array index.
+type Segment struct {
+	Key     string // object key; for index segments given in the variadic form, the raw text
+	Index   int    // array index, negative values count from the end (-1 is the last element)
+	IsIndex bool   // true if the segment addresses an array element
+}
+
+// Path is a parsed path expression such as `data.items[0].name`.
+type Path []Segment
+
+// maxCachedPaths bounds the parsed path caches so that expressions built
+// from untrusted input cannot grow them without limit.
+const maxCachedPaths = 4096
+
+// parseCache is a bounded cache of parsed expressions keyed by their source.
+type parseCache struct {
+	m    sync.Map
+	size int32
+	mu   sync.Mutex
+}
+
+func (c *parseCache) load(expr string) (interface{}, bool) {
+	return c.m.Load(expr)
+}
+
+func (c *parseCache) store(expr string, v interface{}) {
+	c.mu.Lock()
+	if c.size < maxCachedPaths {
+		if _, loaded := c.m.LoadOrStore(expr, v); !loaded {
+			c.size++
+		}
+	}
+	c.mu.Unlock()
+}
+
+var pathCache parseCache
+
+// ParsePath parses a path expression. The syntax is:
+//
+//	data.items[0].name    dotted keys and bracketed array indexes
+//	items[-1]             negative indexes count from the end
+//	data.2020             keys after a dot are always object keys, even if numeric
+//	data["a.b"]['x y']    quoted keys may contain any character; \" \' and \\ escape
+//	$.data.items          an optional leading $ refers to the document root
+//
+// Parsed paths are cached, so calling ParsePath (or a getter with a path
+// expression) repeatedly with the same string is cheap.
+func ParsePath(expr string) (Path, error) {
+	if p, ok := pathCache.load(expr); ok {
+		return p.(Path), nil
+	}
+	p, err := parsePath(expr)
+	if err != nil {
+		return nil, err
+	}
+	pathCache.store(expr, p)
+	return p, nil
+}
+
+// MustParsePath is like ParsePath but panics if the expression is invalid.
+func MustParsePath(expr string) Path {
+	p, err := ParsePath(expr)
+	if err != nil {
+		panic(err)
+	}
+	return p
+}
+
+// parsePath parses expr without consulting the cache.
+func parsePath(expr string) (Path, error) {
+	var p Path
+	i := 0
+	if strings.HasPrefix(expr, "$") {
+		i = 1
+		if i < len(expr) && expr[i] == '.' {
+			i++
+			if i == len(expr) {
+				return nil, fmt.Errorf("jsonq: invalid path %q: empty key at offset %d", expr, i)
+			}
+		}
+	}
+	for i < len(expr) {
+		switch expr[i] {
+		case '[':
+			seg, next, err := parseBracket(expr, i)
+			if err != nil {
+				return nil, err
+			}
+			p = append(p, seg)
+			i = next
+		case '.':
+			if len(p) == 0 {
+				return nil, fmt.Errorf("jsonq: invalid path %q: unexpected '.' at offset %d", expr, i)
+			}
+			i++
+			if i == len(expr) || expr[i] == '.' || expr[i] == '[' {
+				return nil, fmt.Errorf("jsonq: invalid path %q: empty key at offset %d", expr, i)
+			}
+		case ']':
+			return nil, fmt.Errorf("jsonq: invalid path %q: unexpected ']' at offset %d", expr, i)
+		default:
+			if len(p) > 0 && expr[i-1] != '.' {
+				return nil, fmt.Errorf("jsonq: invalid path %q: expected '.' or '[' at offset %d", expr, i)
+			}
+			start := i
+			for i < len(expr) && expr[i] != '.' && expr[i] != '[' && expr[i] != ']' {
+				i++
+			}
+			p = append(p, Segment{Key: expr[start:i]})
+		}
+	}
+	return p, nil
+}
+
+// parseBracket parses a bracketed index or quoted key starting at expr[i] == '['
+// and returns the segment and the offset just past the closing bracket.
+func parseBracket(expr string, i int) (Segment, int, error) {
+	start := i
+	i++
+	if i < len(expr) && (expr[i] == '"' || expr[i] == '\'') {
+		key, next, err := parseQuoted(expr, i)
+		if err != nil {
+			return Segment{}, 0, err
+		}
+		if next >= len(expr) || expr[next] != ']' {
+			return Segment{}, 0, fmt.Errorf("jsonq: invalid path %q: missing ']' after quoted key at offset %d", expr, start)
+		}
+		return Segment{Key: key}, next + 1, nil
+	}
+	end := strings.IndexByte(expr[i:], ']')
+	if end < 0 {
+		return Segment{}, 0, fmt.Errorf("jsonq: invalid path %q: unclosed '[' at offset %d", expr, start)
+	}
+	text := strings.TrimSpace(expr[i : i+end])
+	index, err := strconv.Atoi(text)
+	if err != nil {
+		return Segment{}, 0, fmt.Errorf("jsonq: invalid path %q: invalid array index %q at offset %d", expr, text, start)
+	}
+	return Segment{Index: index, IsIndex: true}, i + end + 1, nil
+}
+
+// parseQuoted parses a quoted string starting at expr[i] (the opening quote)
+// and returns the unescaped value and the offset just past the closing quote.
+func parseQuoted(expr string, i int) (string, int, error) {
+	quote := expr[i]
+	var b strings.Builder
+	for j := i + 1; j < len(expr); j++ {
+		switch c := expr[j]; c {
+		case '\\':
+			if j+1 >= len(expr) {
+				return "", 0, fmt.Errorf("jsonq: invalid path %q: unterminated escape at offset %d", expr, j)
+			}
+			j++
+			b.WriteByte(expr[j])
+		case quote:
+			return b.String(), j + 1, nil
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return "", 0, fmt.Errorf("jsonq: invalid path %q: unterminated quoted key at offset %d", expr, i)
+}
+
+// String formats the path in the syntax accepted by ParsePath, quoting keys
+// that would otherwise be ambiguous.
+func (p Path) String() string {
+	var b strings.Builder
+	for i, seg := range p {
+		switch {
+		case seg.IsIndex:
+			b.WriteString("[" + strconv.Itoa(seg.Index) + "]")
+		case plainKey(seg.Key):
+			if i > 0 {
+				b.WriteByte('.')
+			}
+			b.WriteString(seg.Key)
+		default:
+			b.WriteString(`["` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(seg.Key) + `"]`)
+		}
+	}
+	return b.String()
+}
+
+// plainKey reports whether key can be written without quoting.
+func plainKey(key string) bool {
+	if key == "" || key[0] == '$' {
+		return false
+	}
+	return !strings.ContainsAny(key, `.[]'"\`)
+}
+
+// Append returns a new path with the given segments appended.
+func (p Path) Append(segs ...Segment) Path {
+	out := make(Path, 0, len(p)+len(segs))
+	return append(append(out, p...), segs...)
+}
+
+// isPathExpr reports whether a single getter argument should be parsed as a
+// path expression rather than used as a literal key.
+func isPathExpr(s string) bool {
+	return strings.ContainsAny(s, ".[") || strings.HasPrefix(s, "$")
+}
+
+// toPath converts getter arguments into a Path. A single argument containing
+// path syntax is parsed as a path expression, unless the document root has a
+// key with exactly that name (so existing lookups of keys like "a.b" keep
+// working). Otherwise every argument is one segment, as in earlier versions:
+// numeric arguments address array elements, or object keys when the value at
+// that point is an object.
+func toPath(blob interface{}, s []string) Path {
+	if len(s) == 1 && isPathExpr(s[0]) {
+		root, isObject := blob.(map[string]interface{})
+		if _, literal := root[s[0]]; !isObject || !literal {
+			if p, err := ParsePath(s[0]); err == nil {
+				return p
+			}
+		}
+	}
+	p := make(Path, len(s))
+	for i, q := range s {
+		p[i].Key = q
+		if index, err := strconv.Atoi(q); err == nil {
+			p[i].Index, p[i].IsIndex = index, true
+		}
+	}
+	return p
+}
+
+// get resolves the path against a decoded json value. A null value is
+// reported as ErrNotFound.
+func (p Path) get(blob interface{}) (interface{}, error) {
+	val, err := p.resolve(blob)
+	if err != nil {
+		return nil, err
+	}
+	if val == nil && len(p) > 0 {
+		return nil, atPath(notFound("value is null"), p)
+	}
+	return val, nil
+}
+
+// resolve is like get but allows null values.
+func (p Path) resolve(blob interface{}) (interface{}, error) {
+	val := blob
+	for i, seg := range p {
+		next, err := step(val, seg)
+		if err != nil {
+			return nil, atPath(err, p[:i+1])
+		}
+		val = next
+	}
+	return val, nil
+}
+
+// step resolves a single segment against a decoded json value.
+func step(blob interface{}, seg Segment) (interface{}, error) {
+	if seg.IsIndex {
+		if arr, ok := blob.([]interface{}); ok {
+			index := seg.Index
+			if index < 0 {
+				index += len(arr)
+			}
+			if index < 0 || index >= len(arr) {
+				return nil, outOfRange(seg.Index, len(arr))
+			}
+			return arr[index], nil
+		}
+		// a numeric variadic argument may also be an object key such as "2020"
+		if _, ok := blob.(map[string]interface{}); !ok || seg.Key == "" {
+			return nil, mismatch("array", blob)
+		}
+	}
+	obj, ok := blob.(map[string]interface{})
+	if !ok {
+		return nil, mismatch("object", blob)
+	}
+	val, ok := obj[seg.Key]
+	if !ok {
+		return nil, notFound("")
+	}
+	return val, nil
+}
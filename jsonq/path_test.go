@@ -0,0 +1,102 @@
+package jsonq
+
+import (
+	"testing"
+)
+
+// mustQuery decodes doc and wraps it in a JSONQuery.
+func mustQuery(t *testing.T, doc string) *JSONQuery {
+	t.Helper()
+	j, err := FromString(doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return j
+}
+
+const pathDoc = `{"data":{"items":[{"name":"a"},{"name":"b"}],"2020":{"x":1},"a.b":{"c":"dot"},"l":[[1,2],[3]]},"a.b":"literal","k":null}`
+
+func TestPathLookup(t *testing.T) {
+	j := mustQuery(t, pathDoc)
+	tests := []struct {
+		path []string
+		want string
+	}{
+		{[]string{"data.items[0].name"}, "a"},
+		{[]string{"data.items[-1].name"}, "b"},
+		{[]string{"$.data.items[1].name"}, "b"},
+		{[]string{`data["a.b"].c`}, "dot"},
+		{[]string{`data['a.b']['c']`}, "dot"},
+		{[]string{"a.b"}, "literal"}, // a literal root key wins over path syntax
+		{[]string{"data", "items", "1", "name"}, "b"},
+		{[]string{"data", "a.b", "c"}, "dot"}, // variadic segments are never parsed
+	}
+	for _, tt := range tests {
+		got, err := j.String(tt.path...)
+		if err != nil || got != tt.want {
+			t.Errorf("String(%q) = %q, %v; want %q", tt.path, got, err, tt.want)
+		}
+	}
+	if _, err := j.String("data.items[5].name"); err == nil {
+		t.Error("index out of range accepted")
+	}
+	if _, err := j.String("data.items[-3].name"); err == nil {
+		t.Error("negative index before the first element accepted")
+	}
+	if _, err := j.Interface("k"); err == nil {
+		t.Error("null value accepted")
+	}
+}
+
+func TestNumericKeys(t *testing.T) {
+	j := mustQuery(t, pathDoc)
+	// "2020" after a dot is a key, and a numeric variadic segment falls back
+	// to a key when the value is an object
+	for _, path := range [][]string{{"data.2020.x"}, {`data["2020"].x`}, {"data", "2020", "x"}} {
+		if v, err := j.Int(path...); err != nil || v != 1 {
+			t.Errorf("Int(%q) = %d, %v", path, v, err)
+		}
+	}
+	if v, err := j.Int("data.l[0][1]"); err != nil || v != 2 {
+		t.Errorf("nested index = %d, %v", v, err)
+	}
+	if _, err := j.Int("data[0]"); err == nil {
+		t.Error("bracketed index on an object accepted")
+	}
+}
+
+func TestParsePath(t *testing.T) {
+	for _, bad := range []string{"a..b", ".a", "a[x]", "a[", `a["x`, "a]", "a.", "$.", `a["x"`} {
+		if _, err := ParsePath(bad); err == nil {
+			t.Errorf("ParsePath(%q) accepted", bad)
+		}
+	}
+	tests := map[string]string{
+		`data["a.b"].items[0]`: `data["a.b"].items[0]`,
+		`$.a['b c'][-1]`:       `a.b c[-1]`,
+		`['$x']['']`:           `["$x"][""]`,
+		`a["q\"t"]`:            `a["q\"t"]`,
+		"x":                    "x",
+	}
+	for expr, want := range tests {
+		p := MustParsePath(expr)
+		if got := p.String(); got != want {
+			t.Errorf("MustParsePath(%q).String() = %q, want %q", expr, got, want)
+		}
+		// String output parses back to the same path
+		if again := MustParsePath(p.String()); again.String() != want || len(again) != len(p) {
+			t.Errorf("%q does not round-trip: %q", want, again)
+		}
+	}
+	// a cached path is returned again
+	p1, p2 := MustParsePath("cache.me[1]"), MustParsePath("cache.me[1]")
+	if &p1[0] != &p2[0] {
+		t.Error("ParsePath did not reuse the cached path")
+	}
+	defer func() {
+		if recover() == nil {
+			t.Error("MustParsePath did not panic")
+		}
+	}()
+	MustParsePath("a[")
+}
@@ -0,0 +1,436 @@
+package jsonq
+
+import (
+	"fmt"
+	"math/big"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+/*
+Schema is a compiled JSON Schema. The supported subset of draft 2020-12 is:
+
+	type                  "object", "array", "string", "number", "integer",
+	                      "boolean", "null", or an array of these
+	enum, const           allowed values (numbers compare exactly: 1 == 1.0)
+	required, properties  object members
+	additionalProperties  false rejects members not listed in properties
+	                      (useful to catch typos in config), or a schema
+	minProperties, maxProperties
+	minimum, maximum, exclusiveMinimum, exclusiveMaximum, multipleOf
+	minLength, maxLength  in characters
+	pattern               a Go regexp, matched anywhere in the string
+	items                 schema for every array element
+	minItems, maxItems, uniqueItems
+	true, false           boolean schemas accept or reject everything
+
+Other keywords (title, description, default, $schema, ...) are ignored.
+*/
+type Schema struct {
+	reject        bool // the boolean schema false
+	types         []string
+	enum          []interface{}
+	hasConst      bool
+	constValue    interface{}
+	required      []string
+	properties    map[string]*Schema
+	additional    *Schema
+	minProperties *int
+	maxProperties *int
+	minimum       *big.Rat
+	maximum       *big.Rat
+	exclusiveMin  *big.Rat
+	exclusiveMax  *big.Rat
+	multipleOf    *big.Rat
+	minLength     *int
+	maxLength     *int
+	pattern       *regexp.Regexp
+	items         *Schema
+	minItems      *int
+	maxItems      *int
+	uniqueItems   bool
+}
+
+// SchemaError is a single schema violation at a location in the document.
+type SchemaError struct {
+	Path    Path   // location of the offending value
+	Keyword string // the schema keyword that failed, e.g. "required"
+	Message string // description without the offending value
+}
+
+func (e *SchemaError) Error() string {
+	return pathName(e.Path) + ": " + e.Message
+}
+
+// SchemaErrors lists every violation found by Validate, with object members
+// visited in key order.
+type SchemaErrors []*SchemaError
+
+func (e SchemaErrors) Error() string {
+	msgs := make([]string, len(e))
+	for i, err := range e {
+		msgs[i] = err.Error()
+	}
+	return "jsonq: schema validation failed: " + strings.Join(msgs, "; ")
+}
+
+// ParseSchema parses and compiles a JSON Schema document.
+func ParseSchema(data []byte) (*Schema, error) {
+	q, err := FromBytes(data)
+	if err != nil {
+		return nil, err
+	}
+	return CompileSchema(q)
+}
+
+// CompileSchema compiles a JSON Schema held in a JSONQuery.
+func CompileSchema(schema *JSONQuery) (*Schema, error) {
+	return compileSchema(schema.blob, "#")
+}
+
+// Validate checks the document against schema and returns nil or a
+// SchemaErrors with all violations:
+//
+//	cfg, err := gfile.Readconfigfile("config.json")
+//	...
+//	if err := cfg.Validate(schema); err != nil {
+//		log.Fatal(err)
+//	}
+func (j *JSONQuery) Validate(schema *Schema) error {
+	var errs SchemaErrors
+	schema.validate(j.blob, nil, &errs)
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// schemaTypes are the valid values of "type".
+var schemaTypes = map[string]bool{"object": true, "array": true, "string": true, "number": true, "integer": true, "boolean": true, "null": true}
+
+// compileSchema compiles the decoded schema v; at is its location for errors.
+func compileSchema(v interface{}, at string) (*Schema, error) {
+	if b, ok := v.(bool); ok {
+		return &Schema{reject: !b}, nil
+	}
+	m, ok := v.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("jsonq: invalid schema at %s: expected object or boolean", at)
+	}
+	s := &Schema{}
+	var err error
+	invalid := func(keyword, want string) error {
+		return fmt.Errorf("jsonq: invalid schema at %s/%s: expected %s", at, keyword, want)
+	}
+	for keyword, kv := range m {
+		switch keyword {
+		case "type":
+			switch t := kv.(type) {
+			case string:
+				s.types = []string{t}
+			case []interface{}:
+				for _, e := range t {
+					name, ok := e.(string)
+					if !ok {
+						return nil, invalid(keyword, "string or array of strings")
+					}
+					s.types = append(s.types, name)
+				}
+			default:
+				return nil, invalid(keyword, "string or array of strings")
+			}
+			for _, t := range s.types {
+				if !schemaTypes[t] {
+					return nil, fmt.Errorf("jsonq: invalid schema at %s/type: unknown type %q", at, t)
+				}
+			}
+		case "enum":
+			arr, ok := kv.([]interface{})
+			if !ok {
+				return nil, invalid(keyword, "array")
+			}
+			s.enum = arr
+		case "const":
+			s.hasConst, s.constValue = true, kv
+		case "required":
+			arr, ok := kv.([]interface{})
+			if !ok {
+				return nil, invalid(keyword, "array of strings")
+			}
+			for _, e := range arr {
+				name, ok := e.(string)
+				if !ok {
+					return nil, invalid(keyword, "array of strings")
+				}
+				s.required = append(s.required, name)
+			}
+		case "properties":
+			props, ok := kv.(map[string]interface{})
+			if !ok {
+				return nil, invalid(keyword, "object")
+			}
+			s.properties = make(map[string]*Schema, len(props))
+			for name, ps := range props {
+				if s.properties[name], err = compileSchema(ps, at+"/properties/"+name); err != nil {
+					return nil, err
+				}
+			}
+		case "additionalProperties":
+			if s.additional, err = compileSchema(kv, at+"/"+keyword); err != nil {
+				return nil, err
+			}
+		case "items":
+			if s.items, err = compileSchema(kv, at+"/"+keyword); err != nil {
+				return nil, err
+			}
+		case "minimum", "maximum", "exclusiveMinimum", "exclusiveMaximum", "multipleOf":
+			r, ok := filterNumber(kv)
+			if !ok {
+				return nil, invalid(keyword, "number")
+			}
+			switch keyword {
+			case "minimum":
+				s.minimum = r
+			case "maximum":
+				s.maximum = r
+			case "exclusiveMinimum":
+				s.exclusiveMin = r
+			case "exclusiveMaximum":
+				s.exclusiveMax = r
+			case "multipleOf":
+				if r.Sign() <= 0 {
+					return nil, invalid(keyword, "number greater than 0")
+				}
+				s.multipleOf = r
+			}
+		case "minLength", "maxLength", "minItems", "maxItems", "minProperties", "maxProperties":
+			i, err := exactInt64(kv, "integer")
+			if err != nil || i < 0 {
+				return nil, invalid(keyword, "non-negative integer")
+			}
+			n := int(i)
+			switch keyword {
+			case "minLength":
+				s.minLength = &n
+			case "maxLength":
+				s.maxLength = &n
+			case "minItems":
+				s.minItems = &n
+			case "maxItems":
+				s.maxItems = &n
+			case "minProperties":
+				s.minProperties = &n
+			case "maxProperties":
+				s.maxProperties = &n
+			}
+		case "pattern":
+			str, ok := kv.(string)
+			if !ok {
+				return nil, invalid(keyword, "string")
+			}
+			if s.pattern, err = regexp.Compile(str); err != nil {
+				return nil, fmt.Errorf("jsonq: invalid schema at %s/pattern: %s", at, err)
+			}
+		case "uniqueItems":
+			b, ok := kv.(bool)
+			if !ok {
+				return nil, invalid(keyword, "boolean")
+			}
+			s.uniqueItems = b
+		}
+	}
+	return s, nil
+}
+
+// validate appends the violations of val (at path p) to errs.
+func (s *Schema) validate(val interface{}, p Path, errs *SchemaErrors) {
+	fail := func(keyword, format string, args ...interface{}) {
+		*errs = append(*errs, &SchemaError{Path: p, Keyword: keyword, Message: fmt.Sprintf(format, args...)})
+	}
+	if s.reject {
+		fail("false", "value is not allowed")
+		return
+	}
+	if len(s.types) > 0 && !s.hasType(val) {
+		fail("type", "expected %s, got %s", strings.Join(s.types, " or "), schemaTypeName(val))
+		return
+	}
+	if s.enum != nil {
+		matched := false
+		for _, e := range s.enum {
+			if jsonEqual(val, e) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			fail("enum", "must be one of %s", schemaValueList(s.enum))
+		}
+	}
+	if s.hasConst && !jsonEqual(val, s.constValue) {
+		fail("const", "must be %s", schemaValueList([]interface{}{s.constValue}))
+	}
+	switch v := val.(type) {
+	case map[string]interface{}:
+		s.validateObject(v, p, errs, fail)
+	case []interface{}:
+		if s.minItems != nil && len(v) < *s.minItems {
+			fail("minItems", "must have at least %d items", *s.minItems)
+		}
+		if s.maxItems != nil && len(v) > *s.maxItems {
+			fail("maxItems", "must have at most %d items", *s.maxItems)
+		}
+		if s.uniqueItems {
+			for i := 1; i < len(v); i++ {
+				for k := 0; k < i; k++ {
+					if jsonEqual(v[i], v[k]) {
+						fail("uniqueItems", "items %d and %d are equal", k, i)
+					}
+				}
+			}
+		}
+		if s.items != nil {
+			for i, e := range v {
+				s.items.validate(e, p.Append(Segment{Index: i, IsIndex: true}), errs)
+			}
+		}
+	case string:
+		n := utf8.RuneCountInString(v)
+		if s.minLength != nil && n < *s.minLength {
+			fail("minLength", "must be at least %d characters", *s.minLength)
+		}
+		if s.maxLength != nil && n > *s.maxLength {
+			fail("maxLength", "must be at most %d characters", *s.maxLength)
+		}
+		if s.pattern != nil && !s.pattern.MatchString(v) {
+			fail("pattern", "must match pattern %s", s.pattern)
+		}
+	default:
+		r, ok := filterNumber(val)
+		if !ok {
+			return
+		}
+		if s.minimum != nil && r.Cmp(s.minimum) < 0 {
+			fail("minimum", "must be >= %s", s.minimum.RatString())
+		}
+		if s.maximum != nil && r.Cmp(s.maximum) > 0 {
+			fail("maximum", "must be <= %s", s.maximum.RatString())
+		}
+		if s.exclusiveMin != nil && r.Cmp(s.exclusiveMin) <= 0 {
+			fail("exclusiveMinimum", "must be > %s", s.exclusiveMin.RatString())
+		}
+		if s.exclusiveMax != nil && r.Cmp(s.exclusiveMax) >= 0 {
+			fail("exclusiveMaximum", "must be < %s", s.exclusiveMax.RatString())
+		}
+		if s.multipleOf != nil && !new(big.Rat).Quo(r, s.multipleOf).IsInt() {
+			fail("multipleOf", "must be a multiple of %s", s.multipleOf.RatString())
+		}
+	}
+}
+
+func (s *Schema) validateObject(v map[string]interface{}, p Path, errs *SchemaErrors, fail func(string, string, ...interface{})) {
+	if s.minProperties != nil && len(v) < *s.minProperties {
+		fail("minProperties", "must have at least %d properties", *s.minProperties)
+	}
+	if s.maxProperties != nil && len(v) > *s.maxProperties {
+		fail("maxProperties", "must have at most %d properties", *s.maxProperties)
+	}
+	for _, name := range s.required {
+		if _, ok := v[name]; !ok {
+			fail("required", "missing required property %q", name)
+		}
+	}
+	keys := make([]string, 0, len(v))
+	for k := range v {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		kp := p.Append(Segment{Key: k})
+		if ps, ok := s.properties[k]; ok {
+			ps.validate(v[k], kp, errs)
+		} else if s.additional != nil {
+			if s.additional.reject {
+				*errs = append(*errs, &SchemaError{Path: kp, Keyword: "additionalProperties", Message: "unknown property"})
+			} else {
+				s.additional.validate(v[k], kp, errs)
+			}
+		}
+	}
+}
+
+// hasType reports whether val matches one of the schema types.
+func (s *Schema) hasType(val interface{}) bool {
+	actual := schemaTypeName(val)
+	for _, t := range s.types {
+		if t == actual || t == "number" && actual == "integer" {
+			return true
+		}
+	}
+	return false
+}
+
+// schemaTypeName is the JSON Schema type of val; whole numbers are "integer".
+func schemaTypeName(val interface{}) string {
+	if r, ok := filterNumber(val); ok {
+		if r.IsInt() {
+			return "integer"
+		}
+		return "number"
+	}
+	if _, ok := val.(bool); ok {
+		return "boolean"
+	}
+	return jsonTypeName(val)
+}
+
+// jsonEqual compares decoded json values, numbers by exact value.
+func jsonEqual(a, b interface{}) bool {
+	if x, ok := filterNumber(a); ok {
+		y, ok := filterNumber(b)
+		return ok && x.Cmp(y) == 0
+	}
+	switch x := a.(type) {
+	case map[string]interface{}:
+		y, ok := b.(map[string]interface{})
+		if !ok || len(x) != len(y) {
+			return false
+		}
+		for k, xv := range x {
+			yv, ok := y[k]
+			if !ok || !jsonEqual(xv, yv) {
+				return false
+			}
+		}
+		return true
+	case []interface{}:
+		y, ok := b.([]interface{})
+		if !ok || len(x) != len(y) {
+			return false
+		}
+		for i := range x {
+			if !jsonEqual(x[i], y[i]) {
+				return false
+			}
+		}
+		return true
+	}
+	return a == b
+}
+
+// schemaValueList formats schema values (never document values) for messages.
+func schemaValueList(values []interface{}) string {
+	parts := make([]string, len(values))
+	for i, v := range values {
+		if s, ok := v.(string); ok {
+			parts[i] = fmt.Sprintf("%q", s)
+		} else if v == nil {
+			parts[i] = "null"
+		} else {
+			parts[i] = fmt.Sprint(v)
+		}
+	}
+	return strings.Join(parts, ", ")
+}
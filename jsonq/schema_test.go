@@ -0,0 +1,116 @@
+package jsonq
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+const schemaDoc = `{
+ "type":"object","required":["server","appid"],"additionalProperties":false,
+ "properties":{
+  "appid":{"type":"string","pattern":"^wx[0-9a-f]{16}$"},
+  "server":{"type":"object","required":["port"],"properties":{
+    "port":{"type":"integer","minimum":1,"maximum":65535},
+    "mode":{"enum":["debug","release"]},
+    "timeout":{"type":["string","number"]}}},
+  "tags":{"type":"array","items":{"type":"string","minLength":2},"maxItems":2,"uniqueItems":true},
+  "ratio":{"type":"number","exclusiveMaximum":1}
+ }}`
+
+// violations lists the keyword and path of every schema error in err.
+func violations(t *testing.T, err error) string {
+	t.Helper()
+	var errs SchemaErrors
+	if !errors.As(err, &errs) {
+		t.Fatalf("Validate = %v, want SchemaErrors", err)
+	}
+	var out []string
+	for _, e := range errs {
+		out = append(out, e.Keyword+" "+pathName(e.Path))
+	}
+	return strings.Join(out, ",")
+}
+
+func TestValidateReportsAllViolations(t *testing.T) {
+	s, err := ParseSchema([]byte(schemaDoc))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 8080.0 is an integer
+	ok := mustQuery(t, `{"appid":"wx0123456789abcdef","server":{"port":8080.0,"mode":"debug","timeout":"1s"},"tags":["ab"],"ratio":0.5}`)
+	if err := ok.Validate(s); err != nil {
+		t.Errorf("valid document rejected: %v", err)
+	}
+	bad := mustQuery(t, `{"appid":"secretvalue","server":{"port":70000,"mode":"prod","timeout":true},"tags":["a","bb","bb"],"ratio":1,"prot":1}`)
+	err = bad.Validate(s)
+	want := "pattern appid,additionalProperties prot,exclusiveMaximum ratio,enum server.mode,maximum server.port," +
+		"type server.timeout,maxItems tags,uniqueItems tags,minLength tags[0]"
+	if got := violations(t, err); got != want {
+		t.Errorf("violations:\ngot  %s\nwant %s", got, want)
+	}
+	if strings.Contains(err.Error(), "secretvalue") {
+		t.Errorf("message leaks a document value: %s", err)
+	}
+	missing := mustQuery(t, `{"server":{"port":"80"}}`)
+	want = `jsonq: schema validation failed: document root: missing required property "appid"; server.port: expected integer, got string`
+	if err := missing.Validate(s); err == nil || err.Error() != want {
+		t.Errorf("Validate = %v\nwant %s", err, want)
+	}
+}
+
+func TestValidateKeywords(t *testing.T) {
+	tests := []struct {
+		schema, doc string
+		want        string // violations, empty if valid
+	}{
+		{`{"maxLength":2}`, `"汉字"`, ""}, // lengths count characters, not bytes
+		{`{"minLength":3}`, `"汉字"`, "minLength document root"},
+		{`{"multipleOf":0.1}`, `0.3`, ""}, // exact decimal arithmetic
+		{`{"multipleOf":2}`, `3`, "multipleOf document root"},
+		{`{"const":{"a":[1]}}`, `{"a":[1.0]}`, ""},
+		{`{"const":1}`, `"1"`, "const document root"},
+		{`{"type":"integer"}`, `1e2`, ""},
+		{`{"type":"null"}`, `0`, "type document root"},
+		{`{"items":false}`, `[]`, ""},
+		{`{"items":false}`, `[1]`, "false [0]"},
+		{`{"additionalProperties":{"type":"number"},"properties":{"a":{}}}`, `{"a":"x","b":"y"}`, "type b"},
+		{`{"minProperties":2}`, `{"a":1}`, "minProperties document root"},
+		{`{"minimum":1,"exclusiveMinimum":1}`, `1`, "exclusiveMinimum document root"},
+		{`true`, `{"anything":[]}`, ""},
+	}
+	for _, tt := range tests {
+		s, err := ParseSchema([]byte(tt.schema))
+		if err != nil {
+			t.Fatalf("ParseSchema(%s): %v", tt.schema, err)
+		}
+		err = mustQuery(t, tt.doc).Validate(s)
+		if tt.want == "" {
+			if err != nil {
+				t.Errorf("%s rejected %s: %v", tt.schema, tt.doc, err)
+			}
+			continue
+		}
+		if got := violations(t, err); got != tt.want {
+			t.Errorf("%s on %s: %s, want %s", tt.schema, tt.doc, got, tt.want)
+		}
+	}
+}
+
+func TestCompileSchema(t *testing.T) {
+	for _, bad := range []string{`{"type":"strng"}`, `{"pattern":"("}`, `{"required":"x"}`, `{"multipleOf":0}`, `{"minLength":-1}`, `[]`, `{`} {
+		if _, err := ParseSchema([]byte(bad)); err == nil {
+			t.Errorf("ParseSchema(%s) accepted", bad)
+		}
+	}
+	s, err := CompileSchema(mustQuery(t, `{"type":"array","items":{"type":"integer"}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := mustQuery(t, `[1,2]`).Validate(s); err != nil {
+		t.Errorf("valid array rejected: %v", err)
+	}
+	if err := mustQuery(t, `[1,2.5]`).Validate(s); err == nil {
+		t.Error("fractional item accepted")
+	}
+}
@@ -0,0 +1,261 @@
+package jsonq
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// ErrStopIteration may be returned by a StreamEach callback to stop reading
+// without an error.
+var ErrStopIteration = errors.New("jsonq: stop iteration")
+
+// errStreamDone stops the walk once every requested value has been read.
+var errStreamDone = errors.New("jsonq: stream done")
+
+// streamAction tells walkStream what to do with the value at a path.
+type streamAction int
+
+const (
+	streamSkip    streamAction = iota // discard the value token by token
+	streamDescend                     // walk into the object or array
+	streamHandled                     // the visitor consumed the value itself
+)
+
+/*
+StreamExtract reads a json document from r and returns a JSONQuery holding
+only the values at the given paths, at their original locations, so that they
+can be read with the usual getters. Nothing outside the requested paths is
+kept in memory, and reading stops as soon as every path has been seen:
+
+	res, err := jsonq.StreamExtract(resp.Body, "total", "next_openid")
+	total := res.IntOr(0, "total")
+
+Paths use the ParsePath syntax without negative indexes. Missing paths are
+simply absent from the result.
+*/
+func StreamExtract(r io.Reader, paths ...string) (*JSONQuery, error) {
+	targets := make([]Path, len(paths))
+	for i, expr := range paths {
+		p, err := parseStreamPath(expr)
+		if err != nil {
+			return nil, err
+		}
+		targets[i] = p
+	}
+	res := &JSONQuery{}
+	found := make([]bool, len(targets))
+	remaining := len(targets)
+	dec := newStreamDecoder(r)
+	err := walkStream(dec, nil, func(p Path) (streamAction, error) {
+		action := streamSkip
+		for i, t := range targets {
+			switch {
+			case !found[i] && pathEqual(p, t):
+				action = streamHandled
+			case len(t) > len(p) && pathEqual(p, t[:len(p)]) && action == streamSkip:
+				action = streamDescend
+			}
+		}
+		if action != streamHandled {
+			return action, nil
+		}
+		var v interface{}
+		if err := dec.Decode(&v); err != nil {
+			return action, err
+		}
+		for i, t := range targets {
+			if !found[i] && pathEqual(p, t) {
+				found[i] = true
+				remaining--
+			}
+		}
+		if len(p) == 0 {
+			res.blob = v
+		} else {
+			blob, err := setIn(res.blob, p, 0, v)
+			if err != nil {
+				return action, err
+			}
+			res.blob = blob
+		}
+		if remaining == 0 {
+			return action, errStreamDone
+		}
+		return action, nil
+	})
+	if err != nil && err != errStreamDone {
+		return nil, err
+	}
+	return res, nil
+}
+
+/*
+StreamEach reads a json document from r and calls fn for every element of
+the array at path, decoding one element at a time. path is a ParsePath path
+followed by [*]:
+
+	err := jsonq.StreamEach(resp.Body, "data.openid[*]", func(i int, item *jsonq.JSONQuery) error {
+		openid, _ := item.String()
+		return save(openid)
+	})
+
+An error returned by fn stops reading and is returned, except
+ErrStopIteration which stops reading and returns nil. If the document has no
+value at path, StreamEach returns an ErrNotFound error; if it is not an
+array, an ErrTypeMismatch error.
+*/
+func StreamEach(r io.Reader, path string, fn func(index int, item *JSONQuery) error) error {
+	if !strings.HasSuffix(path, "[*]") {
+		return fmt.Errorf("jsonq: invalid stream path %q: must end with [*]", path)
+	}
+	target, err := parseStreamPath(strings.TrimSuffix(path, "[*]"))
+	if err != nil {
+		return err
+	}
+	dec := newStreamDecoder(r)
+	seen := false
+	err = walkStream(dec, nil, func(p Path) (streamAction, error) {
+		if len(p) < len(target) {
+			if pathEqual(p, target[:len(p)]) {
+				return streamDescend, nil
+			}
+			return streamSkip, nil
+		}
+		if !pathEqual(p, target) {
+			return streamSkip, nil
+		}
+		seen = true
+		tok, err := dec.Token()
+		if err != nil {
+			return streamHandled, err
+		}
+		if tok != json.Delim('[') {
+			actual := jsonTypeName(tok)
+			if tok == json.Delim('{') {
+				actual = "object"
+			}
+			return streamHandled, &PathError{Err: ErrTypeMismatch, Path: p, Expected: "array", Actual: actual}
+		}
+		for i := 0; dec.More(); i++ {
+			var v interface{}
+			if err := dec.Decode(&v); err != nil {
+				return streamHandled, err
+			}
+			if err := fn(i, &JSONQuery{blob: v}); err != nil {
+				return streamHandled, err
+			}
+		}
+		if _, err := dec.Token(); err != nil {
+			return streamHandled, err
+		}
+		return streamHandled, errStreamDone
+	})
+	switch {
+	case err == errStreamDone || errors.Is(err, ErrStopIteration):
+		return nil
+	case err != nil:
+		return err
+	case !seen:
+		return atPath(notFound(""), target)
+	}
+	return nil
+}
+
+// newStreamDecoder returns a decoder that keeps numbers as json.Number like FromReader.
+func newStreamDecoder(r io.Reader) *json.Decoder {
+	dec := json.NewDecoder(r)
+	dec.UseNumber()
+	return dec
+}
+
+// parseStreamPath parses a path for streaming, where negative indexes cannot
+// be resolved because array lengths are unknown until the end.
+func parseStreamPath(expr string) (Path, error) {
+	p, err := ParsePath(expr)
+	if err != nil {
+		return nil, err
+	}
+	for _, seg := range p {
+		if seg.IsIndex && seg.Index < 0 {
+			return nil, fmt.Errorf("jsonq: invalid stream path %q: negative indexes are not supported", expr)
+		}
+	}
+	return p, nil
+}
+
+// pathEqual reports whether two concrete paths address the same value.
+func pathEqual(a, b Path) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i].IsIndex != b[i].IsIndex {
+			return false
+		}
+		if a[i].IsIndex && a[i].Index != b[i].Index || !a[i].IsIndex && a[i].Key != b[i].Key {
+			return false
+		}
+	}
+	return true
+}
+
+// walkStream walks the value at the decoder's position, asking visit what to
+// do with it and, when descending, with each of its members or elements.
+func walkStream(dec *json.Decoder, p Path, visit func(Path) (streamAction, error)) error {
+	action, err := visit(p)
+	if err != nil || action == streamHandled {
+		return err
+	}
+	if action == streamSkip {
+		return skipValue(dec)
+	}
+	tok, err := dec.Token()
+	if err != nil {
+		return err
+	}
+	switch tok {
+	case json.Delim('{'):
+		for dec.More() {
+			key, err := dec.Token()
+			if err != nil {
+				return err
+			}
+			if err := walkStream(dec, p.Append(Segment{Key: key.(string)}), visit); err != nil {
+				return err
+			}
+		}
+	case json.Delim('['):
+		for i := 0; dec.More(); i++ {
+			if err := walkStream(dec, p.Append(Segment{Index: i, IsIndex: true}), visit); err != nil {
+				return err
+			}
+		}
+	default:
+		return nil
+	}
+	_, err = dec.Token()
+	return err
+}
+
+// skipValue discards the next value without decoding it.
+func skipValue(dec *json.Decoder) error {
+	depth := 0
+	for {
+		tok, err := dec.Token()
+		if err != nil {
+			return err
+		}
+		switch tok {
+		case json.Delim('{'), json.Delim('['):
+			depth++
+		case json.Delim('}'), json.Delim(']'):
+			depth--
+		}
+		if depth == 0 {
+			return nil
+		}
+	}
+}
@@ -0,0 +1,108 @@
+package jsonq
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+)
+
+const streamDoc = `{"total":3,"skip":{"deep":[1,{"a":[2,3]}],"s":"x]}"},"data":{"openid":["o1","o2","o3"]},"next_openid":"o3","meta":{"x":{"y":[10,20,30]}},"id":9007199254740993}`
+
+// eofFailReader fails instead of returning io.EOF, so a stream reader that
+// consumes its whole input is caught.
+type eofFailReader struct {
+	r io.Reader
+}
+
+func (f *eofFailReader) Read(p []byte) (int, error) {
+	n, err := f.r.Read(p)
+	if err == io.EOF {
+		return n, errors.New("read past end")
+	}
+	return n, err
+}
+
+func TestStreamExtract(t *testing.T) {
+	res, err := StreamExtract(strings.NewReader(streamDoc), "total", "next_openid", "meta.x.y[1]", "missing.path", "id")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// only the requested values are kept, at their original paths
+	want := `{"id":9007199254740993,"meta":{"x":{"y":[null,20]}},"next_openid":"o3","total":3}`
+	if b, _ := res.MarshalJSON(); string(b) != want {
+		t.Errorf("extracted %s\nwant      %s", b, want)
+	}
+	if v, err := res.Int64("id"); err != nil || v != 9007199254740993 {
+		t.Errorf("id = %d, %v; numbers must stay exact", v, err)
+	}
+	if _, err := StreamExtract(strings.NewReader(streamDoc), "data.openid[-1]"); err == nil {
+		t.Error("negative index accepted")
+	}
+	whole, err := StreamExtract(strings.NewReader(`[1,2]`), "$")
+	if b, _ := whole.MarshalJSON(); err != nil || string(b) != `[1,2]` {
+		t.Errorf("root path = %s, %v", b, err)
+	}
+}
+
+func TestStreamStopsEarly(t *testing.T) {
+	// the input is truncated after the requested values, so reading on fails
+	truncated := `{"a":1,"b":{"c":[5,6]},"rest":[1,2,3`
+	if res, err := StreamExtract(&eofFailReader{strings.NewReader(truncated)}, "a", "b.c[0]"); err != nil || res.IntOr(0, "b.c[0]") != 5 {
+		t.Errorf("StreamExtract read past the requested values: %v", err)
+	}
+	n := 0
+	err := StreamEach(&eofFailReader{strings.NewReader(`{"list":[{"id":1},{"id":2},{"id":3`)}, "list[*]", func(int, *JSONQuery) error {
+		n++
+		return ErrStopIteration
+	})
+	if err != nil || n != 1 {
+		t.Errorf("ErrStopIteration: %d calls, %v", n, err)
+	}
+	if _, err := StreamExtract(strings.NewReader(`{"a":`), "b"); err == nil {
+		t.Error("truncated document accepted when the path is missing")
+	}
+}
+
+func TestStreamEach(t *testing.T) {
+	var got []string
+	err := StreamEach(strings.NewReader(streamDoc), "data.openid[*]", func(i int, item *JSONQuery) error {
+		s, err := item.String()
+		got = append(got, fmt.Sprintf("%d %s", i, s))
+		return err
+	})
+	if err != nil || strings.Join(got, ",") != "0 o1,1 o2,2 o3" {
+		t.Errorf("items = %v, %v", got, err)
+	}
+	got = got[:0]
+	err = StreamEach(strings.NewReader(`[{"id":1},[2],null]`), "[*]", func(i int, item *JSONQuery) error {
+		b, _ := item.MarshalJSON()
+		got = append(got, string(b))
+		return nil
+	})
+	if err != nil || strings.Join(got, " ") != `{"id":1} [2] null` {
+		t.Errorf("top-level array items = %v, %v", got, err)
+	}
+	noop := func(int, *JSONQuery) error { return nil }
+	tests := []struct {
+		path string
+		is   error
+	}{
+		{"data.nope[*]", ErrNotFound},
+		{"next_openid[*]", ErrTypeMismatch},
+		{"skip[*]", ErrTypeMismatch},
+	}
+	for _, tt := range tests {
+		if err := StreamEach(strings.NewReader(streamDoc), tt.path, noop); !errors.Is(err, tt.is) {
+			t.Errorf("StreamEach(%q) = %v, want %v", tt.path, err, tt.is)
+		}
+	}
+	if err := StreamEach(strings.NewReader(streamDoc), "data.openid", noop); err == nil {
+		t.Error("path without [*] accepted")
+	}
+	boom := errors.New("boom")
+	if err := StreamEach(strings.NewReader(streamDoc), "data.openid[*]", func(int, *JSONQuery) error { return boom }); !errors.Is(err, boom) {
+		t.Errorf("callback error = %v", err)
+	}
+}